@@ -20,9 +20,12 @@ type argOptionBool struct {
 }
 
 type argOptions struct {
-	FlagSet     *flag.FlagSet
-	DebugOption argOptionBool
-	IsSetMap    map[string]*bool
+	FlagSet       *flag.FlagSet
+	DebugOption   argOptionBool
+	OfflineOption argOptionBool
+	JsonOption    argOptionBool
+	FormatOption  argOptionStr
+	IsSetMap      map[string]*bool
 }
 
 // helpers
@@ -36,15 +39,27 @@ func (options *argOptions) boolVar(option *argOptionBool, name string, value boo
 	options.IsSetMap[name] = &option.IsSet
 }
 
+// --json and --format, only for commands printing records
+func (options *argOptions) recordVars() {
+	options.boolVar(&options.JsonOption, "json", false, "print records as json")
+	options.stringVar(&options.FormatOption, "format", "", "print each record with a go template")
+}
+
 func (options *argOptions) init(flagSetName string) {
 	options.FlagSet = flag.NewFlagSet(flagSetName, flag.PanicOnError)
 	options.IsSetMap = map[string]*bool{}
 }
 
 func (options *argOptions) parse() {
+	options.parseArgs(os.Args[2:])
+}
+
+// for commands with sub commands, e.g. gg vpatch create ...
+func (options *argOptions) parseArgs(args []string) {
 	// extra for debug
 	options.boolVar(&options.DebugOption, "debug", false, "show debug messages")
-	options.FlagSet.Parse(os.Args[2:])
+	options.boolVar(&options.OfflineOption, "offline", false, "no network, only local mirrors and caches")
+	options.FlagSet.Parse(args)
 	options.FlagSet.Visit(func(flag *flag.Flag) {
 		*options.IsSetMap[flag.Name] = true
 	})
@@ -53,6 +68,12 @@ func (options *argOptions) parse() {
 	if options.DebugOption.Bool {
 		gglogEnable(nil)
 	}
+	if options.OfflineOption.Bool {
+		ggOfflineFlag = true
+	}
+	if options.JsonOption.Bool || options.FormatOption.IsSet {
+		setOutputMode(options.JsonOption.Bool, options.FormatOption.String)
+	}
 }
 
 func (options *argOptions) args() []string {
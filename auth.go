@@ -0,0 +1,283 @@
+package main
+
+//
+// credentials for private hosts, used for go-get meta and git over https
+//
+
+import (
+	"encoding/base64"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// per host settings in GGHOME/config.json
+type ggAuth struct {
+	Host     string
+	Token    string `json:",omitempty"` // bearer token
+	TokenEnv string `json:",omitempty"` // or the env variable holding it
+	Ssh      bool   `json:",omitempty"` // clone over ssh instead of https
+}
+
+// the host asked for credentials we do not have, or refused the ones we sent
+type authError struct {
+	Host   string
+	Reason string
+}
+
+func (e *authError) Error() string {
+	return "Authentication failed for " + e.Host + ", " + e.Reason + ". Check ~/.netrc, GG_AUTH_TOKENS or the Auth entry for the host in " + ggConfigFilename()
+}
+
+type netrcEntry struct {
+	Login    string
+	Password string
+}
+
+// machine -> entry, "" for default
+func parseNetrc(content string) map[string]netrcEntry {
+	entries := map[string]netrcEntry{}
+	fields := strings.Fields(content)
+	machine := ""
+	inMachine := false
+	for i := 0; i < len(fields); i++ {
+		next := ""
+		if i+1 < len(fields) {
+			next = fields[i+1]
+		}
+		switch fields[i] {
+		case "machine":
+			machine, inMachine = next, true
+			i++
+		case "default":
+			machine, inMachine = "", true
+		case "login":
+			if inMachine {
+				entry := entries[machine]
+				entry.Login = next
+				entries[machine] = entry
+			}
+			i++
+		case "password":
+			if inMachine {
+				entry := entries[machine]
+				entry.Password = next
+				entries[machine] = entry
+			}
+			i++
+		case "account":
+			i++
+		case "macdef":
+			// macro runs to the end of the file as far as we care
+			return entries
+		}
+	}
+	return entries
+}
+
+// $NETRC or ~/.netrc, loaded once
+func (cmd *ggcmd) netrc() map[string]netrcEntry {
+	if cmd.netrcEntries == nil {
+		filename := os.Getenv("NETRC")
+		if filename == "" {
+			filename = filepath.Join(os.Getenv("HOME"), ".netrc")
+		}
+		content, err := ioutil.ReadFile(filename)
+		if err != nil {
+			cmd.netrcEntries = map[string]netrcEntry{}
+		} else {
+			cmd.netrcEntries = parseNetrc(string(content))
+		}
+	}
+	return cmd.netrcEntries
+}
+
+func (cmd *ggcmd) authFor(host string) *ggAuth {
+	for _, auth := range cmd.userConfig().Auth {
+		if strings.EqualFold(auth.Host, host) {
+			return auth
+		}
+	}
+	return nil
+}
+
+// value for the Authorization header, "" if we have nothing for host
+// GG_AUTH_TOKENS=host=token,host=token, then config, then netrc
+func (cmd *ggcmd) authorizationFor(host string) string {
+	for _, item := range splitCommaList(os.Getenv("GG_AUTH_TOKENS")) {
+		parts := strings.SplitN(item, "=", 2)
+		if len(parts) == 2 && strings.EqualFold(parts[0], host) {
+			return "Bearer " + parts[1]
+		}
+	}
+
+	if auth := cmd.authFor(host); auth != nil {
+		if auth.TokenEnv != "" && os.Getenv(auth.TokenEnv) != "" {
+			return "Bearer " + os.Getenv(auth.TokenEnv)
+		}
+		if auth.Token != "" {
+			return "Bearer " + auth.Token
+		}
+	}
+
+	netrc := cmd.netrc()
+	entry, ok := netrc[host]
+	if !ok {
+		entry, ok = netrc[""]
+	}
+	if ok && entry.Login != "" {
+		return "Basic " + base64.StdEncoding.EncodeToString([]byte(entry.Login+":"+entry.Password))
+	}
+	return ""
+}
+
+// GET with credentials, they are only ever sent over https
+func (cmd *ggcmd) httpGetWithAuth(rawurl string) (*http.Response, error) {
+	req, err := http.NewRequest("GET", rawurl, nil)
+	if err != nil {
+		return nil, err
+	}
+	if req.URL.Scheme == "https" {
+		if authorization := cmd.authorizationFor(req.URL.Hostname()); authorization != "" {
+			req.Header.Set("Authorization", authorization)
+		}
+	}
+
+	resp, err := ggHttpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	// the host that answered, after redirects
+	host := resp.Request.URL.Hostname()
+	if resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden {
+		resp.Body.Close()
+		return nil, &authError{host, resp.Status}
+	}
+	// private gitlab and friends redirect to a login page instead, a package
+	// may well have login in its own path
+	if final := resp.Request.URL; final.String() != req.URL.String() && isLoginPath(final.Path) {
+		resp.Body.Close()
+		return nil, &authError{host, "redirected to " + final.String()}
+	}
+	return resp, nil
+}
+
+func isLoginPath(path string) bool {
+	lower := strings.ToLower(path)
+	for _, part := range []string{"login", "signin", "sign_in", "sign-in"} {
+		if strings.Contains(lower, part) {
+			return true
+		}
+	}
+	return false
+}
+
+// host of an https://, ssh:// or scp like git@host:path source
+func vcsSourceHost(vcsSource string) string {
+	if u, err := url.Parse(vcsSource); err == nil && u.Host != "" {
+		return u.Hostname()
+	}
+	if i := strings.Index(vcsSource, ":"); i > 0 && !strings.Contains(vcsSource[:i], "/") {
+		host := vcsSource[:i]
+		if j := strings.LastIndex(host, "@"); j >= 0 {
+			host = host[j+1:]
+		}
+		return host
+	}
+	return ""
+}
+
+// https://host/a/b -> git@host:a/b for hosts that prefer ssh
+func (cmd *ggcmd) sshVcsSource(vcs string, vcsSource string) string {
+	u, err := url.Parse(vcsSource)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") {
+		return vcsSource
+	}
+	auth := cmd.authFor(u.Hostname())
+	if auth == nil || !auth.Ssh {
+		return vcsSource
+	}
+
+	path := strings.TrimPrefix(u.Path, "/")
+	if vcs == "hg" {
+		return "ssh://hg@" + u.Host + "/" + path
+	}
+	return "git@" + u.Host + ":" + path
+}
+
+// env for git, credentials as an extra header for https sources
+// never prompt, a prompt would hang gg. An ssh command of the user's own is
+// left alone, it may need its own options.
+func (cmd *ggcmd) vcsEnv(vcs string, vcsSource string) []string {
+	env := append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+	if !hasUserSshCommand() {
+		env = append(env, "GIT_SSH_COMMAND=ssh -o BatchMode=yes")
+	}
+	if vcs != "git" || !strings.HasPrefix(vcsSource, "https://") {
+		return env
+	}
+	authorization := cmd.authorizationFor(vcsSourceHost(vcsSource))
+	if authorization == "" {
+		return env
+	}
+
+	// kept out of the command line, so not visible in ps, after any config
+	// the user passes the same way
+	count, _ := strconv.Atoi(os.Getenv("GIT_CONFIG_COUNT"))
+	if count < 0 {
+		count = 0
+	}
+	var withHeader []string
+	for _, kv := range env {
+		if !strings.HasPrefix(kv, "GIT_CONFIG_COUNT=") {
+			withHeader = append(withHeader, kv)
+		}
+	}
+	index := strconv.Itoa(count)
+	return append(withHeader,
+		"GIT_CONFIG_COUNT="+strconv.Itoa(count+1),
+		"GIT_CONFIG_KEY_"+index+"=http."+vcsSource+".extraHeader",
+		"GIT_CONFIG_VALUE_"+index+"=Authorization: "+authorization)
+}
+
+// GIT_SSH_COMMAND, GIT_SSH or core.sshCommand set by the user
+func hasUserSshCommand() bool {
+	if os.Getenv("GIT_SSH_COMMAND") != "" || os.Getenv("GIT_SSH") != "" {
+		return true
+	}
+	out, err := exec.Command("git", "config", "--get", "core.sshCommand").Output()
+	return err == nil && strings.TrimSpace(string(out)) != ""
+}
+
+// vcs output that means credentials, as opposed to a missing repo
+var vcsAuthFailures = []string{
+	"authentication failed",
+	"could not read username",
+	"could not read password",
+	"terminal prompts disabled",
+	"permission denied (publickey",
+	"access denied",
+	"http error: 401",
+	"http error: 403",
+	"the requested url returned error: 401",
+	"the requested url returned error: 403",
+	"authorization failed",
+}
+
+// authError when the vcs output shows a credentials problem, otherwise nil
+func vcsAuthError(vcsSource string, output []byte) error {
+	lower := strings.ToLower(string(output))
+	for _, failure := range vcsAuthFailures {
+		if strings.Contains(lower, failure) {
+			lines := strings.Split(strings.TrimSpace(string(output)), "\n")
+			return &authError{vcsSourceHost(vcsSource), strconv.Quote(strings.TrimSpace(lines[len(lines)-1]))}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,309 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// point ggHttpClient at test servers, by host name and optional port, for
+// the rest of the test. https servers answer for any name, their
+// certificate is trusted.
+func testHttpHosts(t *testing.T, servers map[string]*httptest.Server) {
+	addrs := map[string]string{}
+	roots := x509.NewCertPool()
+	for host, server := range servers {
+		u, _ := url.Parse(server.URL)
+		if server.TLS != nil {
+			roots.AddCert(server.Certificate())
+		}
+		if strings.Contains(host, ":") {
+			addrs[host] = u.Host
+		} else if server.TLS != nil {
+			addrs[host+":443"] = u.Host
+		} else {
+			addrs[host+":80"] = u.Host
+		}
+	}
+
+	transport := ggHttpClient.Transport
+	ggHttpClient.Transport = &http.Transport{
+		DialContext: func(ctx context.Context, network string, addr string) (net.Conn, error) {
+			if server, ok := addrs[addr]; ok {
+				addr = server
+			}
+			return (&net.Dialer{}).DialContext(ctx, network, addr)
+		},
+		TLSClientConfig: &tls.Config{RootCAs: roots, ServerName: "example.com"},
+	}
+	t.Cleanup(func() {
+		ggHttpClient.Transport = transport
+	})
+}
+
+// no user config, netrc or tokens from the machine running the tests, and
+// a debug log to write to
+func testAuthEnv(t *testing.T, netrc string) {
+	gglogDisable()
+	dir := t.TempDir()
+	t.Setenv("GGHOME", dir)
+	t.Setenv("GG_AUTH_TOKENS", "")
+	netrcFile := filepath.Join(dir, "netrc")
+	err := ioutil.WriteFile(netrcFile, []byte(netrc), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("NETRC", netrcFile)
+}
+
+func TestParseNetrc(t *testing.T) {
+	entries := parseNetrc(`machine git.example.com
+  login alice
+  password s3cret
+machine other.example.com login bob account x password pw
+default login anon password none
+macdef init
+  machine ignored.example.com login nobody
+`)
+	want := map[string]netrcEntry{
+		"git.example.com":   {"alice", "s3cret"},
+		"other.example.com": {"bob", "pw"},
+		"":                  {"anon", "none"},
+	}
+	if len(entries) != len(want) {
+		t.Errorf("parseNetrc found %v, want %v", entries, want)
+	}
+	for machine, entry := range want {
+		if entries[machine] != entry {
+			t.Errorf("parseNetrc %q = %v, want %v", machine, entries[machine], entry)
+		}
+	}
+}
+
+func TestAuthorizationFor(t *testing.T) {
+	testAuthEnv(t, "machine git.example.com login alice password s3cret\n")
+	t.Setenv("GG_AUTH_TOKENS", "tokens.example.com=envtoken")
+	t.Setenv("GG_TEST_TOKEN", "fromenv")
+
+	cmd := &ggcmd{config: &ggConfig{Auth: []*ggAuth{
+		{Host: "config.example.com", Token: "configtoken"},
+		{Host: "tokenenv.example.com", TokenEnv: "GG_TEST_TOKEN", Token: "unused"},
+		{Host: "git.example.com", Token: "wins over netrc"},
+	}}}
+	tests := []struct {
+		host          string
+		authorization string
+	}{
+		{"tokens.example.com", "Bearer envtoken"},
+		{"config.example.com", "Bearer configtoken"},
+		{"tokenenv.example.com", "Bearer fromenv"},
+		{"git.example.com", "Bearer wins over netrc"},
+		{"other.example.com", ""},
+	}
+	for _, test := range tests {
+		if authorization := cmd.authorizationFor(test.host); authorization != test.authorization {
+			t.Errorf("authorizationFor(%s) = %q, want %q", test.host, authorization, test.authorization)
+		}
+	}
+
+	cmd = &ggcmd{config: &ggConfig{}}
+	if authorization := cmd.authorizationFor("git.example.com"); authorization != "Basic YWxpY2U6czNjcmV0" {
+		t.Errorf("authorizationFor from netrc = %q, want Basic alice:s3cret", authorization)
+	}
+}
+
+func TestHttpGetWithAuth(t *testing.T) {
+	testAuthEnv(t, "machine private.example.com login alice password s3cret\n")
+	t.Setenv("GG_AUTH_TOKENS", "tokens.example.com=t0ken")
+
+	var elsewhereAuthorization []string
+	elsewhere := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		elsewhereAuthorization = append(elsewhereAuthorization, r.Header.Get("Authorization"))
+		w.Write([]byte("elsewhere"))
+	}))
+	defer elsewhere.Close()
+
+	private := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/redirect":
+			http.Redirect(w, r, "https://elsewhere.example.com/moved", http.StatusFound)
+			return
+		case "/redirect-port":
+			http.Redirect(w, r, "https://private.example.com:8443/moved", http.StatusFound)
+			return
+		case "/login-redirect":
+			http.Redirect(w, r, "/users/sign_in", http.StatusFound)
+			return
+		case "/forbidden":
+			w.WriteHeader(http.StatusForbidden)
+			return
+		case "/missing":
+			http.NotFound(w, r)
+			return
+		}
+		authorization := r.Header.Get("Authorization")
+		if authorization != "Basic YWxpY2U6czNjcmV0" && authorization != "Bearer t0ken" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte("ok " + authorization))
+	}))
+	defer private.Close()
+
+	testHttpHosts(t, map[string]*httptest.Server{
+		"private.example.com":      private,
+		"tokens.example.com":       private,
+		"nocreds.example.com":      private,
+		"elsewhere.example.com":    elsewhere,
+		"private.example.com:8443": elsewhere,
+	})
+
+	cmd := &ggcmd{config: &ggConfig{}}
+	get := func(rawurl string) (string, error) {
+		resp, err := cmd.httpGetWithAuth(rawurl)
+		if err != nil {
+			return "", err
+		}
+		defer resp.Body.Close()
+		body, err := ioutil.ReadAll(resp.Body)
+		return resp.Status + " " + string(body), err
+	}
+
+	tests := []struct {
+		url     string
+		body    string // what the answer contains
+		authErr bool
+	}{
+		{"https://private.example.com/a", "ok Basic", false},
+		{"https://tokens.example.com/a", "ok Bearer t0ken", false},
+		{"https://nocreds.example.com/a", "", true},
+		{"https://private.example.com/forbidden", "", true},
+		{"https://private.example.com/login-redirect", "", true},
+		{"https://private.example.com/x/gologin?go-get=1", "ok Basic", false},
+		{"https://private.example.com/users/sign_in", "ok Basic", false},
+		{"https://private.example.com/missing", "404", false},
+		{"https://private.example.com/redirect", "elsewhere", false},
+		{"https://private.example.com/redirect-port", "elsewhere", false},
+	}
+	for _, test := range tests {
+		body, err := get(test.url)
+		_, isAuthErr := err.(*authError)
+		switch {
+		case test.authErr && !isAuthErr:
+			t.Errorf("GET %s = %q, %v, want an authError", test.url, body, err)
+		case !test.authErr && err != nil:
+			t.Errorf("GET %s failed %v", test.url, err)
+		case !strings.Contains(body, test.body):
+			t.Errorf("GET %s = %q, want %q in it", test.url, body, test.body)
+		}
+	}
+
+	if len(elsewhereAuthorization) != 2 || elsewhereAuthorization[0] != "" || elsewhereAuthorization[1] != "" {
+		t.Errorf("credentials for private.example.com sent to elsewhere.example.com after a redirect: %q", elsewhereAuthorization)
+	}
+}
+
+func TestVcsAuthError(t *testing.T) {
+	tests := []struct {
+		output  string
+		authErr bool
+	}{
+		{"Cloning into 'x'...\nremote: HTTP Basic: Access denied\nfatal: Authentication failed for 'https://git.example.com/a/b.git/'", true},
+		{"fatal: could not read Username for 'https://git.example.com': terminal prompts disabled", true},
+		{"git@git.example.com: Permission denied (publickey).\nfatal: Could not read from remote repository.", true},
+		{"fatal: unable to access 'https://git.example.com/a/b/': The requested URL returned error: 403", true},
+		{"remote: Repository not found.\nfatal: repository 'https://git.example.com/a/b/' not found", false},
+		{"fatal: unable to access 'https://git.example.com/a/b/': The requested URL returned error: 404", false},
+		{"abort: HTTP Error 404: Not Found", false},
+	}
+	for _, test := range tests {
+		err := vcsAuthError("https://git.example.com/a/b", []byte(test.output))
+		if (err != nil) != test.authErr {
+			t.Errorf("vcsAuthError(%q) = %v, want an error %v", test.output, err, test.authErr)
+		}
+		if err != nil && err.(*authError).Host != "git.example.com" {
+			t.Errorf("vcsAuthError(%q) host %s, want git.example.com", test.output, err.(*authError).Host)
+		}
+	}
+}
+
+func TestVcsEnv(t *testing.T) {
+	testAuthEnv(t, "machine git.example.com login alice password s3cret\n")
+	gitConfig := filepath.Join(t.TempDir(), "gitconfig")
+	t.Setenv("GIT_CONFIG_GLOBAL", gitConfig)
+	t.Setenv("GIT_CONFIG_NOSYSTEM", "1")
+	t.Setenv("GIT_SSH_COMMAND", "")
+	t.Setenv("GIT_SSH", "")
+	t.Setenv("GIT_CONFIG_COUNT", "")
+	cmd := &ggcmd{config: &ggConfig{}}
+
+	// the last value of a name wins, as for exec.Cmd
+	lookup := func(env []string, name string) string {
+		value := ""
+		for _, kv := range env {
+			if strings.HasPrefix(kv, name+"=") {
+				value = kv[len(name)+1:]
+			}
+		}
+		return value
+	}
+
+	env := cmd.vcsEnv("git", "https://git.example.com/a/b")
+	if lookup(env, "GIT_SSH_COMMAND") != "ssh -o BatchMode=yes" || lookup(env, "GIT_TERMINAL_PROMPT") != "0" {
+		t.Errorf("vcsEnv does not keep ssh and git from prompting, %v", env)
+	}
+	if lookup(env, "GIT_CONFIG_COUNT") != "1" || lookup(env, "GIT_CONFIG_KEY_0") != "http.https://git.example.com/a/b.extraHeader" ||
+		lookup(env, "GIT_CONFIG_VALUE_0") != "Authorization: Basic YWxpY2U6czNjcmV0" {
+		t.Errorf("vcsEnv without an extraHeader, %v", env)
+	}
+
+	env = cmd.vcsEnv("git", "https://other.example.com/a/b")
+	if lookup(env, "GIT_CONFIG_COUNT") != "" {
+		t.Errorf("vcsEnv of a host without credentials sets git config, %v", env)
+	}
+
+	// config the user passes in the env comes first
+	t.Setenv("GIT_CONFIG_COUNT", "2")
+	t.Setenv("GIT_CONFIG_KEY_0", "user.name")
+	t.Setenv("GIT_CONFIG_VALUE_0", "me")
+	t.Setenv("GIT_CONFIG_KEY_1", "http.proxy")
+	t.Setenv("GIT_CONFIG_VALUE_1", "http://proxy.example.com")
+	env = cmd.vcsEnv("git", "https://git.example.com/a/b")
+	if lookup(env, "GIT_CONFIG_COUNT") != "3" || lookup(env, "GIT_CONFIG_KEY_0") != "user.name" || lookup(env, "GIT_CONFIG_VALUE_1") != "http://proxy.example.com" ||
+		lookup(env, "GIT_CONFIG_KEY_2") != "http.https://git.example.com/a/b.extraHeader" {
+		t.Errorf("vcsEnv with the user's GIT_CONFIG_COUNT, %v", env)
+	}
+	count := 0
+	for _, kv := range env {
+		if strings.HasPrefix(kv, "GIT_CONFIG_COUNT=") {
+			count++
+		}
+	}
+	if count != 1 {
+		t.Errorf("vcsEnv has %d GIT_CONFIG_COUNT", count)
+	}
+
+	// the user's own ssh command is left alone
+	t.Setenv("GIT_SSH_COMMAND", "ssh -i /home/me/.ssh/deploy -p 2222")
+	env = cmd.vcsEnv("git", "git@git.example.com:a/b")
+	if lookup(env, "GIT_SSH_COMMAND") != "ssh -i /home/me/.ssh/deploy -p 2222" {
+		t.Errorf("vcsEnv overrides GIT_SSH_COMMAND, %v", lookup(env, "GIT_SSH_COMMAND"))
+	}
+	t.Setenv("GIT_SSH_COMMAND", "")
+	err := ioutil.WriteFile(gitConfig, []byte("[core]\n\tsshCommand = ssh -o ProxyCommand=corkscrew\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	env = cmd.vcsEnv("git", "git@git.example.com:a/b")
+	if lookup(env, "GIT_SSH_COMMAND") != "" {
+		t.Errorf("vcsEnv overrides core.sshCommand with %v", lookup(env, "GIT_SSH_COMMAND"))
+	}
+}
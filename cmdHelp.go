@@ -8,7 +8,7 @@ import (
 func (cmd *ggcmd) cmdHelp() {
 	// gg help
 	if len(os.Args) == 2 {
-		fmt.Printf(cmd.commands[""].usage)
+		fmt.Print(cmd.commands[""].usage)
 		os.Exit(0)
 	}
 
@@ -20,7 +20,7 @@ func (cmd *ggcmd) cmdHelp() {
 		ggFatal("Command %s not understood for help.", action)
 	}
 
-	fmt.Printf(helpAction.usage)
+	fmt.Print(helpAction.usage)
 	fmt.Printf("\n")
 	os.Exit(0)
 }
@@ -2,30 +2,139 @@ package main
 
 import (
 	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
 )
 
 func (cmd *ggcmd) cmdLdep() {
 	var optDepTests argOptionBool
+	var optVendorRoot argOptionStr
+	var optGraph argOptionStr
+	var optRepo argOptionBool
+	var optPlatforms argOptionStr
+	var optTags argOptionStr
+	var optStatus argOptionBool
 	options := argOptions{}
 	options.init("ldep")
 	options.boolVar(&optDepTests, "dep-tests", true, "Also check dependencies of tests")
+	options.stringVar(&optVendorRoot, "v", "", "Vendor package root")
+	options.stringVar(&optVendorRoot, "vendor", "", "Vendor package root")
+	options.stringVar(&optGraph, "graph", "", "Print dependency graph as dot, json, mermaid")
+	options.boolVar(&optRepo, "repo", false, "Graph of repos instead of packages")
+	options.stringVar(&optPlatforms, "platforms", "", "Comma separated GOOS/GOARCH, e.g. linux/amd64,windows/amd64")
+	options.stringVar(&optTags, "tags", "", "Comma separated build tags")
+	options.boolVar(&optStatus, "status", false, "Show if each dependency is vendored, canonical, missing or local")
+	options.recordVars()
 	options.parse()
 	optPackages := options.args()
 
 	// was a package specified
 	// if not just do it in current directory
-	var goListArg string = "."
+	goListArgs := optPackages
+	if len(goListArgs) == 0 {
+		goListArgs = []string{"."}
+	}
+
+	// annotate with vendor status when there is a vendor root, platforms from it
+	vendorFilename, currentGgv, err := resolveVendorConfigFilename(optVendorRoot.String, optVendorRoot.IsSet)
+	if err != nil {
+		if optStatus.Bool {
+			ggFatal("--status needs a vendor root. %s", err)
+		}
+		currentGgv = nil
+	}
+	cmd.setDepPlatforms(optPlatforms, optTags, currentGgv)
 
-	if len(optPackages) == 0 {
-		goListArg = "."
-	} else if len(optPackages) == 1 {
-		goListArg = optPackages[0]
-	} else {
-		ggFatal("Please specify exactly one package to check.")
+	if optGraph.IsSet {
+		rawEdges := cmd.ldepGraphHelper(goListArgs, optDepTests.Bool)
+		cmd.writeDepGraph(rawEdges, currentGgv, filepath.Dir(vendorFilename), optRepo.Bool, optGraph.String)
+		return
+	}
+
+	vendorPrefix := ""
+	if currentGgv != nil {
+		vendorPrefix = currentGgv.VendorPrefix
+	}
+	deps, specific := cmd.ldepHelper(goListArgs, optDepTests.Bool, vendorPrefix)
+
+	var statuses map[string]string
+	if optStatus.Bool {
+		cmd.loadImportMaps(filepath.Dir(vendorFilename))
+		statuses = cmd.ldepStatuses(deps, goListArgs, currentGgv)
+	}
+	if structuredOutput() {
+		printRecords(depRecords(deps, specific, statuses))
+		return
+	}
+
+	if !optStatus.Bool {
+		for _, pkg := range deps {
+			fmt.Printf("%s\n", platformNote(pkg, specific))
+		}
+		return
 	}
 
-	deps := cmd.ldepHelper(goListArg, optDepTests.Bool)
 	for _, pkg := range deps {
-		fmt.Printf("%s\n", pkg)
+		fmt.Printf("%-9s %s\n", statuses[pkg], platformNote(pkg, specific))
+	}
+}
+
+// vendored:  imported under the vendor prefix
+// canonical: imported by its canonical path, but vendored, usev it
+// missing:   imported by its canonical path and not vendored, vadd it
+// local:     part of the project being checked
+func (cmd *ggcmd) ldepStatuses(deps []string, goListArgs []string, ggv *ggvJson) map[string]string {
+	// the project, its module or its repo
+	var localRoots []string
+	for _, pkgGoList := range goListPackages(nil, goListArgs) {
+		localRoots = append(localRoots, cmd.ldepLocalRoot(pkgGoList))
+	}
+
+	statuses := map[string]string{}
+	for _, pkg := range deps {
+		if strings.HasPrefix(pkg, ggv.VendorPrefix+"/") {
+			statuses[pkg] = "vendored"
+			continue
+		}
+		for _, root := range localRoots {
+			if metaPrefixMatches(root, pkg) {
+				statuses[pkg] = "local"
+				break
+			}
+		}
+		if statuses[pkg] != "" {
+			continue
+		}
+		if name, _ := ggv.packageFor(pkg); name != "" {
+			statuses[pkg] = "canonical"
+		} else {
+			statuses[pkg] = "missing"
+		}
+	}
+	return statuses
+}
+
+// module path, else the repo holding the package: the nearest directory with
+// a .git, .hg, .bzr or .svn between it and GOPATH/src, else what the import
+// maps or known hosts make of the import path
+func (cmd *ggcmd) ldepLocalRoot(pkgGoList goListJson) string {
+	if pkgGoList.Module != nil {
+		return pkgGoList.Module.Path
+	}
+	if pkgGoList.Root != "" && pkgGoList.Dir != "" {
+		src := filepath.Join(pkgGoList.Root, "src")
+		for dir := pkgGoList.Dir; strings.HasPrefix(dir, src+string(filepath.Separator)); dir = filepath.Dir(dir) {
+			for _, marker := range []string{".git", ".hg", ".bzr", ".svn"} {
+				if _, err := os.Stat(filepath.Join(dir, marker)); err == nil {
+					rel, _ := filepath.Rel(src, dir)
+					return filepath.ToSlash(rel)
+				}
+			}
+		}
+	}
+	if meta := cmd.importMapMeta(pkgGoList.ImportPath); meta != nil {
+		return meta.Prefix
 	}
+	return cmd.trimPackageToRepo(pkgGoList.ImportPath)
 }
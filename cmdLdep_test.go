@@ -0,0 +1,66 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestUnderGoListed(t *testing.T) {
+	listed := []goListJson{{ImportPath: "example.com/a"}, {ImportPath: "example.com/b/c"}}
+	tests := map[string]bool{
+		"example.com/a":     true,
+		"example.com/a/x":   true,
+		"example.com/b/c/d": true,
+		"example.com/ab":    false,
+		"example.com/a-x":   false,
+		"example.com/b":     false,
+		"example.com/b/cd":  false,
+	}
+	for pkg, want := range tests {
+		if got := underGoListed(pkg, listed); got != want {
+			t.Errorf("underGoListed %s = %v, want %v", pkg, got, want)
+		}
+	}
+}
+
+func TestLdepLocalRoot(t *testing.T) {
+	gglogDisable()
+	root := t.TempDir()
+	src := filepath.Join(root, "src")
+	for _, dir := range []string{
+		"example.com/proj/.git",
+		"example.com/proj/cmd/tool",
+		"example.com/hgproj/.hg",
+		"example.com/hgproj/sub",
+		"example.com/bare/pkg",
+		"github.com/org/repo/pkg",
+	} {
+		err := os.MkdirAll(filepath.Join(src, filepath.FromSlash(dir)), 0755)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	// a repo around GOPATH itself does not count
+	os.Mkdir(filepath.Join(root, ".git"), 0755)
+
+	cmd := &ggcmd{config: &ggConfig{}}
+	cmd.importMaps = []*ggImportMap{{Prefix: "example.com/bare", Vcs: "git", Source: "https://git.example.com/bare"}}
+	tests := []struct {
+		pkg  goListJson
+		want string
+	}{
+		{goListJson{ImportPath: "example.com/mod/x", Module: &struct{ Path string }{"example.com/mod"}}, "example.com/mod"},
+		{goListJson{ImportPath: "example.com/proj/cmd/tool", Root: root, Dir: filepath.Join(src, "example.com/proj/cmd/tool")}, "example.com/proj"},
+		{goListJson{ImportPath: "example.com/proj", Root: root, Dir: filepath.Join(src, "example.com/proj")}, "example.com/proj"},
+		{goListJson{ImportPath: "example.com/hgproj/sub", Root: root, Dir: filepath.Join(src, "example.com/hgproj/sub")}, "example.com/hgproj"},
+		{goListJson{ImportPath: "example.com/bare/pkg", Root: root, Dir: filepath.Join(src, "example.com/bare/pkg")}, "example.com/bare"},
+		{goListJson{ImportPath: "github.com/org/repo/pkg", Root: root, Dir: filepath.Join(src, "github.com/org/repo/pkg")}, "github.com/org/repo"},
+		{goListJson{ImportPath: "example.com/unknown/pkg"}, "example.com/unknown/pkg"},
+	}
+	for _, test := range tests {
+		if got := cmd.ldepLocalRoot(test.pkg); got != test.want {
+			t.Errorf("ldepLocalRoot %s = %s, want %s", test.pkg.ImportPath, got, test.want)
+		}
+	}
+}
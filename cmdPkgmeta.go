@@ -2,11 +2,117 @@ package main
 
 import (
 	"fmt"
-	"os"
+	"sort"
+	"strings"
+	"time"
 )
 
+// Meta when found, Error otherwise
+type pkgmetaRecord struct {
+	Package string
+	Meta    *pkgMeta `json:",omitempty"`
+	Error   string   `json:",omitempty"`
+}
+
+// a cache entry, with whether it would be looked up again
+type metaCacheRecord struct {
+	Package string
+	*metaCacheEntry
+	Expired bool
+}
+
 func (cmd *ggcmd) cmdPkgmeta() {
-	p := os.Args[2]
-	pkg, vcs, vcsSource, err := getPkgMeta(p)
-	fmt.Printf("getPkgMeta(%s) = %s, %s, %s, %v\n", p, pkg, vcs, vcsSource, err)
+	var optCache argOptionBool
+	var optRefresh argOptionBool
+
+	options := argOptions{}
+	options.init("pkgmeta")
+	options.boolVar(&optCache, "cache", false, "Show cached meta instead of looking it up")
+	options.boolVar(&optRefresh, "refresh", false, "Look up package meta again instead of using the cache")
+	options.recordVars()
+	options.parse()
+	optPackages := options.args()
+
+	if optCache.Bool {
+		cmd.printMetaCache(optPackages)
+		return
+	}
+
+	if len(optPackages) == 0 {
+		ggFatal("Please specify at least one package.")
+	}
+	cmd.metaRefresh = optRefresh.Bool
+	cmd.loadImportMapsHere()
+
+	if structuredOutput() {
+		records := []pkgmetaRecord{}
+		for _, p := range optPackages {
+			meta, err := cmd.getPkgMeta(p)
+			record := pkgmetaRecord{Package: p, Meta: meta}
+			if err != nil {
+				record.Error = err.Error()
+			}
+			records = append(records, record)
+		}
+		printRecords(records)
+		return
+	}
+
+	for _, p := range optPackages {
+		meta, err := cmd.getPkgMeta(p)
+		if err != nil {
+			fmt.Printf("getPkgMeta(%s) = %v\n", p, err)
+			continue
+		}
+		fmt.Printf("getPkgMeta(%s) = %s, %s, %s\n", p, meta.Prefix, meta.Vcs, meta.RepoRoot)
+		if meta.Subdir != "" {
+			fmt.Printf("    subdir: %s\n", meta.Subdir)
+		}
+		if meta.Source != nil {
+			fmt.Printf("    go-source: %s %s %s\n", meta.Source.Home, meta.Source.Directory, meta.Source.File)
+		}
+	}
+}
+
+// entries for the given paths and their sub packages, all if none given
+func (cmd *ggcmd) printMetaCache(prefixes []string) {
+	entries := cmd.metaCache()
+	var names []string
+	for name := range entries {
+		if len(prefixes) == 0 {
+			names = append(names, name)
+			continue
+		}
+		for _, prefix := range prefixes {
+			if metaPrefixMatches(strings.TrimSuffix(prefix, "/..."), name) {
+				names = append(names, name)
+				break
+			}
+		}
+	}
+	sort.Strings(names)
+
+	now := time.Now()
+	if structuredOutput() {
+		records := []metaCacheRecord{}
+		for _, name := range names {
+			records = append(records, metaCacheRecord{name, entries[name], entries[name].expired(now)})
+		}
+		printRecords(records)
+		return
+	}
+
+	fmt.Printf("%s\n", metaCacheFilename())
+	for _, name := range names {
+		entry := entries[name]
+		age := now.Sub(entry.Fetched).Truncate(time.Second).String()
+		if entry.expired(now) {
+			age += ", expired"
+		}
+		if entry.Meta != nil {
+			fmt.Printf("%s = %s, %s, %s (%s)\n", name, entry.Meta.Prefix, entry.Meta.Vcs, entry.Meta.RepoRoot, age)
+		} else {
+			fmt.Printf("%s failed: %s (%s)\n", name, entry.Error, age)
+		}
+	}
 }
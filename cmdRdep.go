@@ -2,23 +2,58 @@ package main
 
 import (
 	"fmt"
-	"os"
+	"path/filepath"
 )
 
 func (cmd *ggcmd) cmdRdep() {
 	var optDepTests argOptionBool
+	var optGraph argOptionStr
+	var optRepo argOptionBool
+	var optPlatforms argOptionStr
+	var optTags argOptionStr
 	options := argOptions{}
 	options.init("rdep")
 	options.boolVar(&optDepTests, "dep-tests", true, "Also check dependencies of tests")
+	options.stringVar(&optGraph, "graph", "", "Print dependency graph as dot, json, mermaid")
+	options.boolVar(&optRepo, "repo", false, "Graph of repos instead of packages")
+	options.stringVar(&optPlatforms, "platforms", "", "Comma separated GOOS/GOARCH, e.g. linux/amd64,windows/amd64")
+	options.stringVar(&optTags, "tags", "", "Comma separated build tags")
+	options.recordVars()
 	options.parse()
 	optPackages := options.args()
 
 	if len(optPackages) != 1 {
 		ggFatal("Please specify exactly one go-gettable package.")
 	}
+	cmd.loadImportMapsHere()
 
-	deps := cmd.rdepHelper(os.Args[2], optDepTests.Bool)
+	// platforms of the vendor root here, unless given
+	vendorFilename, currentGgv, err := resolveVendorConfigFilename("", false)
+	if err != nil {
+		currentGgv = nil
+	}
+	cmd.setDepPlatforms(optPlatforms, optTags, currentGgv)
+
+	if optGraph.IsSet {
+		rawEdges := cmd.rdepGraphHelper(optPackages[0], optDepTests.Bool)
+		// the remote package imports canonical paths, it has no vendor
+		// prefix to use, so its dependencies are vendored or missing only
+		var graphGgv *ggvJson
+		if currentGgv != nil {
+			rdepGgv := *currentGgv
+			rdepGgv.VendorPrefix = ""
+			graphGgv = &rdepGgv
+		}
+		cmd.writeDepGraph(rawEdges, graphGgv, filepath.Dir(vendorFilename), optRepo.Bool, optGraph.String)
+		return
+	}
+
+	deps, specific := cmd.rdepHelper(optPackages[0], optDepTests.Bool)
+	if structuredOutput() {
+		printRecords(depRecords(deps, specific, nil))
+		return
+	}
 	for _, pkg := range deps {
-		fmt.Printf("%s\n", pkg)
+		fmt.Printf("%s\n", platformNote(pkg, specific))
 	}
 }
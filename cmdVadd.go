@@ -10,6 +10,7 @@ func (cmd *ggcmd) cmdVadd() {
 	var optVendorRoot argOptionStr
 	var optVcs argOptionStr
 	var optVcsSource argOptionStr
+	var optFallbacks argOptionStr
 	var optRevision argOptionStr
 	var optLock argOptionBool
 	var optRewrite argOptionBool
@@ -18,14 +19,20 @@ func (cmd *ggcmd) cmdVadd() {
 	var optDepTests argOptionBool
 	var optNotes argOptionStr
 	var optTest argOptionBool
+	var optRefresh argOptionBool
+	var optPlatforms argOptionStr
+	var optTags argOptionStr
+	var optInclude argOptionStr
+	var optExclude argOptionStr
 	var optPackages []string
 
 	options := argOptions{}
 	options.init("vadd")
 	options.stringVar(&optVendorRoot, "v", "", "Vendor package root")
 	options.stringVar(&optVendorRoot, "vendor", "", "Vendor package root")
-	options.stringVar(&optVcs, "vcs", "", "git, hg, manual")
+	options.stringVar(&optVcs, "vcs", "", "git, hg, proxy, manual")
 	options.stringVar(&optVcsSource, "vcs-source", "", "e.g. https://github.com/aaa/bb")
+	options.stringVar(&optFallbacks, "fallbacks", "", "Comma separated sources to try when vcs-source fails")
 	options.stringVar(&optRevision, "revision", "", "source control revision hash")
 	options.boolVar(&optLock, "lock", false, "Lock on revision")
 	options.boolVar(&optRewrite, "rewrite", true, "Rewrite imports on vendored package")
@@ -34,6 +41,12 @@ func (cmd *ggcmd) cmdVadd() {
 	options.boolVar(&optDepTests, "dep-tests", false, "Also check dependencies of tests")
 	options.stringVar(&optNotes, "notes", "", "Additional notes")
 	options.boolVar(&optTest, "test", false, "Just test to see what will change.")
+	options.boolVar(&optRefresh, "refresh", false, "Look up package meta again instead of using the cache")
+	options.stringVar(&optPlatforms, "platforms", "", "Comma separated GOOS/GOARCH, saved in _ggv.json")
+	options.stringVar(&optTags, "tags", "", "Comma separated build tags, saved in _ggv.json")
+	options.stringVar(&optInclude, "include", "", "Comma separated globs of what to vendor of the repo")
+	options.stringVar(&optExclude, "exclude", "", "Comma separated globs of what not to vendor of the repo")
+	options.recordVars()
 	options.parse()
 	optPackages = options.args()
 
@@ -42,8 +55,8 @@ func (cmd *ggcmd) cmdVadd() {
 	}
 
 	if len(optPackages) > 1 {
-		if optVcs.IsSet || optVcsSource.IsSet || optRevision.IsSet {
-			ggFatal("When specifying more than one package, --vcs, --vcs-source, --revision may not be specified.")
+		if optVcs.IsSet || optVcsSource.IsSet || optFallbacks.IsSet || optRevision.IsSet || optInclude.IsSet || optExclude.IsSet {
+			ggFatal("When specifying more than one package, --vcs, --vcs-source, --fallbacks, --revision, --include, --exclude may not be specified.")
 		}
 	}
 
@@ -51,7 +64,18 @@ func (cmd *ggcmd) cmdVadd() {
 	if err != nil {
 		ggFatal("Unable to get vendor file %s", err)
 	}
+	cmd.loadUrlRewrites(currentGgv)
+	cmd.metaRefresh = optRefresh.Bool
+	cmd.setDepPlatforms(optPlatforms, optTags, currentGgv)
+	if optPlatforms.IsSet {
+		currentGgv.Platforms = splitCommaList(optPlatforms.String)
+	}
+	if optTags.IsSet {
+		currentGgv.BuildTags = splitCommaList(optTags.String)
+	}
 	vendorDir := filepath.Dir(vendorFilename)
+	cmd.loadOfflineVendor(vendorDir, currentGgv)
+	cmd.loadImportMaps(vendorDir)
 	vendorRoot := currentGgv.VendorPrefix
 
 	// check if we have this one already
@@ -72,13 +96,24 @@ func (cmd *ggcmd) cmdVadd() {
 		}
 	}
 
+	// proxy from $GOPROXY unless specified
+	if optVcs.String == "proxy" && !optVcsSource.IsSet && len(optPackages) == 1 {
+		optVcsSource.String = proxyBaseUrl() + "/" + proxyEscapePath(optPackages[0])
+		optVcsSource.IsSet = true
+	}
+
 	todoPackages := map[string][]string{}
 
 	// if we are doing single package and specifing vcs, then do it
 	if optVcs.IsSet && optVcsSource.IsSet && len(optPackages) == 1 {
 		todoPackages[optPackages[0]] = []string{optPackages[0], optVcs.String, optVcsSource.String}
 	} else {
-		todoPackages = cmd.getMinimalPackagesList(optPackages, optShallow.Bool, optDepTests.Bool, nil)
+		given := &ggvPackage{
+			Revision: optRevision.String,
+			Include:  splitCommaList(optInclude.String),
+			Exclude:  splitCommaList(optExclude.String),
+		}
+		todoPackages = cmd.getMinimalPackagesList(optPackages, optShallow.Bool, optDepTests.Bool, nil, given)
 	}
 
 	gglog.Printf("Affected packages:\n")
@@ -110,22 +145,20 @@ func (cmd *ggcmd) cmdVadd() {
 			if newPackageInfo.VcsSource == "" {
 				newPackageInfo.VcsSource = goGetInfo[2]
 			}
+
+			if len(goGetInfo) > 3 {
+				newPackageInfo.Subdir = goGetInfo[3]
+			}
+
+			// the repo of the package given, not its dependencies
+			if len(optPackages) == 1 && metaPrefixMatches(pkgName, optPackages[0]) {
+				newPackageInfo.Fallbacks = splitCommaList(optFallbacks.String)
+				newPackageInfo.Include = splitCommaList(optInclude.String)
+				newPackageInfo.Exclude = splitCommaList(optExclude.String)
+			}
 		} else {
 			// existing package; may get updated as side effect
-			newPackageInfo.LastUpdate = currentPackageInfo.LastUpdate
-			newPackageInfo.Vcs = currentPackageInfo.Vcs
-			newPackageInfo.VcsSource = currentPackageInfo.VcsSource
-			if currentPackageInfo.Lock {
-				newPackageInfo.Revision = currentPackageInfo.Revision
-			} else {
-				newPackageInfo.Revision = ""
-			}
-			newPackageInfo.Lock = currentPackageInfo.Lock
-			newPackageInfo.RewriteImports = currentPackageInfo.RewriteImports
-			newPackageInfo.ShallowUpdate = currentPackageInfo.ShallowUpdate
-			newPackageInfo.SaveRepo = currentPackageInfo.SaveRepo
-			newPackageInfo.DepTests = currentPackageInfo.DepTests
-			newPackageInfo.Notes = currentPackageInfo.Notes
+			newPackageInfo = currentPackageInfo.refetch(currentPackageInfo.Lock)
 		}
 
 		// skip manual packages
@@ -136,19 +169,10 @@ func (cmd *ggcmd) cmdVadd() {
 
 	err = cmd.downloadUpdate(vendorDir, vendorRoot, updatedPackages, optTest.Bool)
 
-	for pkg, pkgInfo := range updatedPackages {
-		var oldInfo *ggvPackage = currentGgv.Packages[pkg]
-		if oldInfo == nil {
-			fmt.Printf("Added %s - %s %s - %s\n", pkg, pkgInfo.Vcs, pkgInfo.VcsSource, pkgInfo.Revision)
-		} else {
-			if pkgInfo.Revision != oldInfo.Revision {
-				fmt.Printf("Updated %s - %s %s - %s to %s\n", pkg, pkgInfo.Vcs, pkgInfo.VcsSource, oldInfo.Revision, pkgInfo.Revision)
-			}
-		}
-	}
+	printChanges(packageChanges(currentGgv.Packages, updatedPackages, ""))
 
 	if optTest.Bool {
-		fmt.Printf("Dry run. Exiting with no errors.\n")
+		fmt.Fprintf(ggMessagesOut, "Dry run. Exiting with no errors.\n")
 		return
 	}
 
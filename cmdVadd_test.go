@@ -0,0 +1,99 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+// a git repo at dir with files, committed
+func testGitRepo(t *testing.T, dir string, files map[string]string) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("no git")
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		err := os.MkdirAll(filepath.Dir(path), 0755)
+		if err == nil {
+			err = ioutil.WriteFile(path, []byte(content), 0644)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, args := range [][]string{{"init", "-q"}, {"add", "-A"}, {"commit", "-q", "-m", "init"}} {
+		git := exec.Command("git", args...)
+		git.Dir = dir
+		git.Env = append(os.Environ(), "GIT_AUTHOR_NAME=gg", "GIT_AUTHOR_EMAIL=gg@example.com",
+			"GIT_COMMITTER_NAME=gg", "GIT_COMMITTER_EMAIL=gg@example.com")
+		if out, err := git.CombinedOutput(); err != nil {
+			t.Fatalf("git %v %v %s", args, err, out)
+		}
+	}
+}
+
+// GOPATH with an empty vendor root at src/proj/vendor, its _ggv.json
+func testVendorRoot(t *testing.T, importMap string) string {
+	testAuthEnv(t, "")
+	gopath := t.TempDir()
+	t.Setenv("GOPATH", gopath)
+	t.Setenv("GG_OFFLINE", "")
+	vendorDir := filepath.Join(gopath, "src", "proj", "vendor")
+	err := os.MkdirAll(vendorDir, 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ggv := &ggvJson{VendorPrefix: "proj/vendor", Packages: map[string]*ggvPackage{}}
+	vendorFilename := filepath.Join(vendorDir, "_ggv.json")
+	ggv.saveGvv(vendorFilename)
+	err = ioutil.WriteFile(filepath.Join(vendorDir, "_ggmap.json"), []byte(importMap), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return vendorFilename
+}
+
+// the command as run from the command line, args after gg
+func testRunCommand(t *testing.T, args ...string) {
+	osArgs := os.Args
+	os.Args = append([]string{"gg"}, args...)
+	defer func() { os.Args = osArgs }()
+	cmd := &ggcmd{}
+	cmd.init()
+	cmd.getCommand().helper()
+	ggCleanup()
+}
+
+func TestVaddFallbacksOnlyForThePackageGiven(t *testing.T) {
+	repos := t.TempDir()
+	libRepo := filepath.Join(repos, "lib")
+	depRepo := filepath.Join(repos, "dep")
+	testGitRepo(t, libRepo, map[string]string{"lib.go": "package lib\n\nimport _ \"example.com/dep\"\n"})
+	testGitRepo(t, depRepo, map[string]string{"dep.go": "package dep\n"})
+	vendorFilename := testVendorRoot(t, `{"Mappings": [
+		{"Prefix": "example.com/lib", "Vcs": "git", "Source": "`+filepath.ToSlash(libRepo)+`"},
+		{"Prefix": "example.com/dep", "Vcs": "git", "Source": "`+filepath.ToSlash(depRepo)+`"}]}`)
+
+	testRunCommand(t, "vadd", "-v", "proj/vendor", "--fallbacks", "https://mirror.example.com/lib", "example.com/lib")
+
+	content, _ := ioutil.ReadFile(vendorFilename)
+	ggv, err := decodeGgv(content, vendorFilename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lib, dep := ggv.Packages["example.com/lib"], ggv.Packages["example.com/dep"]
+	if lib == nil || dep == nil {
+		t.Fatalf("vadd added %v, want example.com/lib and its dependency", ggv.Packages)
+	}
+	if len(lib.Fallbacks) != 1 || lib.Fallbacks[0] != "https://mirror.example.com/lib" {
+		t.Errorf("example.com/lib has Fallbacks %v", lib.Fallbacks)
+	}
+	if len(dep.Fallbacks) != 0 {
+		t.Errorf("dependency example.com/dep got the Fallbacks of example.com/lib, %v", dep.Fallbacks)
+	}
+	if _, err := os.Stat(filepath.Join(filepath.Dir(vendorFilename), "example.com", "dep", "dep.go")); err != nil {
+		t.Errorf("dependency not vendored, %v", err)
+	}
+}
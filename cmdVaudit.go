@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+func (cmd *ggcmd) cmdVaudit() {
+	var optVendorRoot argOptionStr
+	var optDb argOptionStr
+	var optFailOn argOptionStr
+	var optRefresh argOptionBool
+
+	options := argOptions{}
+	options.init("vaudit")
+	options.stringVar(&optVendorRoot, "v", "", "Vendor package root")
+	options.stringVar(&optVendorRoot, "vendor", "", "Vendor package root")
+	options.stringVar(&optDb, "db", os.Getenv("GG_ADVISORY_DB"), "OSV advisory directory, .zip or .tar.gz")
+	options.stringVar(&optFailOn, "fail-on", "high", "low, medium, high, critical")
+	options.boolVar(&optRefresh, "refresh", false, "Pull latest changes into the local mirrors")
+	options.parse()
+	optPackages := options.args()
+
+	if optDb.String == "" {
+		ggFatal("Please specify the advisory database with --db or GG_ADVISORY_DB.")
+	}
+	failRank := auditSeverityRank[strings.ToUpper(optFailOn.String)]
+	if failRank == 0 {
+		ggFatal("Unknown severity %s, use low, medium, high or critical.", optFailOn.String)
+	}
+
+	_, currentGgv, err := resolveVendorConfigFilename(optVendorRoot.String, optVendorRoot.IsSet)
+	if err != nil {
+		ggFatal("Unable to get vendor file %s", err)
+	}
+	cmd.loadUrlRewrites(currentGgv)
+
+	if len(optPackages) == 0 {
+		for p := range currentGgv.Packages {
+			optPackages = append(optPackages, p)
+		}
+		sort.Strings(optPackages)
+	}
+	for _, p := range optPackages {
+		if currentGgv.Packages[p] == nil {
+			ggFatal("Specified package %s does not exist. vadd it first.", p)
+		}
+	}
+
+	advisories, err := loadOsvDatabase(optDb.String)
+	if err != nil {
+		ggFatal("Unable to read advisory database %s", err)
+	}
+	gglog.Printf("%d advisories loaded\n", len(advisories))
+
+	findings := cmd.auditPackages(currentGgv, optPackages, advisories, optRefresh.Bool)
+	sortAuditFindings(findings)
+
+	failing := 0
+	for _, finding := range findings {
+		fmt.Printf("%s %s %s - %s\n", finding.Package, finding.Id, finding.Severity, finding.Summary)
+		if len(finding.Aliases) > 0 {
+			fmt.Printf("    aliases: %s\n", strings.Join(finding.Aliases, ", "))
+		}
+		fmt.Printf("    vendored: %s\n", finding.Revision)
+		if len(finding.Fixed) > 0 {
+			fmt.Printf("    fixed: %s\n", strings.Join(finding.Fixed, ", "))
+		}
+		if auditSeverityRank[finding.Severity] >= failRank {
+			failing++
+		}
+	}
+
+	fmt.Printf("%d advisories found, %d packages checked, %d at or above %s.\n", len(findings), len(optPackages), failing, strings.ToUpper(optFailOn.String))
+	if failing > 0 {
+		ggExit(1)
+	}
+}
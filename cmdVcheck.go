@@ -0,0 +1,144 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+func (cmd *ggcmd) cmdVcheck() {
+	var optVendorRoot argOptionStr
+	var optDepTests argOptionBool
+
+	options := argOptions{}
+	options.init("vcheck")
+	options.stringVar(&optVendorRoot, "v", "", "Vendor package root")
+	options.stringVar(&optVendorRoot, "vendor", "", "Vendor package root")
+	options.boolVar(&optDepTests, "dep-tests", false, "Also check imports of tests")
+	options.parse()
+
+	vendorFilename, currentGgv, err := resolveVendorConfigFilename(optVendorRoot.String, optVendorRoot.IsSet)
+	if err != nil {
+		ggFatal("Unable to get vendor file %s", err)
+	}
+	vendorDir := filepath.Dir(vendorFilename)
+
+	problems := cmd.vcheckProblems(vendorDir, currentGgv, optDepTests.Bool)
+	for _, problem := range problems {
+		fmt.Printf("%s\n", problem)
+	}
+
+	if len(problems) > 0 {
+		fmt.Printf("%d problems found.\n", len(problems))
+		ggExit(1)
+	}
+	fmt.Printf("Vendor directory matches %s.\n", filepath.Base(vendorFilename))
+}
+
+// consistency problems between _ggv.json and the vendor directory
+func (cmd *ggcmd) vcheckProblems(vendorDir string, ggv *ggvJson, includeTests bool) []string {
+	var problems []string
+
+	var pkgNames []string
+	for p := range ggv.Packages {
+		pkgNames = append(pkgNames, p)
+	}
+	sort.Strings(pkgNames)
+
+	// overlapping keys
+	for _, p := range pkgNames {
+		for _, other := range pkgNames {
+			if strings.HasPrefix(other, p+"/") {
+				problems = append(problems, fmt.Sprintf("overlap: %s is nested in %s", other, p))
+			}
+		}
+	}
+
+	// missing or empty packages
+	for _, p := range pkgNames {
+		if !vcheckHasFiles(filepath.Join(vendorDir, p)) {
+			if ggv.Packages[p].Vcs == "manual" {
+				problems = append(problems, fmt.Sprintf("missing: %s manual entry is empty", p))
+			} else {
+				problems = append(problems, fmt.Sprintf("missing: %s", p))
+			}
+		}
+	}
+
+	// directories nobody recorded
+	filepath.Walk(vendorDir, func(path string, f os.FileInfo, err error) error {
+		if err != nil || !f.IsDir() || path == vendorDir {
+			return nil
+		}
+		name := f.Name()
+		if strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_") || name == "testdata" {
+			return filepath.SkipDir
+		}
+		rel, err := filepath.Rel(vendorDir, path)
+		if err != nil {
+			return nil
+		}
+		rel = filepath.ToSlash(rel)
+
+		if pkgName, _ := ggv.packageFor(rel); pkgName != "" {
+			return filepath.SkipDir
+		}
+		// parent of a vendored package, e.g. github.com
+		for _, p := range pkgNames {
+			if strings.HasPrefix(p, rel+"/") {
+				return nil
+			}
+		}
+		problems = append(problems, fmt.Sprintf("untracked: %s", rel))
+		return filepath.SkipDir
+	})
+
+	// imports that do not match the vendor tree
+	vendorRoot := ggv.VendorPrefix
+	for _, p := range pkgNames {
+		info := ggv.Packages[p]
+		graph, err := scanImportGraph(filepath.Join(vendorDir, p), p, includeTests)
+		if err != nil {
+			continue
+		}
+
+		var pkgs []string
+		for pkg := range graph {
+			pkgs = append(pkgs, pkg)
+		}
+		sort.Strings(pkgs)
+
+		for _, pkg := range pkgs {
+			for _, imp := range graph[pkg] {
+				canonical := canonicalImport(vendorRoot, imp)
+				if cmd.isCorePackage(canonical) {
+					continue
+				}
+				depName, _ := ggv.packageFor(canonical)
+
+				if imp == canonical {
+					if info.RewriteImports && vendorRoot != "" && depName != "" {
+						problems = append(problems, fmt.Sprintf("canonical: %s imports %s, should be %s/%s", pkg, imp, vendorRoot, imp))
+					}
+					continue
+				}
+
+				if depName == "" {
+					problems = append(problems, fmt.Sprintf("unvendored: %s imports %s, %s is not vendored", pkg, imp, canonical))
+				} else if !vcheckHasFiles(filepath.Join(vendorDir, canonical)) {
+					problems = append(problems, fmt.Sprintf("unvendored: %s imports %s, directory is missing", pkg, imp))
+				}
+			}
+		}
+	}
+
+	return problems
+}
+
+func vcheckHasFiles(dir string) bool {
+	entries, err := ioutil.ReadDir(dir)
+	return err == nil && len(entries) > 0
+}
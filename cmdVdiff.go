@@ -0,0 +1,100 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+)
+
+func (cmd *ggcmd) cmdVdiff() {
+	var optVendorRoot argOptionStr
+	var optTo argOptionStr
+	var optStat argOptionBool
+	var optIgnoreRewrite argOptionBool
+
+	options := argOptions{}
+	options.init("vdiff")
+	options.stringVar(&optVendorRoot, "v", "", "Vendor package root")
+	options.stringVar(&optVendorRoot, "vendor", "", "Vendor package root")
+	options.stringVar(&optTo, "to", "", "source control revision hash to compare against")
+	options.boolVar(&optStat, "stat", false, "Only show a summary of the changes")
+	options.boolVar(&optIgnoreRewrite, "ignore-rewrite", false, "Ignore differences caused only by import rewrites")
+	options.parse()
+	optPackages := options.args()
+
+	if len(optPackages) != 1 {
+		ggFatal("Please specify exactly one vendored package to diff.")
+	}
+	p := optPackages[0]
+
+	vendorFilename, currentGgv, err := resolveVendorConfigFilename(optVendorRoot.String, optVendorRoot.IsSet)
+	if err != nil {
+		ggFatal("Unable to get vendor file %s", err)
+	}
+	cmd.loadUrlRewrites(currentGgv)
+	vendorDir := filepath.Dir(vendorFilename)
+	vendorRoot := currentGgv.VendorPrefix
+
+	currentPackageInfo := currentGgv.Packages[p]
+	if currentPackageInfo == nil {
+		ggFatal("Specified package %s does not exist. vadd it first.", p)
+	}
+	if currentPackageInfo.Vcs == "manual" {
+		ggFatal("Package %s is manual and has no revision to compare against.", p)
+	}
+
+	// fetch and rewrite exactly as an update would
+	candidateInfo := *currentPackageInfo
+	candidateInfo.Revision = optTo.String
+
+	tempDir, destDir, revision, err := cmd.downloadPkg(vendorDir, vendorRoot, p, &candidateInfo, true)
+	if err != nil {
+		ggFatal("%s", err)
+	}
+	ggRemoveAtExit(tempDir)
+
+	oldDir, newDir := destDir, tempDir
+	if optIgnoreRewrite.Bool && currentPackageInfo.RewriteImports {
+		// undo the rewrites on both sides, both go through the same gofmt
+		compareDir, err := ioutil.TempDir("", "gg")
+		if err != nil {
+			ggFatal("Unable to create temp directory %s", err)
+		}
+		ggRemoveAtExit(compareDir)
+
+		oldDir = filepath.Join(compareDir, "old")
+		err = copyDir(destDir, oldDir)
+		if err != nil {
+			ggFatal("Unable to copy %s %s", destDir, err)
+		}
+
+		err = cmd.astmodVendorWithPrefix(nil, vendorRoot, oldDir, true)
+		if err != nil {
+			ggFatal("Unable to undo import rewrite for package %s at %s", p, oldDir)
+		}
+		err = cmd.astmodVendorWithPrefix(nil, vendorRoot, newDir, true)
+		if err != nil {
+			ggFatal("Unable to undo import rewrite for package %s at %s", p, newDir)
+		}
+	}
+
+	diffs, err := diffTrees(oldDir, newDir)
+	if err != nil {
+		ggFatal("Unable to diff %s %s", p, err)
+	}
+
+	fmt.Printf("Diff %s - %s %s - %s to %s\n", p, currentPackageInfo.Vcs, currentPackageInfo.VcsSource, currentPackageInfo.Revision, revision)
+	if len(diffs) == 0 {
+		fmt.Printf("No differences.\n")
+		return
+	}
+
+	if optStat.Bool {
+		fmt.Printf("%s", diffStat(diffs))
+		return
+	}
+
+	for _, fd := range diffs {
+		fmt.Printf("%s", fd.unified("a/"+p+"/"+fd.Path, "b/"+p+"/"+fd.Path))
+	}
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+)
+
+func (cmd *ggcmd) cmdVgraph() {
+	var optVendorRoot argOptionStr
+	var optFormat argOptionStr
+	var optRepo argOptionBool
+	var optConsumers argOptionStr
+	var optDepTests argOptionBool
+
+	options := argOptions{}
+	options.init("vgraph")
+	options.stringVar(&optVendorRoot, "v", "", "Vendor package root")
+	options.stringVar(&optVendorRoot, "vendor", "", "Vendor package root")
+	options.stringVar(&optFormat, "graph-format", "dot", "dot, json, mermaid")
+	options.boolVar(&optRepo, "repo", false, "Graph of vendored repos instead of packages")
+	options.stringVar(&optConsumers, "c", "", "Comma separated directories of projects using the vendor root")
+	options.stringVar(&optConsumers, "consumers", "", "Comma separated directories of projects using the vendor root")
+	options.boolVar(&optDepTests, "dep-tests", false, "Also follow imports of tests")
+	options.parse()
+
+	vendorFilename, currentGgv, err := resolveVendorConfigFilename(optVendorRoot.String, optVendorRoot.IsSet)
+	if err != nil {
+		ggFatal("Unable to get vendor file %s", err)
+	}
+	vendorDir := filepath.Dir(vendorFilename)
+
+	rawEdges, err := cmd.vendorImportGraph(vendorDir, currentGgv, optDepTests.Bool, false)
+	if err != nil {
+		ggFatal("Unable to read vendored packages %s", err)
+	}
+	if optConsumers.IsSet {
+		consumersGraph, err := cmd.consumersImportGraph(optConsumers.String, currentGgv.VendorPrefix, optDepTests.Bool, false)
+		if err != nil {
+			ggFatal("Unable to read consumers %s", err)
+		}
+		cmd.mergeImportGraph(rawEdges, consumersGraph, currentGgv.VendorPrefix, false)
+	}
+
+	cmd.writeDepGraph(rawEdges, currentGgv, vendorDir, optRepo.Bool, optFormat.String)
+}
+
+// annotate with the vendor config if there is one, and print
+func (cmd *ggcmd) writeDepGraph(rawEdges importGraph, ggv *ggvJson, vendorDir string, repoLevel bool, format string) {
+	g := cmd.newDepGraph(rawEdges, ggv, vendorDir)
+	if repoLevel {
+		g = cmd.repoDepGraph(g, ggv, vendorDir)
+	}
+
+	err := g.write(os.Stdout, format)
+	if err != nil {
+		ggFatal("%s", err)
+	}
+}
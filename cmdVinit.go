@@ -9,10 +9,12 @@ import (
 
 func (cmd *ggcmd) cmdVinit() {
 	var optVendorRoot argOptionStr
+	var optSplit argOptionBool
 	options := argOptions{}
 	options.init("vinit")
 	options.stringVar(&optVendorRoot, "v", "", "Vendor package root")
 	options.stringVar(&optVendorRoot, "vendor", "", "Vendor package root")
+	options.boolVar(&optSplit, "split", false, "Keep the resolved state in _ggv.lock.json")
 	options.parse()
 
 	var vendorPkg string = ""
@@ -55,13 +57,16 @@ func (cmd *ggcmd) cmdVinit() {
 
 	fmt.Printf("Vendor Package: %s\n", vendorPkg)
 	fmt.Printf("Vendor File: %s\n", vfile)
+	if optSplit.Bool {
+		fmt.Printf("Lock File: %s\n", ggvLockFilename(vfile))
+	}
 	_, err = os.Stat(vfile)
 
 	if err == nil {
 		ggFatal("Exiting with error. _ggv.json already exists at %s", vfile)
 	}
 
-	ggv := ggvJson{"", vendorPkg, map[string]*ggvPackage{}}
+	ggv := ggvJson{VendorPrefix: vendorPkg, Packages: map[string]*ggvPackage{}, split: optSplit.Bool}
 	err = ggv.saveGvv(vfile)
 	if err != nil {
 		ggFatal("Unable to write %s.", vfile)
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+func (cmd *ggcmd) cmdVlicenses() {
+	var optVendorRoot argOptionStr
+	var optAllow argOptionStr
+	var optDeny argOptionStr
+	var optNotice argOptionStr
+	var optTest argOptionBool
+
+	options := argOptions{}
+	options.init("vlicenses")
+	options.stringVar(&optVendorRoot, "v", "", "Vendor package root")
+	options.stringVar(&optVendorRoot, "vendor", "", "Vendor package root")
+	options.stringVar(&optAllow, "allow", "", "Comma separated spdx ids allowed, saved in _ggv.json")
+	options.stringVar(&optDeny, "deny", "", "Comma separated spdx ids denied, saved in _ggv.json")
+	options.stringVar(&optNotice, "notice", "", "Write combined third party notice file")
+	options.boolVar(&optTest, "test", false, "Report only, do not update _ggv.json")
+	options.parse()
+
+	vendorFilename, currentGgv, err := resolveVendorConfigFilename(optVendorRoot.String, optVendorRoot.IsSet)
+	if err != nil {
+		ggFatal("Unable to get vendor file %s", err)
+	}
+	vendorDir := filepath.Dir(vendorFilename)
+
+	if optAllow.IsSet || optDeny.IsSet {
+		if currentGgv.LicensePolicy == nil {
+			currentGgv.LicensePolicy = &ggvLicensePolicy{}
+		}
+		if optAllow.IsSet {
+			currentGgv.LicensePolicy.Allow = splitCommaList(optAllow.String)
+		}
+		if optDeny.IsSet {
+			currentGgv.LicensePolicy.Deny = splitCommaList(optDeny.String)
+		}
+	}
+
+	var pkgNames []string
+	for p := range currentGgv.Packages {
+		pkgNames = append(pkgNames, p)
+	}
+	sort.Strings(pkgNames)
+
+	notice := &bytes.Buffer{}
+	flagged := 0
+	for _, p := range pkgNames {
+		info := currentGgv.Packages[p]
+		pkgDir := filepath.Join(vendorDir, p)
+
+		files, err := detectLicenses(pkgDir)
+		if err != nil && !os.IsNotExist(err) {
+			ggFatal("Unable to read licenses of %s %s", p, err)
+		}
+		info.License = licenseExpression(files)
+
+		flag := licensePolicyFlag(info.License, currentGgv.LicensePolicy)
+		if flag != "" {
+			flagged++
+			fmt.Printf("%s %s - %s\n", p, info.License, flag)
+		} else {
+			fmt.Printf("%s %s\n", p, info.License)
+		}
+		for _, lf := range files {
+			if lf.License != "" {
+				gglog.Printf("%s/%s %s\n", p, lf.Path, lf.License)
+			}
+		}
+
+		if optNotice.IsSet {
+			cmd.vlicensesNotice(notice, p, info, pkgDir, files)
+		}
+	}
+
+	if flagged > 0 {
+		fmt.Printf("%d of %d packages flagged.\n", flagged, len(pkgNames))
+	}
+
+	if optNotice.IsSet {
+		err = ioutil.WriteFile(optNotice.String, notice.Bytes(), 0644)
+		if err != nil {
+			ggFatal("Unable to write %s. %s", optNotice.String, err)
+		}
+	}
+
+	if !optTest.Bool {
+		err = currentGgv.saveGvv(vendorFilename)
+		if err != nil {
+			ggFatal("%s", err)
+		}
+	}
+
+	if flagged > 0 {
+		ggExit(1)
+	}
+}
+
+// one section of the third party notice file
+func (cmd *ggcmd) vlicensesNotice(notice *bytes.Buffer, p string, info *ggvPackage, pkgDir string, files []licenseFile) {
+	fmt.Fprintf(notice, "%s\n%s\n", p, strings.Repeat("=", len(p)))
+	fmt.Fprintf(notice, "License: %s\n", info.License)
+	if info.VcsSource != "" {
+		fmt.Fprintf(notice, "Source: %s\n", info.VcsSource)
+	}
+	if info.Revision != "" {
+		fmt.Fprintf(notice, "Revision: %s\n", info.Revision)
+	}
+	fmt.Fprintf(notice, "\n")
+
+	for _, lf := range files {
+		content, err := readFileString(filepath.Join(pkgDir, filepath.FromSlash(lf.Path)))
+		if err != nil {
+			ggFatal("Unable to read %s %s", lf.Path, err)
+		}
+		fmt.Fprintf(notice, "--- %s ---\n\n%s\n", lf.Path, strings.TrimSpace(content))
+		fmt.Fprintf(notice, "\n")
+	}
+	fmt.Fprintf(notice, "\n")
+}
@@ -11,6 +11,7 @@ func (cmd *ggcmd) cmdVlist() {
 	options.init("vlist")
 	options.stringVar(&optVendorRoot, "v", "", "Vendor package root")
 	options.stringVar(&optVendorRoot, "vendor", "", "Vendor package root")
+	options.recordVars()
 	options.parse()
 
 	vendorFilename, currentGgv, err := resolveVendorConfigFilename(optVendorRoot.String, optVendorRoot.IsSet)
@@ -25,8 +26,23 @@ func (cmd *ggcmd) cmdVlist() {
 	}
 
 	sort.Strings(pprint)
+	if structuredOutput() {
+		records := []vlistRecord{}
+		for _, p := range pprint {
+			records = append(records, vlistRecord{p, currentGgv.Packages[p]})
+		}
+		printRecords(records)
+		return
+	}
 	for _, p := range pprint {
 		info := currentGgv.Packages[p]
 		fmt.Printf("%s %s %s %s\n", p, info.Vcs, info.VcsSource, info.Revision)
 	}
 }
+
+// the package name and everything _ggv.json has for it, as metaCacheRecord
+// does for cache entries
+type vlistRecord struct {
+	Name string
+	*ggvPackage
+}
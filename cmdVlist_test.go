@@ -0,0 +1,48 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+func TestVlistRecord(t *testing.T) {
+	info := &ggvPackage{
+		Vcs:       "proxy",
+		VcsSource: "https://proxy.example.com/example.com/a",
+		Revision:  "v1.2.0",
+		Prune:     &ggvPrune{Tests: true},
+		Version:   "v1.2.0",
+		ZipHash:   "h1:zip",
+		TreeHash:  "h1:tree",
+		Patches:   []string{"_ggpatches/a.patch"},
+	}
+
+	// every field of the package, as in _ggv.json
+	b, err := json.Marshal(vlistRecord{"example.com/a", info})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var record map[string]interface{}
+	json.Unmarshal(b, &record)
+	var inGgv map[string]interface{}
+	b, _ = json.Marshal(info)
+	json.Unmarshal(b, &inGgv)
+	if record["Name"] != "example.com/a" || len(record) != len(inGgv)+1 {
+		t.Errorf("vlist record %v, want Name and %v", record, inGgv)
+	}
+	for field := range inGgv {
+		if _, ok := record[field]; !ok {
+			t.Errorf("vlist record without %s", field)
+		}
+	}
+
+	var out bytes.Buffer
+	recordsOut, format := ggRecordsOut, ggOutputFormat
+	defer func() { ggRecordsOut, ggOutputFormat = recordsOut, format }()
+	ggRecordsOut, ggOutputFormat = &out, "{{.Name}} {{.TreeHash}} {{.Prune.Tests}} {{join .Patches \",\"}}"
+	printRecords([]vlistRecord{{"example.com/a", info}})
+	if out.String() != "example.com/a h1:tree true _ggpatches/a.patch\n" {
+		t.Errorf("vlist --format printed %q", out.String())
+	}
+}
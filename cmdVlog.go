@@ -0,0 +1,125 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+)
+
+func (cmd *ggcmd) cmdVlog() {
+	var optVendorRoot argOptionStr
+	var optFrom argOptionStr
+	var optTo argOptionStr
+	var optMarkdown argOptionBool
+	var optRefresh argOptionBool
+
+	options := argOptions{}
+	options.init("vlog")
+	options.stringVar(&optVendorRoot, "v", "", "Vendor package root")
+	options.stringVar(&optVendorRoot, "vendor", "", "Vendor package root")
+	options.stringVar(&optFrom, "from", "", "start revision, vendored revision if not specified")
+	options.stringVar(&optTo, "to", "", "end revision, latest if not specified")
+	options.boolVar(&optMarkdown, "markdown", false, "Markdown output")
+	options.boolVar(&optRefresh, "refresh", true, "Pull latest changes into the local mirror")
+	options.parse()
+	optPackages := options.args()
+
+	if (optFrom.IsSet || optTo.IsSet) && len(optPackages) != 1 {
+		ggFatal("When specifying --from or --to, you must specify exactly one package.")
+	}
+
+	_, currentGgv, err := resolveVendorConfigFilename(optVendorRoot.String, optVendorRoot.IsSet)
+	if err != nil {
+		ggFatal("Unable to get vendor file %s", err)
+	}
+	cmd.loadUrlRewrites(currentGgv)
+
+	if len(optPackages) == 0 {
+		for p := range currentGgv.Packages {
+			optPackages = append(optPackages, p)
+		}
+		sort.Strings(optPackages)
+	}
+
+	for _, p := range optPackages {
+		info := currentGgv.Packages[p]
+		if info == nil {
+			ggFatal("Specified package %s does not exist. vadd it first.", p)
+		}
+		if info.Vcs == "manual" {
+			continue
+		}
+		if info.Vcs == "proxy" {
+			fmt.Printf("%s - %s from a module proxy, no history available.\n", p, info.Version)
+			continue
+		}
+
+		mirrorDir, err := cmd.mirrorRepo(info, optRefresh.Bool)
+		if err != nil {
+			ggFatal("%s", err)
+		}
+
+		from := info.Revision
+		if optFrom.IsSet {
+			from = optFrom.String
+		}
+		to := optTo.String
+		if to == "" {
+			to, err = mirrorHeadRevision(info.Vcs, mirrorDir)
+			if err != nil {
+				ggFatal("Unable to get latest revision of %s %s", p, err)
+			}
+		}
+
+		cmd.vlogPrintHeader(p, from, to, optMarkdown.Bool)
+
+		if from == "" {
+			cmd.vlogPrintNote("No vendored revision recorded.", optMarkdown.Bool)
+			continue
+		}
+
+		linear, err := mirrorIsAncestor(info.Vcs, mirrorDir, from, to)
+		if err != nil {
+			ggFatal("Unable to compare revisions %s and %s of %s %s", from, to, p, err)
+		}
+		if !linear {
+			cmd.vlogPrintNote(fmt.Sprintf("History is not linear, %s is not an ancestor of %s.", from, to), optMarkdown.Bool)
+			continue
+		}
+
+		entries, err := mirrorLog(info.Vcs, mirrorDir, from, to)
+		if err != nil {
+			ggFatal("Unable to get log of %s %s", p, err)
+		}
+		if len(entries) == 0 {
+			cmd.vlogPrintNote("Up to date.", optMarkdown.Bool)
+			continue
+		}
+
+		for _, entry := range entries {
+			if optMarkdown.Bool {
+				fmt.Printf("- `%s` %s (%s, %s)\n", entry.Revision, entry.Subject, entry.Author, entry.Date)
+			} else {
+				fmt.Printf("  %s %s %s %s\n", entry.Revision, entry.Date, entry.Author, entry.Subject)
+			}
+		}
+		if optMarkdown.Bool {
+			fmt.Printf("\n")
+		}
+	}
+}
+
+func (cmd *ggcmd) vlogPrintHeader(p string, from string, to string, markdown bool) {
+	if markdown {
+		fmt.Printf("### %s\n\n`%s`...`%s`\n\n", p, from, to)
+	} else {
+		fmt.Printf("%s %s..%s\n", p, from, to)
+	}
+}
+
+func (cmd *ggcmd) vlogPrintNote(note string, markdown bool) {
+	if markdown {
+		fmt.Printf("%s\n\n", note)
+	} else {
+		fmt.Printf("  %s\n", note)
+	}
+}
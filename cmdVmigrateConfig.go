@@ -0,0 +1,66 @@
+package main
+
+import (
+	"fmt"
+)
+
+func (cmd *ggcmd) cmdVmigrateConfig() {
+	var optVendorRoot argOptionStr
+	var optDropUnknown argOptionBool
+	var optSplit argOptionBool
+	var optTest argOptionBool
+
+	options := argOptions{}
+	options.init("vmigrate-config")
+	options.stringVar(&optVendorRoot, "v", "", "Vendor package root")
+	options.stringVar(&optVendorRoot, "vendor", "", "Vendor package root")
+	options.boolVar(&optDropUnknown, "drop-unknown", false, "Remove fields this gg does not know")
+	options.boolVar(&optSplit, "split", false, "Move the resolved state into _ggv.lock.json")
+	options.boolVar(&optTest, "test", false, "Just test to see what will change.")
+	options.parse()
+
+	vendorFilename, currentGgv, err := resolveVendorConfigFilename(optVendorRoot.String, optVendorRoot.IsSet)
+	if err != nil {
+		ggFatal("Unable to get vendor file %s", err)
+	}
+
+	split := optSplit.Bool && !currentGgv.split
+	if len(currentGgv.migrations) == 0 && len(currentGgv.unknownFields) == 0 && !split {
+		fmt.Printf("%s is up to date, schema version %s.\n", vendorFilename, ggvSchemaVersion)
+		return
+	}
+
+	for _, note := range currentGgv.migrations {
+		fmt.Printf("Migrate %s\n", note)
+	}
+	if split {
+		fmt.Printf("Split revisions, update times and hashes into %s\n", ggvLockFilename(vendorFilename))
+	}
+	for _, field := range currentGgv.unknownFields {
+		fmt.Printf("Unknown field %s\n", field)
+	}
+	if len(currentGgv.unknownFields) > 0 && !optDropUnknown.Bool {
+		ggFatal("Unknown fields would be lost. Please update gg, or use --drop-unknown to remove them.")
+	}
+
+	if optTest.Bool {
+		fmt.Printf("Dry run. Exiting with no errors.\n")
+		return
+	}
+
+	backupFilename, err := currentGgv.saveBackup(vendorFilename)
+	if err != nil {
+		ggFatal("Unable to back up %s. %s", vendorFilename, err)
+	}
+	currentGgv.readVersion = ggvSchemaVersion
+	currentGgv.unknownFields = nil
+	if split {
+		currentGgv.split = true
+	}
+
+	err = currentGgv.saveGvv(vendorFilename)
+	if err != nil {
+		ggFatal("%s", err)
+	}
+	fmt.Printf("Saved %s as schema version %s, the old file is %s.\n", vendorFilename, ggvSchemaVersion, backupFilename)
+}
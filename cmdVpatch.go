@@ -0,0 +1,302 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// patches live next to _ggv.json
+const vpatchDirName = "_ggpatches"
+
+var reVpatchName = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+func (cmd *ggcmd) cmdVpatch() {
+	if len(os.Args) <= 2 {
+		ggFatal("Please specify create, list, drop or export.")
+	}
+
+	switch os.Args[2] {
+	case "create":
+		cmd.vpatchCreate()
+	case "list":
+		cmd.vpatchList()
+	case "drop":
+		cmd.vpatchDrop()
+	case "export":
+		cmd.vpatchExport()
+	default:
+		ggFatal("vpatch %s not understood. Please specify create, list, drop or export.", os.Args[2])
+	}
+}
+
+func (cmd *ggcmd) vpatchCreate() {
+	var optVendorRoot argOptionStr
+	var optName argOptionStr
+
+	options := argOptions{}
+	options.init("vpatch create")
+	options.stringVar(&optVendorRoot, "v", "", "Vendor package root")
+	options.stringVar(&optVendorRoot, "vendor", "", "Vendor package root")
+	options.stringVar(&optName, "name", "local", "Name of the patch")
+	options.parseArgs(os.Args[3:])
+	optPackages := options.args()
+
+	if len(optPackages) != 1 {
+		ggFatal("Please specify exactly one vendored package.")
+	}
+	p := optPackages[0]
+
+	vendorFilename, currentGgv, err := resolveVendorConfigFilename(optVendorRoot.String, optVendorRoot.IsSet)
+	if err != nil {
+		ggFatal("Unable to get vendor file %s", err)
+	}
+	cmd.loadUrlRewrites(currentGgv)
+	vendorDir := filepath.Dir(vendorFilename)
+	vendorRoot := currentGgv.VendorPrefix
+
+	currentPackageInfo := vpatchPackageInfo(currentGgv, p)
+
+	// pristine tree, as vrebuild would produce it with the existing patches
+	pristineInfo := *currentPackageInfo
+	tempDir, destDir, _, err := cmd.downloadPkg(vendorDir, vendorRoot, p, &pristineInfo, true)
+	if err != nil {
+		ggFatal("%s", err)
+	}
+	ggRemoveAtExit(tempDir)
+
+	patch, err := patchFromTrees(tempDir, destDir)
+	if err != nil {
+		ggFatal("Unable to diff package %s %s", p, err)
+	}
+	if patch == "" {
+		fmt.Printf("No local modifications in %s.\n", p)
+		return
+	}
+
+	// next free number, dropped patches leave gaps
+	number := 0
+	for _, patchName := range currentPackageInfo.Patches {
+		n, _ := strconv.Atoi(strings.SplitN(path.Base(patchName), "-", 2)[0])
+		if n > number {
+			number = n
+		}
+	}
+	patchName := fmt.Sprintf("%s/%s/%04d-%s.patch", vpatchDirName, p, number+1, reVpatchName.ReplaceAllString(optName.String, "-"))
+
+	patchFilename := filepath.Join(vendorDir, filepath.FromSlash(patchName))
+	err = os.MkdirAll(filepath.Dir(patchFilename), os.ModePerm)
+	if err != nil {
+		ggFatal("Unable to create patch directory %s", err)
+	}
+	err = ioutil.WriteFile(patchFilename, []byte(patch), 0644)
+	if err != nil {
+		ggFatal("Unable to write patch %s", err)
+	}
+
+	currentPackageInfo.Patches = append(currentPackageInfo.Patches, patchName)
+	// the vendored files are now what a rebuild gives
+	currentPackageInfo.TreeHash, err = vendorTreeHash(destDir)
+	if err != nil {
+		ggFatal("Unable to hash package %s %s", p, err)
+	}
+	err = currentGgv.saveGvv(vendorFilename)
+	if err != nil {
+		ggFatal("%s", err)
+	}
+
+	diffs, _ := diffTrees(tempDir, destDir)
+	fmt.Printf("Created %s\n", patchName)
+	fmt.Printf("%s", diffStat(diffs))
+}
+
+func (cmd *ggcmd) vpatchList() {
+	var optVendorRoot argOptionStr
+
+	options := argOptions{}
+	options.init("vpatch list")
+	options.stringVar(&optVendorRoot, "v", "", "Vendor package root")
+	options.stringVar(&optVendorRoot, "vendor", "", "Vendor package root")
+	options.parseArgs(os.Args[3:])
+	optPackages := options.args()
+
+	_, currentGgv, err := resolveVendorConfigFilename(optVendorRoot.String, optVendorRoot.IsSet)
+	if err != nil {
+		ggFatal("Unable to get vendor file %s", err)
+	}
+
+	if len(optPackages) == 0 {
+		for p, info := range currentGgv.Packages {
+			if len(info.Patches) > 0 {
+				optPackages = append(optPackages, p)
+			}
+		}
+		sort.Strings(optPackages)
+	}
+
+	for _, p := range optPackages {
+		info := vpatchPackageInfo(currentGgv, p)
+		fmt.Printf("%s\n", p)
+		for _, patchName := range info.Patches {
+			fmt.Printf("    %s\n", patchName)
+		}
+	}
+}
+
+func (cmd *ggcmd) vpatchDrop() {
+	var optVendorRoot argOptionStr
+
+	options := argOptions{}
+	options.init("vpatch drop")
+	options.stringVar(&optVendorRoot, "v", "", "Vendor package root")
+	options.stringVar(&optVendorRoot, "vendor", "", "Vendor package root")
+	options.parseArgs(os.Args[3:])
+	optArgs := options.args()
+
+	if len(optArgs) != 2 {
+		ggFatal("Please specify the package and the patch to drop.")
+	}
+	p := optArgs[0]
+
+	vendorFilename, currentGgv, err := resolveVendorConfigFilename(optVendorRoot.String, optVendorRoot.IsSet)
+	if err != nil {
+		ggFatal("Unable to get vendor file %s", err)
+	}
+	vendorDir := filepath.Dir(vendorFilename)
+
+	currentPackageInfo := vpatchPackageInfo(currentGgv, p)
+
+	// full name or just the file name
+	var patches []string
+	dropped := ""
+	for _, patchName := range currentPackageInfo.Patches {
+		if dropped == "" && (patchName == optArgs[1] || path.Base(patchName) == optArgs[1]) {
+			dropped = patchName
+			continue
+		}
+		patches = append(patches, patchName)
+	}
+	if dropped == "" {
+		ggFatal("Package %s has no patch %s.", p, optArgs[1])
+	}
+
+	err = os.Remove(filepath.Join(vendorDir, filepath.FromSlash(dropped)))
+	if err != nil && !os.IsNotExist(err) {
+		ggFatal("Unable to remove patch %s", err)
+	}
+
+	currentPackageInfo.Patches = patches
+	err = currentGgv.saveGvv(vendorFilename)
+	if err != nil {
+		ggFatal("%s", err)
+	}
+	fmt.Printf("Dropped %s. The vendored files are unchanged until the next vrebuild or vupdate.\n", dropped)
+}
+
+// patches against canonical import paths, to send upstream
+func (cmd *ggcmd) vpatchExport() {
+	var optVendorRoot argOptionStr
+	var optDir argOptionStr
+
+	options := argOptions{}
+	options.init("vpatch export")
+	options.stringVar(&optVendorRoot, "v", "", "Vendor package root")
+	options.stringVar(&optVendorRoot, "vendor", "", "Vendor package root")
+	options.stringVar(&optDir, "dir", ".", "Directory to write the patches to")
+	options.parseArgs(os.Args[3:])
+	optPackages := options.args()
+
+	if len(optPackages) != 1 {
+		ggFatal("Please specify exactly one vendored package.")
+	}
+	p := optPackages[0]
+
+	vendorFilename, currentGgv, err := resolveVendorConfigFilename(optVendorRoot.String, optVendorRoot.IsSet)
+	if err != nil {
+		ggFatal("Unable to get vendor file %s", err)
+	}
+	cmd.loadUrlRewrites(currentGgv)
+	vendorDir := filepath.Dir(vendorFilename)
+	vendorRoot := currentGgv.VendorPrefix
+
+	currentPackageInfo := vpatchPackageInfo(currentGgv, p)
+	if len(currentPackageInfo.Patches) == 0 {
+		ggFatal("Package %s has no patches.", p)
+	}
+
+	pristineInfo := *currentPackageInfo
+	pristineInfo.Patches = nil
+	tempDir, _, _, err := cmd.downloadPkg(vendorDir, vendorRoot, p, &pristineInfo, true)
+	if err != nil {
+		ggFatal("%s", err)
+	}
+	ggRemoveAtExit(tempDir)
+
+	compareDir, err := ioutil.TempDir("", "gg")
+	if err != nil {
+		ggFatal("Unable to create temp directory %s", err)
+	}
+	ggRemoveAtExit(compareDir)
+
+	err = os.MkdirAll(optDir.String, os.ModePerm)
+	if err != nil {
+		ggFatal("Unable to create %s %s", optDir.String, err)
+	}
+
+	// apply one at a time, compare both sides with the rewrites undone
+	for n, patchName := range currentPackageInfo.Patches {
+		beforeDir := filepath.Join(compareDir, strconv.Itoa(n), "before")
+		afterDir := filepath.Join(compareDir, strconv.Itoa(n), "after")
+		err = copyDir(tempDir, beforeDir)
+		if err != nil {
+			ggFatal("Unable to copy %s %s", tempDir, err)
+		}
+
+		err = cmd.applyPackagePatches(vendorDir, p, []string{patchName}, tempDir)
+		if err != nil {
+			ggFatal("%s", err)
+		}
+		err = copyDir(tempDir, afterDir)
+		if err != nil {
+			ggFatal("Unable to copy %s %s", tempDir, err)
+		}
+
+		if currentPackageInfo.RewriteImports {
+			for _, dir := range []string{beforeDir, afterDir} {
+				err = cmd.astmodVendorWithPrefix(nil, vendorRoot, dir, true)
+				if err != nil {
+					ggFatal("Unable to undo import rewrite for package %s at %s", p, dir)
+				}
+			}
+		}
+
+		patch, err := patchFromTrees(beforeDir, afterDir)
+		if err != nil {
+			ggFatal("Unable to diff package %s %s", p, err)
+		}
+
+		exportFilename := filepath.Join(optDir.String, path.Base(patchName))
+		err = ioutil.WriteFile(exportFilename, []byte(patch), 0644)
+		if err != nil {
+			ggFatal("Unable to write patch %s", err)
+		}
+		fmt.Printf("Exported %s\n", exportFilename)
+	}
+}
+
+func vpatchPackageInfo(ggv *ggvJson, p string) *ggvPackage {
+	info := ggv.Packages[p]
+	if info == nil {
+		ggFatal("Specified package %s does not exist. vadd it first.", p)
+	}
+	if info.Vcs == "manual" {
+		ggFatal("Package %s is manual, edit it in place instead.", p)
+	}
+	return info
+}
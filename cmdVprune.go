@@ -0,0 +1,131 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+)
+
+func (cmd *ggcmd) cmdVprune() {
+	var optVendorRoot argOptionStr
+	var optConsumers argOptionStr
+	var optUnused argOptionBool
+	var optTests argOptionBool
+	var optTestdata argOptionBool
+	var optNonGo argOptionBool
+	var optOff argOptionBool
+	var optTest argOptionBool
+
+	options := argOptions{}
+	options.init("vprune")
+	options.stringVar(&optVendorRoot, "v", "", "Vendor package root")
+	options.stringVar(&optVendorRoot, "vendor", "", "Vendor package root")
+	options.stringVar(&optConsumers, "c", "", "Comma separated directories of projects using the vendor root")
+	options.stringVar(&optConsumers, "consumers", "", "Comma separated directories of projects using the vendor root")
+	options.boolVar(&optUnused, "unused", false, "Remove sub-packages nothing imports")
+	options.boolVar(&optTests, "tests", true, "Remove _test.go files")
+	options.boolVar(&optTestdata, "testdata", true, "Remove testdata directories")
+	options.boolVar(&optNonGo, "non-go", true, "Remove files that are not go sources, licenses are kept")
+	options.boolVar(&optOff, "off", false, "Stop pruning, next vrebuild or vupdate restores everything")
+	options.boolVar(&optTest, "test", false, "Just test to see what will change.")
+	options.parse()
+	optPackages := options.args()
+
+	if optUnused.Bool && !optConsumers.IsSet {
+		ggFatal("Pruning unused packages needs the projects using the vendor root. Please specify --consumers.")
+	}
+
+	vendorFilename, currentGgv, err := resolveVendorConfigFilename(optVendorRoot.String, optVendorRoot.IsSet)
+	if err != nil {
+		ggFatal("Unable to get vendor file %s", err)
+	}
+	vendorDir := filepath.Dir(vendorFilename)
+	vendorRoot := currentGgv.VendorPrefix
+
+	if len(optPackages) == 0 {
+		for p, info := range currentGgv.Packages {
+			if info.Vcs != "manual" {
+				optPackages = append(optPackages, p)
+			}
+		}
+		sort.Strings(optPackages)
+	}
+
+	for _, p := range optPackages {
+		if currentGgv.Packages[p] == nil {
+			ggFatal("Specified package %s does not exist. vadd it first.", p)
+		}
+	}
+
+	if optOff.Bool {
+		for _, p := range optPackages {
+			currentGgv.Packages[p].Prune = nil
+			fmt.Printf("Prune off %s\n", p)
+		}
+		if optTest.Bool {
+			fmt.Printf("Dry run. Exiting with no errors.\n")
+			return
+		}
+		err = currentGgv.saveGvv(vendorFilename)
+		if err != nil {
+			ggFatal("%s", err)
+		}
+		return
+	}
+
+	graph := importGraph{}
+	if optUnused.Bool {
+		graph, err = cmd.vendorImportGraph(vendorDir, currentGgv, false, true)
+		if err != nil {
+			ggFatal("Unable to read vendored packages %s", err)
+		}
+		consumersGraph, err := cmd.consumersImportGraph(optConsumers.String, vendorRoot, false, true)
+		if err != nil {
+			ggFatal("Unable to read consumers %s", err)
+		}
+		cmd.mergeImportGraph(graph, consumersGraph, vendorRoot, true)
+	}
+
+	for _, p := range optPackages {
+		prune := &ggvPrune{
+			UnusedPackages: optUnused.Bool,
+			Tests:          optTests.Bool,
+			Testdata:       optTestdata.Bool,
+			NonGo:          optNonGo.Bool,
+		}
+		if prune.UnusedPackages {
+			prune.Used = cmd.pruneUsedPackages(graph, p)
+		}
+
+		removed, err := cmd.prunePackageDir(filepath.Join(vendorDir, p), p, vendorRoot, prune, nil, optTest.Bool)
+		if err != nil {
+			ggFatal("Unable to prune package %s %s", p, err)
+		}
+		for _, f := range removed {
+			if optTest.Bool {
+				fmt.Printf("  %s/%s\n", p, f)
+			} else {
+				gglog.Printf("Removed %s/%s\n", p, f)
+			}
+		}
+		fmt.Printf("Pruned %s - %d removed\n", p, len(removed))
+
+		currentGgv.Packages[p].Prune = prune
+		if !optTest.Bool {
+			currentGgv.Packages[p].TreeHash, err = vendorTreeHash(filepath.Join(vendorDir, p))
+			if err != nil {
+				ggFatal("Unable to hash package %s %s", p, err)
+			}
+		}
+	}
+
+	if optTest.Bool {
+		fmt.Printf("Dry run. Exiting with no errors.\n")
+		return
+	}
+
+	err = currentGgv.saveGvv(vendorFilename)
+	if err != nil {
+		ggFatal("%s", err)
+	}
+}
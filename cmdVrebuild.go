@@ -1,6 +1,7 @@
 package main
 
 import (
+	"fmt"
 	"path/filepath"
 )
 
@@ -9,6 +10,7 @@ func (cmd *ggcmd) cmdVrebuild() {
 	options := argOptions{}
 	options.init("vrebuild")
 	options.stringVar(&optVendorRoot, "v", "", "Vendor package root")
+	options.recordVars()
 	options.parse()
 
 	// maybe in future allow rebuilding of specific packages
@@ -24,7 +26,9 @@ func (cmd *ggcmd) cmdVrebuild() {
 	if err != nil {
 		ggFatal("Unable to get vendor file %s", err)
 	}
+	cmd.loadUrlRewrites(currentGgv)
 	vendorDir := filepath.Dir(vendorFilename)
+	cmd.loadOfflineVendor(vendorDir, currentGgv)
 	vendorRoot := currentGgv.VendorPrefix
 
 	updatedPackages := map[string]*ggvPackage{}
@@ -32,22 +36,40 @@ func (cmd *ggcmd) cmdVrebuild() {
 		if currentPackageInfo.Vcs == "manual" {
 			continue
 		}
+		// revisions only from the lock file, never the latest
+		if currentGgv.split && currentPackageInfo.Revision == "" {
+			ggFatal("%s has no revision in %s. Please vupdate it first.", pkgName, ggvLockFilename(vendorFilename))
+		}
 
-		var newPackageInfo *ggvPackage = &ggvPackage{LastUpdate: getNowStr()}
-		newPackageInfo.LastUpdate = currentPackageInfo.LastUpdate
-		newPackageInfo.Vcs = currentPackageInfo.Vcs
-		newPackageInfo.VcsSource = currentPackageInfo.VcsSource
-		newPackageInfo.Revision = currentPackageInfo.Revision
-		newPackageInfo.Lock = currentPackageInfo.Lock
-		newPackageInfo.RewriteImports = currentPackageInfo.RewriteImports
-		newPackageInfo.ShallowUpdate = currentPackageInfo.ShallowUpdate
-		newPackageInfo.SaveRepo = currentPackageInfo.SaveRepo
-		newPackageInfo.DepTests = currentPackageInfo.DepTests
-		newPackageInfo.Notes = currentPackageInfo.Notes
-
-		updatedPackages[pkgName] = newPackageInfo
+		updatedPackages[pkgName] = currentPackageInfo.refetch(true)
 	}
 
 	err = cmd.downloadUpdate(vendorDir, vendorRoot, updatedPackages, false)
-	// _ggv.json stays the same of course
+
+	if structuredOutput() {
+		printRecords(packageChanges(currentGgv.Packages, updatedPackages, "rebuilt"))
+	}
+
+	// the settings stay the same of course, but hashes and revisions not
+	// recorded before are now
+	recorded := 0
+	for pkgName, newPackageInfo := range updatedPackages {
+		currentPackageInfo := currentGgv.Packages[pkgName]
+		if *newPackageInfo.locked() != *currentPackageInfo.locked() {
+			currentPackageInfo.setLocked(newPackageInfo.locked())
+			recorded++
+		}
+	}
+	if recorded == 0 {
+		return
+	}
+	err = currentGgv.saveGvv(vendorFilename)
+	if err != nil {
+		ggFatal("%s", err)
+	}
+	savedFilename := vendorFilename
+	if currentGgv.split {
+		savedFilename = ggvLockFilename(vendorFilename)
+	}
+	fmt.Fprintf(ggMessagesOut, "Recorded revisions and hashes of %d packages in %s.\n", recorded, savedFilename)
 }
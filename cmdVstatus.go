@@ -0,0 +1,346 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// one package of vstatus, -1 for what could not be found out
+// Local is clean, modified, missing or unknown (no TreeHash recorded)
+type vstatusRecord struct {
+	Package        string
+	Vcs            string
+	Revision       string
+	Lock           bool
+	LastUpdate     string
+	AgeDays        int
+	Behind         int    // commits behind the default branch upstream
+	BehindNote     string `json:",omitempty"` // why Behind is -1, e.g. no mirror
+	Local          string
+	RewriteImports bool
+	License        string
+	LicenseFlag    string   `json:",omitempty"`
+	Advisories     []string `json:",omitempty"` // id and severity
+	Consumers      int      // packages importing it
+	Problems       []string `json:",omitempty"` // exit 1
+	Warnings       []string `json:",omitempty"` // exit 2
+}
+
+func (cmd *ggcmd) cmdVstatus() {
+	var optVendorRoot argOptionStr
+	var optConsumers argOptionStr
+	var optDepTests argOptionBool
+	var optRefresh argOptionBool
+	var optDb argOptionStr
+	var optFailOn argOptionStr
+	var optMaxAge argOptionStr
+
+	options := argOptions{}
+	options.init("vstatus")
+	options.stringVar(&optVendorRoot, "v", "", "Vendor package root")
+	options.stringVar(&optVendorRoot, "vendor", "", "Vendor package root")
+	options.stringVar(&optConsumers, "c", "", "Comma separated directories of projects using the vendor root")
+	options.stringVar(&optConsumers, "consumers", "", "Comma separated directories of projects using the vendor root")
+	options.boolVar(&optDepTests, "dep-tests", false, "Also count imports of tests")
+	options.boolVar(&optRefresh, "refresh", false, "Pull latest changes into the local mirrors, clone missing ones")
+	options.stringVar(&optDb, "db", os.Getenv("GG_ADVISORY_DB"), "OSV advisory directory, .zip or .tar.gz")
+	options.stringVar(&optFailOn, "fail-on", "high", "low, medium, high, critical")
+	options.stringVar(&optMaxAge, "max-age", "", "Warn about packages not updated for this many days")
+	options.recordVars()
+	options.parse()
+	optPackages := options.args()
+
+	failRank := auditSeverityRank[strings.ToUpper(optFailOn.String)]
+	if failRank == 0 {
+		ggFatal("Unknown severity %s, use low, medium, high or critical.", optFailOn.String)
+	}
+	maxAge := 0
+	if optMaxAge.IsSet {
+		var err error
+		maxAge, err = strconv.Atoi(optMaxAge.String)
+		if err != nil || maxAge <= 0 {
+			ggFatal("Bad --max-age %s, expected a number of days.", optMaxAge.String)
+		}
+	}
+
+	vendorFilename, currentGgv, err := resolveVendorConfigFilename(optVendorRoot.String, optVendorRoot.IsSet)
+	if err != nil {
+		ggFatal("Unable to get vendor file %s", err)
+	}
+	cmd.loadUrlRewrites(currentGgv)
+	vendorDir := filepath.Dir(vendorFilename)
+
+	if len(optPackages) == 0 {
+		for p := range currentGgv.Packages {
+			optPackages = append(optPackages, p)
+		}
+		sort.Strings(optPackages)
+	}
+	for _, p := range optPackages {
+		if currentGgv.Packages[p] == nil {
+			ggFatal("Specified package %s does not exist. vadd it first.", p)
+		}
+	}
+
+	// who imports what, vendored packages and the given projects
+	graph, err := cmd.vendorImportGraph(vendorDir, currentGgv, optDepTests.Bool, true)
+	if err != nil {
+		ggFatal("Unable to read vendored packages %s", err)
+	}
+	if optConsumers.IsSet {
+		consumersGraph, err := cmd.consumersImportGraph(optConsumers.String, currentGgv.VendorPrefix, optDepTests.Bool, true)
+		if err != nil {
+			ggFatal("Unable to read consumers %s", err)
+		}
+		cmd.mergeImportGraph(graph, consumersGraph, currentGgv.VendorPrefix, true)
+	}
+	rgraph := graph.reverse()
+
+	findings := map[string][]auditFinding{}
+	if optDb.String != "" {
+		advisories, err := loadOsvDatabase(optDb.String)
+		if err != nil {
+			ggFatal("Unable to read advisory database %s", err)
+		}
+		for _, finding := range cmd.auditPackages(currentGgv, optPackages, advisories, optRefresh.Bool) {
+			findings[finding.Package] = append(findings[finding.Package], finding)
+		}
+	}
+
+	now := time.Now()
+	records := []vstatusRecord{}
+	for _, p := range optPackages {
+		info := currentGgv.Packages[p]
+		behind, behindNote := cmd.vstatusBehind(p, info, optRefresh.Bool)
+		record := vstatusRecord{
+			Package:        p,
+			Vcs:            info.Vcs,
+			Revision:       info.Revision,
+			Lock:           info.Lock,
+			LastUpdate:     info.LastUpdate,
+			AgeDays:        vstatusAgeDays(info.LastUpdate, now),
+			Behind:         behind,
+			BehindNote:     behindNote,
+			Local:          vstatusLocal(filepath.Join(vendorDir, p), info),
+			RewriteImports: info.RewriteImports,
+			License:        info.License,
+			Consumers:      vstatusConsumers(rgraph, p),
+		}
+
+		switch record.Local {
+		case "missing", "modified":
+			record.Problems = append(record.Problems, record.Local)
+		}
+		// only what vlicenses found, nothing recorded is not a finding
+		if info.License != "" {
+			record.LicenseFlag = licensePolicyFlag(info.License, currentGgv.LicensePolicy)
+			if record.LicenseFlag != "" {
+				record.Problems = append(record.Problems, "license: "+record.LicenseFlag)
+			}
+		}
+		for _, finding := range findings[p] {
+			record.Advisories = append(record.Advisories, finding.Id+" "+finding.Severity)
+			if auditSeverityRank[finding.Severity] >= failRank {
+				record.Problems = append(record.Problems, "advisory: "+finding.Id+" "+finding.Severity)
+			} else {
+				record.Warnings = append(record.Warnings, "advisory: "+finding.Id+" "+finding.Severity)
+			}
+		}
+		if record.Behind > 0 {
+			record.Warnings = append(record.Warnings, fmt.Sprintf("%d behind", record.Behind))
+		}
+		if maxAge > 0 && record.AgeDays > maxAge {
+			record.Warnings = append(record.Warnings, fmt.Sprintf("not updated for %d days", record.AgeDays))
+		}
+
+		records = append(records, record)
+	}
+
+	if structuredOutput() {
+		printRecords(records)
+	} else {
+		vstatusPrintTable(records)
+	}
+
+	summary, problems, warnings := vstatusSummary(records)
+	fmt.Fprintf(ggMessagesOut, "%s\n", summary)
+	if problems > 0 {
+		ggExit(1)
+	}
+	if warnings > 0 {
+		ggExit(2)
+	}
+}
+
+// whole days since LastUpdate, -1 if not recorded
+func vstatusAgeDays(lastUpdate string, now time.Time) int {
+	t, err := time.ParseInLocation("2006-01-02T15:04:05", lastUpdate, time.Local)
+	if err != nil {
+		return -1
+	}
+	return int(now.Sub(t).Hours() / 24)
+}
+
+// commits between the vendored revision and upstream head, from the mirror
+// under GGHOME, or -1 and why not
+func (cmd *ggcmd) vstatusBehind(p string, info *ggvPackage, refresh bool) (int, string) {
+	if info.Vcs != "git" && info.Vcs != "hg" {
+		return -1, "not a repo"
+	}
+	if info.Revision == "" {
+		return -1, "no revision"
+	}
+
+	mirrorDir := mirrorDirFor(info.Vcs, info.VcsSource)
+	if refresh {
+		var err error
+		mirrorDir, err = cmd.mirrorRepo(info, true)
+		if err != nil {
+			fmt.Fprintf(ggMessagesOut, "Unable to refresh %s. %s\n", p, err)
+			return -1, "refresh failed"
+		}
+	} else if _, err := os.Stat(mirrorDir); err != nil {
+		return -1, "no mirror"
+	}
+
+	head, err := mirrorHeadRevision(info.Vcs, mirrorDir)
+	if err != nil {
+		return -1, "no head"
+	}
+	linear, err := mirrorIsAncestor(info.Vcs, mirrorDir, info.Revision, head)
+	if err != nil || !linear {
+		return -1, "not linear"
+	}
+	entries, err := mirrorLog(info.Vcs, mirrorDir, info.Revision, head)
+	if err != nil {
+		return -1, "no log"
+	}
+	return len(entries), ""
+}
+
+// on disk against the TreeHash recorded when gg last wrote it
+func vstatusLocal(pkgDir string, info *ggvPackage) string {
+	if !vcheckHasFiles(pkgDir) {
+		return "missing"
+	}
+	if info.TreeHash == "" {
+		return "unknown"
+	}
+	hash, err := vendorTreeHash(pkgDir)
+	if err != nil {
+		gglog.Printf("vstatusLocal %s %v\n", pkgDir, err)
+		return "unknown"
+	}
+	if hash != info.TreeHash {
+		return "modified"
+	}
+	return "clean"
+}
+
+// packages outside of p importing any package of p
+func vstatusConsumers(rgraph importGraph, p string) int {
+	importers := map[string]bool{}
+	for pkg, pkgImporters := range rgraph {
+		if !metaPrefixMatches(p, pkg) {
+			continue
+		}
+		for _, importer := range pkgImporters {
+			if !metaPrefixMatches(p, importer) {
+				importers[importer] = true
+			}
+		}
+	}
+	return len(importers)
+}
+
+func vstatusPrintTable(records []vstatusRecord) {
+	unknown := func(n int, suffix string) string {
+		if n < 0 {
+			return "?"
+		}
+		return strconv.Itoa(n) + suffix
+	}
+	orDash := func(s string) string {
+		if s == "" {
+			return "-"
+		}
+		return s
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(w, "PACKAGE\tLOCK\tAGE\tBEHIND\tLOCAL\tREWRITE\tCONSUMERS\tLICENSE\tADVISORIES\n")
+	for _, record := range records {
+		lock := "-"
+		if record.Lock {
+			lock = "locked"
+		}
+		rewrite := "on"
+		if !record.RewriteImports {
+			rewrite = "off"
+		}
+		license := orDash(record.License)
+		if record.LicenseFlag != "" {
+			license += " (" + record.LicenseFlag + ")"
+		}
+		behind := unknown(record.Behind, "")
+		if record.BehindNote == "no mirror" {
+			behind = "no mirror"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%d\t%s\t%s\n", record.Package, lock, unknown(record.AgeDays, "d"),
+			behind, record.Local, rewrite, record.Consumers, license, orDash(strings.Join(record.Advisories, ", ")))
+	}
+	w.Flush()
+}
+
+// summary line, packages with problems, packages with only warnings
+func vstatusSummary(records []vstatusRecord) (string, int, int) {
+	var locked, behind, noMirror, modified, missing, flagged, advised, problems, warnings int
+	for _, record := range records {
+		if record.Lock {
+			locked++
+		}
+		if record.Behind > 0 {
+			behind++
+		}
+		if record.BehindNote == "no mirror" {
+			noMirror++
+		}
+		switch record.Local {
+		case "modified":
+			modified++
+		case "missing":
+			missing++
+		}
+		if record.LicenseFlag != "" {
+			flagged++
+		}
+		if len(record.Advisories) > 0 {
+			advised++
+		}
+		if len(record.Problems) > 0 {
+			problems++
+		} else if len(record.Warnings) > 0 {
+			warnings++
+		}
+	}
+
+	summary := fmt.Sprintf("%d packages, %d locked, %d behind, %d modified, %d missing, %d license flagged, %d with advisories.",
+		len(records), locked, behind, modified, missing, flagged, advised)
+	if noMirror > 0 {
+		summary += fmt.Sprintf(" %d without a mirror, run with --refresh to count commits behind.", noMirror)
+	}
+	if problems > 0 {
+		summary += fmt.Sprintf(" %d with problems.", problems)
+	} else if warnings > 0 {
+		summary += fmt.Sprintf(" %d with warnings.", warnings)
+	} else {
+		summary += " All good."
+	}
+	return summary, problems, warnings
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestVstatusBehindNotes(t *testing.T) {
+	testAuthEnv(t, "")
+	cmd := &ggcmd{config: &ggConfig{}}
+	tests := []struct {
+		info *ggvPackage
+		note string
+	}{
+		{&ggvPackage{Vcs: "proxy", VcsSource: "https://proxy.example.com/example.com/a", Revision: "v1.0.0"}, "not a repo"},
+		{&ggvPackage{Vcs: "git", VcsSource: "https://git.example.com/a"}, "no revision"},
+		{&ggvPackage{Vcs: "git", VcsSource: "https://git.example.com/a", Revision: "abc"}, "no mirror"},
+	}
+	for _, test := range tests {
+		behind, note := cmd.vstatusBehind("example.com/a", test.info, false)
+		if behind != -1 || note != test.note {
+			t.Errorf("vstatusBehind %s %s = %d, %q, want -1, %q", test.info.Vcs, test.info.Revision, behind, note, test.note)
+		}
+	}
+}
+
+func TestVstatusSummary(t *testing.T) {
+	records := []vstatusRecord{
+		{Package: "example.com/a", Lock: true, Behind: 3, Local: "clean", Warnings: []string{"3 behind"}},
+		{Package: "example.com/b", Behind: -1, BehindNote: "no mirror", Local: "modified", Problems: []string{"modified"}},
+		{Package: "example.com/c", Behind: -1, BehindNote: "not linear", Local: "clean"},
+	}
+	summary, problems, warnings := vstatusSummary(records)
+	if problems != 1 || warnings != 1 {
+		t.Errorf("vstatusSummary %d problems %d warnings, want 1 and 1", problems, warnings)
+	}
+	for _, want := range []string{"3 packages, 1 locked, 1 behind, 1 modified", "1 without a mirror, run with --refresh", "1 with problems."} {
+		if !strings.Contains(summary, want) {
+			t.Errorf("vstatusSummary = %q, want %q in it", summary, want)
+		}
+	}
+
+	summary, problems, warnings = vstatusSummary(records[2:])
+	if problems != 0 || warnings != 0 || strings.Contains(summary, "mirror") || !strings.HasSuffix(summary, "All good.") {
+		t.Errorf("vstatusSummary of a clean package = %q, %d, %d", summary, problems, warnings)
+	}
+}
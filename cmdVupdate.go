@@ -12,6 +12,9 @@ func (cmd *ggcmd) cmdVupdate() {
 	var optDepTests argOptionBool
 	var optRevision argOptionStr
 	var optTest argOptionBool
+	var optRefresh argOptionBool
+	var optPlatforms argOptionStr
+	var optTags argOptionStr
 
 	options := argOptions{}
 	options.init("vadd")
@@ -22,7 +25,11 @@ func (cmd *ggcmd) cmdVupdate() {
 	options.boolVar(&optDepTests, "dep-tests", true, "Also check dependencies of tests")
 	options.stringVar(&optRevision, "revision", "", "source control revision hash")
 	options.boolVar(&optTest, "test", false, "Just test to see what will change.")
+	options.boolVar(&optRefresh, "refresh", false, "Look up package meta again instead of using the cache")
+	options.stringVar(&optPlatforms, "platforms", "", "Comma separated GOOS/GOARCH, saved in _ggv.json")
+	options.stringVar(&optTags, "tags", "", "Comma separated build tags, saved in _ggv.json")
 
+	options.recordVars()
 	options.parse()
 	optPackages := options.args()
 
@@ -40,7 +47,18 @@ func (cmd *ggcmd) cmdVupdate() {
 	if err != nil {
 		ggFatal("Unable to get vendor file %s", err)
 	}
+	cmd.loadUrlRewrites(currentGgv)
+	cmd.metaRefresh = optRefresh.Bool
+	cmd.setDepPlatforms(optPlatforms, optTags, currentGgv)
+	if optPlatforms.IsSet {
+		currentGgv.Platforms = splitCommaList(optPlatforms.String)
+	}
+	if optTags.IsSet {
+		currentGgv.BuildTags = splitCommaList(optTags.String)
+	}
 	vendorDir := filepath.Dir(vendorFilename)
+	cmd.loadOfflineVendor(vendorDir, currentGgv)
+	cmd.loadImportMaps(vendorDir)
 	vendorRoot := currentGgv.VendorPrefix
 
 	// make sure specified package(s) exist
@@ -55,9 +73,9 @@ func (cmd *ggcmd) cmdVupdate() {
 		for p, _ := range currentGgv.Packages {
 			optPackages = append(optPackages, p)
 		}
-		todoPackages = cmd.getMinimalPackagesList(optPackages, optShallow.Bool, true, currentGgv.Packages)
+		todoPackages = cmd.getMinimalPackagesList(optPackages, optShallow.Bool, true, currentGgv.Packages, nil)
 	} else {
-		todoPackages = cmd.getMinimalPackagesList(optPackages, optShallow.Bool, true, currentGgv.Packages)
+		todoPackages = cmd.getMinimalPackagesList(optPackages, optShallow.Bool, true, currentGgv.Packages, nil)
 	}
 
 	gglog.Printf("todoPackages: %v\n", todoPackages)
@@ -88,22 +106,13 @@ func (cmd *ggcmd) cmdVupdate() {
 			if newPackageInfo.VcsSource == "" {
 				newPackageInfo.VcsSource = goGetInfo[2]
 			}
+
+			if len(goGetInfo) > 3 {
+				newPackageInfo.Subdir = goGetInfo[3]
+			}
 		} else {
 			// existing package; may get updated as side effect
-			newPackageInfo.LastUpdate = currentPackageInfo.LastUpdate
-			newPackageInfo.Vcs = currentPackageInfo.Vcs
-			newPackageInfo.VcsSource = currentPackageInfo.VcsSource
-			if currentPackageInfo.Lock {
-				newPackageInfo.Revision = currentPackageInfo.Revision
-			} else {
-				newPackageInfo.Revision = ""
-			}
-			newPackageInfo.Lock = currentPackageInfo.Lock
-			newPackageInfo.RewriteImports = currentPackageInfo.RewriteImports
-			newPackageInfo.ShallowUpdate = currentPackageInfo.ShallowUpdate
-			newPackageInfo.SaveRepo = currentPackageInfo.SaveRepo
-			newPackageInfo.DepTests = currentPackageInfo.DepTests
-			newPackageInfo.Notes = currentPackageInfo.Notes
+			newPackageInfo = currentPackageInfo.refetch(currentPackageInfo.Lock)
 		}
 
 		// skip manual packages
@@ -114,19 +123,10 @@ func (cmd *ggcmd) cmdVupdate() {
 
 	err = cmd.downloadUpdate(vendorDir, vendorRoot, updatedPackages, optTest.Bool)
 
-	for pkg, pkgInfo := range updatedPackages {
-		var oldInfo *ggvPackage = currentGgv.Packages[pkg]
-		if oldInfo == nil {
-			fmt.Printf("Added %s - %s %s - %s\n", pkg, pkgInfo.Vcs, pkgInfo.VcsSource, pkgInfo.Revision)
-		} else {
-			if pkgInfo.Revision != oldInfo.Revision {
-				fmt.Printf("Updated %s - %s %s - %s to %s\n", pkg, pkgInfo.Vcs, pkgInfo.VcsSource, oldInfo.Revision, pkgInfo.Revision)
-			}
-		}
-	}
+	printChanges(packageChanges(currentGgv.Packages, updatedPackages, ""))
 
 	if optTest.Bool {
-		fmt.Printf("Dry run. Exiting with no errors.\n")
+		fmt.Fprintf(ggMessagesOut, "Dry run. Exiting with no errors.\n")
 		return
 	}
 
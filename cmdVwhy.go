@@ -0,0 +1,141 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+func (cmd *ggcmd) cmdVwhy() {
+	var optVendorRoot argOptionStr
+	var optConsumers argOptionStr
+	var optDepTests argOptionBool
+
+	options := argOptions{}
+	options.init("vwhy")
+	options.stringVar(&optVendorRoot, "v", "", "Vendor package root")
+	options.stringVar(&optVendorRoot, "vendor", "", "Vendor package root")
+	options.stringVar(&optConsumers, "c", "", "Comma separated directories of projects using the vendor root")
+	options.stringVar(&optConsumers, "consumers", "", "Comma separated directories of projects using the vendor root")
+	options.boolVar(&optDepTests, "dep-tests", false, "Also follow imports of tests")
+	options.parse()
+	optPackages := options.args()
+
+	if len(optPackages) != 1 {
+		ggFatal("Please specify exactly one package.")
+	}
+	target := optPackages[0]
+
+	vendorFilename, currentGgv, err := resolveVendorConfigFilename(optVendorRoot.String, optVendorRoot.IsSet)
+	if err != nil {
+		ggFatal("Unable to get vendor file %s", err)
+	}
+	vendorDir := filepath.Dir(vendorFilename)
+
+	graph, err := cmd.vendorImportGraph(vendorDir, currentGgv, optDepTests.Bool, true)
+	if err != nil {
+		ggFatal("Unable to read vendored packages %s", err)
+	}
+	if optConsumers.IsSet {
+		consumersGraph, err := cmd.consumersImportGraph(optConsumers.String, currentGgv.VendorPrefix, optDepTests.Bool, true)
+		if err != nil {
+			ggFatal("Unable to read consumers %s", err)
+		}
+		cmd.mergeImportGraph(graph, consumersGraph, currentGgv.VendorPrefix, true)
+	}
+
+	chains := cmd.vwhyChains(graph, target)
+	if len(chains) == 0 {
+		fmt.Printf("Nothing depends on %s.\n", target)
+		return
+	}
+
+	fmt.Printf("%s is imported through:\n", target)
+	for _, chain := range chains {
+		fmt.Printf("  %s\n", strings.Join(chain, " -> "))
+	}
+}
+
+// shortest chains from every root (a package nothing imports) to target,
+// shortest first. Imports from within target itself are not followed. An
+// import cycle nothing outside of it imports has no such root, the member
+// farthest from target stands in for it.
+func (cmd *ggcmd) vwhyChains(graph importGraph, target string) [][]string {
+	inTarget := func(p string) bool {
+		return p == target || strings.HasPrefix(p, target+"/")
+	}
+
+	rgraph := graph.reverse()
+
+	// breadth first from target towards importers, next is one hop closer
+	next := map[string]string{}
+	var queue []string
+	for pkg := range rgraph {
+		if inTarget(pkg) {
+			next[pkg] = ""
+			queue = append(queue, pkg)
+		}
+	}
+	sort.Strings(queue)
+
+	var roots []string
+	var order []string
+	for len(queue) > 0 {
+		pkg := queue[0]
+		queue = queue[1:]
+		order = append(order, pkg)
+
+		importers := 0
+		for _, importer := range rgraph[pkg] {
+			if inTarget(importer) {
+				continue
+			}
+			importers++
+			if _, seen := next[importer]; seen {
+				continue
+			}
+			next[importer] = pkg
+			queue = append(queue, importer)
+		}
+
+		if importers == 0 && !inTarget(pkg) {
+			roots = append(roots, pkg)
+		}
+	}
+
+	// what the roots import on their way to target, along graph
+	reached := map[string]bool{}
+	var reach func(pkg string)
+	reach = func(pkg string) {
+		if _, seen := next[pkg]; !seen || reached[pkg] || inTarget(pkg) {
+			return
+		}
+		reached[pkg] = true
+		for _, imp := range graph[pkg] {
+			reach(imp)
+		}
+	}
+	for _, root := range roots {
+		reach(root)
+	}
+	for i := len(order) - 1; i >= 0; i-- {
+		if pkg := order[i]; !reached[pkg] && !inTarget(pkg) {
+			roots = append(roots, pkg)
+			reach(pkg)
+		}
+	}
+
+	var chains [][]string
+	for _, root := range roots {
+		chain := []string{}
+		for pkg := root; pkg != ""; pkg = next[pkg] {
+			chain = append(chain, pkg)
+		}
+		chains = append(chains, chain)
+	}
+	sort.SliceStable(chains, func(i, j int) bool {
+		return len(chains[i]) < len(chains[j])
+	})
+	return chains
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestVwhyChains(t *testing.T) {
+	tests := []struct {
+		name   string
+		graph  importGraph
+		target string
+		chains [][]string
+	}{
+		{
+			name: "roots",
+			graph: importGraph{
+				"app/cmd":  {"app/lib", "x.org/a"},
+				"app/lib":  {"x.org/a/sub"},
+				"x.org/a":  {"x.org/b"},
+				"app/tool": {"x.org/c"},
+			},
+			target: "x.org/a",
+			chains: [][]string{
+				{"app/cmd", "x.org/a"},
+			},
+		},
+		{
+			name: "several roots, shortest first",
+			graph: importGraph{
+				"app/one": {"app/mid"},
+				"app/mid": {"x.org/a"},
+				"app/two": {"x.org/a"},
+			},
+			target: "x.org/a",
+			chains: [][]string{
+				{"app/two", "x.org/a"},
+				{"app/one", "app/mid", "x.org/a"},
+			},
+		},
+		{
+			name: "cycle nothing else imports",
+			graph: importGraph{
+				"x.org/b": {"x.org/c"},
+				"x.org/c": {"x.org/b", "x.org/a"},
+			},
+			target: "x.org/a",
+			chains: [][]string{
+				{"x.org/b", "x.org/c", "x.org/a"},
+			},
+		},
+		{
+			name: "cycle below a root",
+			graph: importGraph{
+				"app/cmd": {"x.org/b"},
+				"x.org/b": {"x.org/c"},
+				"x.org/c": {"x.org/b", "x.org/a"},
+			},
+			target: "x.org/a",
+			chains: [][]string{
+				{"app/cmd", "x.org/b", "x.org/c", "x.org/a"},
+			},
+		},
+		{
+			name: "imports within target",
+			graph: importGraph{
+				"x.org/a":     {"x.org/a/sub"},
+				"x.org/a/sub": {"x.org/a"},
+			},
+			target: "x.org/a",
+			chains: nil,
+		},
+	}
+
+	cmd := &ggcmd{}
+	for _, test := range tests {
+		chains := cmd.vwhyChains(test.graph, test.target)
+		if !reflect.DeepEqual(chains, test.chains) {
+			t.Errorf("%s: vwhyChains = %v, want %v", test.name, chains, test.chains)
+		}
+	}
+}
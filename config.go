@@ -0,0 +1,52 @@
+package main
+
+//
+// user level settings, GGHOME/config.json
+//
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+type ggConfig struct {
+	UrlRewrites []*ggvUrlRewrite `json:",omitempty"` // applied before the vendor root rules
+	Auth        []*ggAuth        `json:",omitempty"` // tokens and ssh preference per host
+	KnownHosts  []*ggKnownHost   `json:",omitempty"` // repo roots without the meta lookup
+}
+
+func ggConfigFilename() string {
+	return filepath.Join(ggHomeDir(), "config.json")
+}
+
+// a missing file is an empty config
+func readGgConfig(filename string) (*ggConfig, error) {
+	config := &ggConfig{}
+	content, err := ioutil.ReadFile(filename)
+	if os.IsNotExist(err) {
+		return config, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	err = json.Unmarshal(content, config)
+	if err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// loaded once per run
+func (cmd *ggcmd) userConfig() *ggConfig {
+	if cmd.config == nil {
+		config, err := readGgConfig(ggConfigFilename())
+		if err != nil {
+			ggFatal("Unable to read %s %s", ggConfigFilename(), err)
+		}
+		cmd.config = config
+	}
+	return cmd.config
+}
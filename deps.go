@@ -0,0 +1,389 @@
+package main
+
+//
+// dependencies read from the fetched source trees, at the revisions being
+// vendored, repos fetched the same way downloadPkg does
+//
+
+import (
+	"fmt"
+	"go/build"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// a fetched tree, before any rewrite, Err if fetching failed
+type fetchedTree struct {
+	Dir      string
+	Revision string
+	ZipHash  string
+	Vendored bool // offline copy of the vendor tree, already rewritten
+	Err      error
+}
+
+// what makes two fetches of p give the same tree
+func fetchedTreeKey(p string, info *ggvPackage) string {
+	return strings.Join([]string{p, info.Vcs, info.VcsSource, info.Revision, info.Subdir, strconv.FormatBool(info.SaveRepo)}, " ")
+}
+
+// fetched once per run, downloadPkg takes it over later
+func (cmd *ggcmd) analysisTree(p string, info *ggvPackage) *fetchedTree {
+	if cmd.fetchedTrees == nil {
+		cmd.fetchedTrees = map[string]*fetchedTree{}
+		ggAtExit(cmd.removeFetchedTrees)
+	}
+	key := fetchedTreeKey(p, info)
+	if cmd.fetchedTrees[key] == nil {
+		tree := &fetchedTree{}
+		if dir, vendored := cmd.offlineVendoredTree(p, info, true); dir != "" {
+			tree.Dir, tree.Revision, tree.ZipHash, tree.Vendored = dir, vendored.Revision, vendored.ZipHash, true
+		} else {
+			tree.Dir, tree.Revision, tree.ZipHash, tree.Err = cmd.fetchPkgTree(p, info)
+		}
+		cmd.fetchedTrees[key] = tree
+	}
+	return cmd.fetchedTrees[key]
+}
+
+// nil if p was not fetched with the same info, or the vendor tree stood in
+// for another revision than info asks for
+func (cmd *ggcmd) takeFetchedTree(p string, info *ggvPackage) *fetchedTree {
+	key := fetchedTreeKey(p, info)
+	tree := cmd.fetchedTrees[key]
+	if tree == nil || tree.Err != nil || (tree.Vendored && info.Revision != tree.Revision) {
+		return nil
+	}
+	delete(cmd.fetchedTrees, key)
+	return tree
+}
+
+func (cmd *ggcmd) removeFetchedTrees() {
+	for _, tree := range cmd.fetchedTrees {
+		if tree.Dir != "" {
+			os.RemoveAll(tree.Dir)
+		}
+	}
+	cmd.fetchedTrees = nil
+}
+
+type depAnalysis struct {
+	cmd       *ggcmd
+	knownPkgs map[string]*ggvPackage
+
+	Repos   map[string]*ggvPackage     // repo root -> how it is fetched
+	Graph   importGraph                // non core packages and their non core imports
+	Needs   map[string]map[string]bool // package -> platforms it is needed on
+	Failed  map[string]bool            // dependency repos that could not be fetched
+	Offline map[string]bool            // packages and repos offline could not find
+
+	imports  map[string]map[string]map[string]bool // package -> import -> platforms
+	starts   map[string]bool                       // repos of the packages asked for
+	tests    map[string]bool                       // packages read with their tests
+	reported map[string]bool
+}
+
+func (cmd *ggcmd) newDepAnalysis(knownPkgs map[string]*ggvPackage) *depAnalysis {
+	return &depAnalysis{
+		cmd:       cmd,
+		knownPkgs: knownPkgs,
+		Repos:     map[string]*ggvPackage{},
+		Graph:     importGraph{},
+		Needs:     map[string]map[string]bool{},
+		Failed:    map[string]bool{},
+		Offline:   map[string]bool{},
+		imports:   map[string]map[string]map[string]bool{},
+		starts:    map[string]bool{},
+		tests:     map[string]bool{},
+		reported:  map[string]bool{},
+	}
+}
+
+// longest of roots that is p or a parent of p, "" if none
+func longestRootFor(p string, roots map[string]*ggvPackage) string {
+	best := ""
+	for root := range roots {
+		if metaPrefixMatches(root, p) && len(root) > len(best) {
+			best = root
+		}
+	}
+	return best
+}
+
+// repo root, fetch info, error
+// repos seen before, then vendored packages, then the meta lookup
+func (analysis *depAnalysis) repoFor(p string) (string, *ggvPackage, error) {
+	if root := longestRootFor(p, analysis.Repos); root != "" {
+		return root, analysis.Repos[root], nil
+	}
+
+	if root := longestRootFor(p, analysis.knownPkgs); root != "" {
+		known := analysis.knownPkgs[root]
+		info := &ggvPackage{
+			Vcs:       known.Vcs,
+			VcsSource: known.VcsSource,
+			Fallbacks: known.Fallbacks,
+			Subdir:    known.Subdir,
+			SaveRepo:  known.SaveRepo,
+			DepTests:  known.DepTests,
+			Version:   known.Version,
+			ZipHash:   known.ZipHash,
+			Include:   known.Include,
+			Exclude:   known.Exclude,
+		}
+		// as vupdate fetches it
+		if known.Lock {
+			info.Revision = known.Revision
+		}
+		analysis.Repos[root] = info
+		return root, info, nil
+	}
+
+	meta, err := analysis.cmd.getPkgMeta(p)
+	if err != nil {
+		return "", nil, err
+	}
+	info := &ggvPackage{Vcs: meta.Vcs, VcsSource: meta.RepoRoot, Subdir: meta.Subdir}
+	analysis.Repos[meta.Prefix] = info
+	return meta.Prefix, info, nil
+}
+
+// follow the imports of pkgs through every repo they lead to, for each
+// platform, a package is needed on the platforms its importers need it on
+// and import it for. With an Include, the kept packages of the repo are
+// followed instead of the one given.
+func (analysis *depAnalysis) run(pkgs []string, includeTestDeps bool, given *ggvPackage) {
+	all := map[string]bool{}
+	for _, platform := range analysis.cmd.platforms() {
+		all[platform.String()] = true
+	}
+
+	var queue []string
+	for _, p := range pkgs {
+		root, info, err := analysis.repoFor(p)
+		if err != nil {
+			analysis.unresolved(p, err)
+			continue
+		}
+		if given != nil && len(pkgs) == 1 {
+			if given.Revision != "" {
+				info.Revision = given.Revision
+			}
+			if given.hasFilter() {
+				info.Include, info.Exclude = given.Include, given.Exclude
+			}
+		}
+		tests := includeTestDeps
+		if analysis.knownPkgs != nil && analysis.knownPkgs[root] != nil {
+			tests = analysis.knownPkgs[root].DepTests
+		}
+		analysis.starts[root] = true
+
+		starts := []string{p}
+		if len(info.Include) > 0 && info.Vcs != "manual" {
+			if tree := analysis.cmd.analysisTree(root, info); tree.Err == nil {
+				starts = filterKeptPackages(tree.Dir, root, info)
+			}
+		}
+		for _, start := range starts {
+			analysis.tests[start] = tests
+			analysis.Needs[start] = map[string]bool{}
+			for name := range all {
+				analysis.Needs[start][name] = true
+			}
+			queue = append(queue, start)
+		}
+	}
+
+	for len(queue) > 0 {
+		pkg := queue[0]
+		queue = queue[1:]
+
+		imports := analysis.importsOf(pkg)
+		for _, imp := range analysis.Graph[pkg] {
+			if analysis.Needs[imp] == nil {
+				analysis.Needs[imp] = map[string]bool{}
+			}
+			added := false
+			for name := range imports[imp] {
+				if analysis.Needs[pkg][name] && !analysis.Needs[imp][name] {
+					analysis.Needs[imp][name] = true
+					added = true
+				}
+			}
+			if added {
+				queue = append(queue, imp)
+			}
+		}
+	}
+
+	// drop the edges no platform takes, e.g. windows only imports of a
+	// package only linux needs
+	for pkg, imps := range analysis.Graph {
+		var kept []string
+		for _, imp := range imps {
+			for name := range analysis.imports[pkg][imp] {
+				if analysis.Needs[pkg][name] {
+					kept = append(kept, imp)
+					break
+				}
+			}
+		}
+		analysis.Graph[pkg] = append([]string{}, kept...)
+	}
+}
+
+// import -> platforms importing it, read once per package
+func (analysis *depAnalysis) importsOf(pkg string) map[string]map[string]bool {
+	if imports, ok := analysis.imports[pkg]; ok {
+		return imports
+	}
+	imports := map[string]map[string]bool{}
+	analysis.imports[pkg] = imports
+	analysis.Graph[pkg] = []string{}
+
+	root, info, err := analysis.repoFor(pkg)
+	if err != nil {
+		analysis.unresolved(pkg, err)
+		return imports
+	}
+	if info.Vcs == "manual" {
+		return imports
+	}
+
+	tree := analysis.cmd.analysisTree(root, info)
+	if tree.Err != nil {
+		// the packages asked for fail later on, when downloaded
+		if !analysis.starts[root] {
+			analysis.Failed[root] = true
+		}
+		if _, ok := tree.Err.(*offlineError); ok {
+			analysis.Offline[root] = true
+		} else if !analysis.reported[root] {
+			fmt.Fprintf(ggMessagesOut, "Not following dependencies of %s. %s\n", root, tree.Err)
+			analysis.reported[root] = true
+		}
+		return imports
+	}
+
+	rel := strings.TrimPrefix(strings.TrimPrefix(pkg, root), "/")
+	if info.hasFilter() && !info.keepsPath(rel) {
+		fmt.Fprintf(ggMessagesOut, "Not following %s, Include and Exclude of %s leave it out.\n", pkg, root)
+		return imports
+	}
+	byPlatform, err := packageImports(filepath.Join(tree.Dir, filepath.FromSlash(rel)), analysis.tests[pkg], analysis.cmd.platforms())
+	if err != nil {
+		fmt.Fprintf(ggMessagesOut, "Unable to read imports of %s at %s. %s\n", pkg, tree.Revision, err)
+		return imports
+	}
+
+	for imp, on := range byPlatform {
+		if tree.Vendored {
+			imp = canonicalImport(analysis.cmd.offlineVendor.Prefix, imp)
+		}
+		if imp == "C" || analysis.cmd.isCorePackage(imp) {
+			continue
+		}
+		if vendored := treeVendoredImport(tree.Dir, root, rel, imp); vendored != "" {
+			imp = vendored
+		}
+		if imports[imp] == nil {
+			imports[imp] = map[string]bool{}
+			analysis.Graph[pkg] = append(analysis.Graph[pkg], imp)
+		}
+		for name := range on {
+			imports[imp][name] = true
+		}
+	}
+	sort.Strings(analysis.Graph[pkg])
+	return imports
+}
+
+// offline, what could not be found is reported all at once at the end
+func (analysis *depAnalysis) unresolved(p string, err error) {
+	if _, ok := err.(*offlineError); ok {
+		analysis.Offline[p] = true
+		return
+	}
+	fmt.Fprintf(ggMessagesOut, "Unable to resolve a package: %s. %s\n", p, err)
+}
+
+// import -> platforms importing it, of the package in dir
+func packageImports(dir string, includeTests bool, platforms []depPlatform) (map[string]map[string]bool, error) {
+	imports := map[string]map[string]bool{}
+	found := false
+	var lastErr error
+	for _, platform := range platforms {
+		ctx := platform.buildContext()
+		pkg, err := ctx.ImportDir(dir, 0)
+		if err != nil {
+			lastErr = err
+			// no files for this platform, or e.g. a broken file, go with what could be read
+			if _, ok := err.(*build.NoGoError); ok || pkg == nil || len(pkg.Imports) == 0 {
+				continue
+			}
+			gglog.Printf("packageImports %s %s %v\n", dir, platform, err)
+		}
+		found = true
+
+		platformImports := pkg.Imports
+		if includeTests {
+			platformImports = append(append(platformImports, pkg.TestImports...), pkg.XTestImports...)
+		}
+		for _, imp := range platformImports {
+			if imports[imp] == nil {
+				imports[imp] = map[string]bool{}
+			}
+			imports[imp][platform.String()] = true
+		}
+	}
+	if !found {
+		return nil, lastErr
+	}
+	return imports, nil
+}
+
+// root/a/vendor/imp when the package at root/rel would get imp from a
+// vendor directory of its own repo, otherwise ""
+func treeVendoredImport(treeDir string, root string, rel string, imp string) string {
+	for dir := rel; ; dir = filepath.ToSlash(filepath.Dir(dir)) {
+		if dir == "." {
+			dir = ""
+		}
+		vendorDir := joinImportPath(dir, "vendor")
+		stat, err := os.Stat(filepath.Join(treeDir, filepath.FromSlash(vendorDir), filepath.FromSlash(imp)))
+		if err == nil && stat.IsDir() {
+			return joinImportPath(root, vendorDir+"/"+imp)
+		}
+		if dir == "" {
+			return ""
+		}
+	}
+}
+
+// all non core packages p depends on, outside of p itself
+func (analysis *depAnalysis) depsOf(p string) []string {
+	seen := map[string]bool{}
+	var deps []string
+	add := func(pkg string) {
+		if !seen[pkg] && !metaPrefixMatches(p, pkg) {
+			seen[pkg] = true
+			deps = append(deps, pkg)
+		}
+	}
+	for pkg, imports := range analysis.Graph {
+		add(pkg)
+		for _, imp := range imports {
+			add(imp)
+		}
+	}
+	sort.Strings(deps)
+	return deps
+}
+
+// package -> platforms, for the dependencies only some platforms need
+func (analysis *depAnalysis) platformSpecific() map[string][]string {
+	return platformSpecific(analysis.Needs, analysis.cmd.platforms())
+}
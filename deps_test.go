@@ -0,0 +1,20 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDepsOf(t *testing.T) {
+	analysis := (&ggcmd{}).newDepAnalysis(nil)
+	analysis.Graph = importGraph{
+		"example.com/a":          {"example.com/a/internal", "example.com/ab", "example.com/b"},
+		"example.com/a/internal": {"example.com/c/v2"},
+		"example.com/ab":         {},
+		"example.com/b":          {"example.com/c/v2"},
+	}
+	want := []string{"example.com/ab", "example.com/b", "example.com/c/v2"}
+	if deps := analysis.depsOf("example.com/a"); !reflect.DeepEqual(deps, want) {
+		t.Errorf("depsOf = %v, want %v", deps, want)
+	}
+}
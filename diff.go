@@ -0,0 +1,408 @@
+package main
+
+//
+// line based unified diffs between files and directory trees
+//
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const (
+	diffContextLines = 3
+	diffMaxEdits     = 4000 // beyond this, just replace the whole file
+)
+
+type diffOp struct {
+	Kind byte // ' ', '-', '+'
+	Line string
+}
+
+// difference of one file between two trees
+type fileDiff struct {
+	Path      string // relative, slash separated
+	OldExists bool
+	NewExists bool
+	Binary    bool
+	Added     int
+	Removed   int
+	Ops       []diffOp
+}
+
+// split keeping the "\n" on each line, so a missing newline at the end of
+// file is a difference as well
+func diffSplitLines(content []byte) []string {
+	var lines []string
+	s := string(content)
+	for len(s) > 0 {
+		i := strings.IndexByte(s, '\n')
+		if i < 0 {
+			lines = append(lines, s)
+			break
+		}
+		lines = append(lines, s[:i+1])
+		s = s[i+1:]
+	}
+	return lines
+}
+
+func diffLines(a []string, b []string) []diffOp {
+	// common prefix and suffix do not need to go through myers
+	pre := 0
+	for pre < len(a) && pre < len(b) && a[pre] == b[pre] {
+		pre++
+	}
+	suf := 0
+	for suf < len(a)-pre && suf < len(b)-pre && a[len(a)-1-suf] == b[len(b)-1-suf] {
+		suf++
+	}
+
+	ops := make([]diffOp, 0, len(a)+len(b))
+	for i := 0; i < pre; i++ {
+		ops = append(ops, diffOp{' ', a[i]})
+	}
+	ops = append(ops, diffMyers(a[pre:len(a)-suf], b[pre:len(b)-suf])...)
+	for i := len(a) - suf; i < len(a); i++ {
+		ops = append(ops, diffOp{' ', a[i]})
+	}
+	return ops
+}
+
+// http://www.xmailserver.org/diff2.pdf
+func diffMyers(a []string, b []string) []diffOp {
+	n, m := len(a), len(b)
+	max := n + m
+	if max == 0 {
+		return nil
+	}
+
+	offset := max
+	v := make([]int, 2*max+2)
+	var trace [][]int // v before step d, only the range -d..d is kept
+
+	for d := 0; d <= max && d <= diffMaxEdits; d++ {
+		snapshot := make([]int, 2*d+1)
+		copy(snapshot, v[offset-d:offset+d+1])
+		trace = append(trace, snapshot)
+
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				return diffMyersBacktrack(a, b, trace)
+			}
+		}
+	}
+
+	// too many edits, not minimal but still correct
+	ops := make([]diffOp, 0, n+m)
+	for _, line := range a {
+		ops = append(ops, diffOp{'-', line})
+	}
+	for _, line := range b {
+		ops = append(ops, diffOp{'+', line})
+	}
+	return ops
+}
+
+func diffMyersBacktrack(a []string, b []string, trace [][]int) []diffOp {
+	var reversed []diffOp
+	x, y := len(a), len(b)
+
+	for d := len(trace) - 1; d >= 0; d-- {
+		v := trace[d]
+		// v covers -d..d of the previous step; index k as v[k+d]
+		get := func(k int) int {
+			if k+d < 0 || k+d >= len(v) {
+				return 0
+			}
+			return v[k+d]
+		}
+
+		k := x - y
+		var prevK int
+		if k == -d || (k != d && get(k-1) < get(k+1)) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := get(prevK)
+		prevY := prevX - prevK
+
+		for x > prevX && y > prevY {
+			reversed = append(reversed, diffOp{' ', a[x-1]})
+			x--
+			y--
+		}
+
+		if d > 0 {
+			if x == prevX {
+				reversed = append(reversed, diffOp{'+', b[prevY]})
+			} else {
+				reversed = append(reversed, diffOp{'-', a[prevX]})
+			}
+		}
+		x, y = prevX, prevY
+	}
+
+	ops := make([]diffOp, len(reversed))
+	for i, op := range reversed {
+		ops[len(reversed)-1-i] = op
+	}
+	return ops
+}
+
+// write a diff line, noting when the line has no newline at the end of file
+func diffWriteLine(buf *bytes.Buffer, kind byte, line string) {
+	buf.WriteByte(kind)
+	buf.WriteString(line)
+	if !strings.HasSuffix(line, "\n") {
+		buf.WriteString("\n\\ No newline at end of file\n")
+	}
+}
+
+// unified diff hunks for ops
+func diffHunks(buf *bytes.Buffer, ops []diffOp) {
+	// positions (0 based) in old and new for each op
+	type opPos struct{ a, b int }
+	pos := make([]opPos, len(ops)+1)
+	a, b := 0, 0
+	for i, op := range ops {
+		pos[i] = opPos{a, b}
+		if op.Kind != '+' {
+			a++
+		}
+		if op.Kind != '-' {
+			b++
+		}
+	}
+	pos[len(ops)] = opPos{a, b}
+
+	i := 0
+	for i < len(ops) {
+		// find next change
+		for i < len(ops) && ops[i].Kind == ' ' {
+			i++
+		}
+		if i >= len(ops) {
+			break
+		}
+
+		start := i - diffContextLines
+		if start < 0 {
+			start = 0
+		}
+
+		// extend while changes are close together
+		end := i
+		for end < len(ops) {
+			if ops[end].Kind != ' ' {
+				end++
+				continue
+			}
+			run := end
+			for run < len(ops) && ops[run].Kind == ' ' {
+				run++
+			}
+			if run >= len(ops) || run-end > 2*diffContextLines {
+				end += diffContextLines
+				if end > len(ops) {
+					end = len(ops)
+				}
+				break
+			}
+			end = run
+		}
+
+		oldStart, newStart := pos[start].a, pos[start].b
+		oldLen, newLen := pos[end].a-oldStart, pos[end].b-newStart
+		// empty ranges in unified diffs refer to the line before
+		if oldLen > 0 {
+			oldStart++
+		}
+		if newLen > 0 {
+			newStart++
+		}
+		fmt.Fprintf(buf, "@@ -%d,%d +%d,%d @@\n", oldStart, oldLen, newStart, newLen)
+		for _, op := range ops[start:end] {
+			diffWriteLine(buf, op.Kind, op.Line)
+		}
+		i = end
+	}
+}
+
+// unified diff text for a file, names are used as is in the ---/+++ lines
+func (fd *fileDiff) unified(oldName string, newName string) string {
+	if !fd.OldExists {
+		oldName = "/dev/null"
+	}
+	if !fd.NewExists {
+		newName = "/dev/null"
+	}
+
+	buf := &bytes.Buffer{}
+	if fd.Binary {
+		fmt.Fprintf(buf, "Binary files %s and %s differ\n", oldName, newName)
+		return buf.String()
+	}
+	fmt.Fprintf(buf, "--- %s\n+++ %s\n", oldName, newName)
+	diffHunks(buf, fd.Ops)
+	return buf.String()
+}
+
+func diffIsBinary(content []byte) bool {
+	check := content
+	if len(check) > 8000 {
+		check = check[:8000]
+	}
+	return bytes.IndexByte(check, 0) >= 0
+}
+
+func diffFiles(relPath string, oldContent []byte, oldExists bool, newContent []byte, newExists bool) *fileDiff {
+	fd := &fileDiff{Path: relPath, OldExists: oldExists, NewExists: newExists}
+	if diffIsBinary(oldContent) || diffIsBinary(newContent) {
+		fd.Binary = true
+		return fd
+	}
+
+	fd.Ops = diffLines(diffSplitLines(oldContent), diffSplitLines(newContent))
+	for _, op := range fd.Ops {
+		if op.Kind == '+' {
+			fd.Added++
+		} else if op.Kind == '-' {
+			fd.Removed++
+		}
+	}
+	return fd
+}
+
+// relative (slash separated) file names in a tree, skipping dot directories
+// such as .git and .hg
+func diffListFiles(dir string) (map[string]bool, error) {
+	files := map[string]bool{}
+	_, err := os.Stat(dir)
+	if err != nil && os.IsNotExist(err) {
+		return files, nil
+	}
+
+	err = filepath.Walk(dir, func(path string, f os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if f.IsDir() {
+			if path != dir && strings.HasPrefix(f.Name(), ".") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !f.Mode().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		files[filepath.ToSlash(rel)] = true
+		return nil
+	})
+	return files, err
+}
+
+// compare two directory trees, sorted by path, only files that differ
+func diffTrees(oldDir string, newDir string) ([]*fileDiff, error) {
+	oldFiles, err := diffListFiles(oldDir)
+	if err != nil {
+		return nil, err
+	}
+	newFiles, err := diffListFiles(newDir)
+	if err != nil {
+		return nil, err
+	}
+
+	var allFiles []string
+	for f := range oldFiles {
+		allFiles = append(allFiles, f)
+	}
+	for f := range newFiles {
+		if !oldFiles[f] {
+			allFiles = append(allFiles, f)
+		}
+	}
+	sort.Strings(allFiles)
+
+	var diffs []*fileDiff
+	for _, f := range allFiles {
+		var oldContent, newContent []byte
+		if oldFiles[f] {
+			oldContent, err = ioutil.ReadFile(filepath.Join(oldDir, filepath.FromSlash(f)))
+			if err != nil {
+				return nil, err
+			}
+		}
+		if newFiles[f] {
+			newContent, err = ioutil.ReadFile(filepath.Join(newDir, filepath.FromSlash(f)))
+			if err != nil {
+				return nil, err
+			}
+		}
+		if oldFiles[f] && newFiles[f] && bytes.Equal(oldContent, newContent) {
+			continue
+		}
+		diffs = append(diffs, diffFiles(f, oldContent, oldFiles[f], newContent, newFiles[f]))
+	}
+	return diffs, nil
+}
+
+// git style diffstat
+func diffStat(diffs []*fileDiff) string {
+	buf := &bytes.Buffer{}
+	nameWidth := 0
+	totalAdded, totalRemoved := 0, 0
+	for _, fd := range diffs {
+		if len(fd.Path) > nameWidth {
+			nameWidth = len(fd.Path)
+		}
+		totalAdded += fd.Added
+		totalRemoved += fd.Removed
+	}
+
+	const barWidth = 50
+	maxChanges := 0
+	for _, fd := range diffs {
+		if fd.Added+fd.Removed > maxChanges {
+			maxChanges = fd.Added + fd.Removed
+		}
+	}
+
+	for _, fd := range diffs {
+		if fd.Binary {
+			fmt.Fprintf(buf, " %-*s | Bin\n", nameWidth, fd.Path)
+			continue
+		}
+		added, removed := fd.Added, fd.Removed
+		if maxChanges > barWidth {
+			added = (added*barWidth + maxChanges - 1) / maxChanges
+			removed = (removed*barWidth + maxChanges - 1) / maxChanges
+		}
+		fmt.Fprintf(buf, " %-*s | %5d %s%s\n", nameWidth, fd.Path, fd.Added+fd.Removed,
+			strings.Repeat("+", added), strings.Repeat("-", removed))
+	}
+	fmt.Fprintf(buf, " %d files changed, %d insertions(+), %d deletions(-)\n", len(diffs), totalAdded, totalRemoved)
+	return buf.String()
+}
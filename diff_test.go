@@ -0,0 +1,125 @@
+package main
+
+import (
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// the two sides of ops
+func diffTestSides(ops []diffOp) ([]string, []string) {
+	var a, b []string
+	for _, op := range ops {
+		if op.Kind != '+' {
+			a = append(a, op.Line)
+		}
+		if op.Kind != '-' {
+			b = append(b, op.Line)
+		}
+	}
+	return a, b
+}
+
+func diffTestEdits(ops []diffOp) int {
+	edits := 0
+	for _, op := range ops {
+		if op.Kind != ' ' {
+			edits++
+		}
+	}
+	return edits
+}
+
+func TestDiffSplitLines(t *testing.T) {
+	tests := []struct {
+		content string
+		lines   []string
+	}{
+		{"", nil},
+		{"a\n", []string{"a\n"}},
+		{"a\nb", []string{"a\n", "b"}},
+		{"a\n\nb\n", []string{"a\n", "\n", "b\n"}},
+	}
+	for _, test := range tests {
+		if lines := diffSplitLines([]byte(test.content)); !reflect.DeepEqual(lines, test.lines) {
+			t.Errorf("diffSplitLines(%q) = %q, want %q", test.content, lines, test.lines)
+		}
+	}
+}
+
+func TestDiffMyers(t *testing.T) {
+	tests := []struct {
+		a, b  string
+		edits int
+	}{
+		{"", "", 0},
+		{"a b c", "a b c", 0},
+		{"", "a b", 2},
+		{"a b", "", 2},
+		// the example of the paper
+		{"a b c a b b a", "c b a b a c", 5},
+		{"a b c d", "a x c d", 2},
+	}
+	for _, test := range tests {
+		a, b := strings.Fields(test.a), strings.Fields(test.b)
+		ops := diffMyers(a, b)
+		gotA, gotB := diffTestSides(ops)
+		if strings.Join(gotA, " ") != test.a || strings.Join(gotB, " ") != test.b {
+			t.Errorf("diffMyers(%q, %q) = %v, sides do not match", test.a, test.b, ops)
+		}
+		if edits := diffTestEdits(ops); edits != test.edits {
+			t.Errorf("diffMyers(%q, %q) has %d edits, want %d", test.a, test.b, edits, test.edits)
+		}
+	}
+}
+
+func TestDiffLinesRandom(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	random := func() []string {
+		lines := make([]string, r.Intn(30))
+		for i := range lines {
+			lines[i] = string(rune('a'+r.Intn(4))) + "\n"
+		}
+		return lines
+	}
+	for i := 0; i < 500; i++ {
+		a, b := random(), random()
+		ops := diffLines(a, b)
+		gotA, gotB := diffTestSides(ops)
+		if strings.Join(gotA, "") != strings.Join(a, "") || strings.Join(gotB, "") != strings.Join(b, "") {
+			t.Fatalf("diffLines(%q, %q) = %v, sides do not match", a, b, ops)
+		}
+		if edits := diffTestEdits(ops); edits > len(a)+len(b) {
+			t.Fatalf("diffLines(%q, %q) has %d edits, more than all lines", a, b, edits)
+		}
+	}
+}
+
+func TestDiffHunks(t *testing.T) {
+	old := "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n"
+	new := "1\n2\nthree\n4\n5\n6\n7\n8\n9\n10\n11\n12\nthirteen\n"
+	fd := diffFiles("f.txt", []byte(old), true, []byte(new), true)
+	want := `--- a/f.txt
++++ b/f.txt
+@@ -1,6 +1,6 @@
+ 1
+ 2
+-3
++three
+ 4
+ 5
+ 6
+@@ -10,3 +10,4 @@
+ 10
+ 11
+ 12
++thirteen
+`
+	if got := fd.unified("a/f.txt", "b/f.txt"); got != want {
+		t.Errorf("unified diff\n%s\nwant\n%s", got, want)
+	}
+	if fd.Added != 2 || fd.Removed != 1 {
+		t.Errorf("Added %d Removed %d, want 2 and 1", fd.Added, fd.Removed)
+	}
+}
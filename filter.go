@@ -0,0 +1,154 @@
+package main
+
+//
+// Include and Exclude of a vendored package, globs on slash separated paths
+// inside the package directory. A glob without a slash matches a name at any
+// depth, as in .gitignore, one with a slash the path from the package
+// directory. A matching directory takes everything in it.
+//
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+func filterGlobMatches(glob string, rel string) bool {
+	glob = strings.Trim(glob, "/")
+	elems := strings.Split(rel, "/")
+	if !strings.Contains(glob, "/") {
+		for _, elem := range elems {
+			if ok, _ := path.Match(glob, elem); ok {
+				return true
+			}
+		}
+		return false
+	}
+	for i := len(elems); i > 0; i-- {
+		if ok, _ := path.Match(glob, strings.Join(elems[:i], "/")); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func filterAnyMatches(globs []string, rel string) bool {
+	for _, glob := range globs {
+		if filterGlobMatches(glob, rel) {
+			return true
+		}
+	}
+	return false
+}
+
+func (info *ggvPackage) hasFilter() bool {
+	return len(info.Include) > 0 || len(info.Exclude) > 0
+}
+
+// rel is a file or directory in the package directory, "" for the package
+// directory itself. Exclude wins over Include.
+func (info *ggvPackage) keepsPath(rel string) bool {
+	if rel == "" {
+		return len(info.Include) == 0
+	}
+	if len(info.Include) > 0 && !filterAnyMatches(info.Include, rel) {
+		return false
+	}
+	return !filterAnyMatches(info.Exclude, rel)
+}
+
+// remove what Include and Exclude leave out, license files at the top are
+// kept unless excluded, .git and .hg are left alone
+func filterPackageDir(dir string, info *ggvPackage) ([]string, error) {
+	var removed []string
+	var emptyCandidates []string
+	err := filepath.Walk(dir, func(path string, f os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if path == dir {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+
+		if f.IsDir() {
+			if strings.HasPrefix(f.Name(), ".") && filepath.Dir(path) == dir {
+				return filepath.SkipDir
+			}
+			if filterAnyMatches(info.Exclude, rel) {
+				removed = append(removed, rel+"/")
+				err = os.RemoveAll(path)
+				if err != nil {
+					return err
+				}
+				return filepath.SkipDir
+			}
+			emptyCandidates = append(emptyCandidates, path)
+			return nil
+		}
+
+		if !strings.Contains(rel, "/") && isLicenseFile(f.Name()) && !filterAnyMatches(info.Exclude, rel) {
+			return nil
+		}
+		if !info.keepsPath(rel) {
+			removed = append(removed, rel)
+			return os.Remove(path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// deepest first, so parents may become empty as well
+	sort.Sort(sort.Reverse(sort.StringSlice(emptyCandidates)))
+	for _, path := range emptyCandidates {
+		entries, err := ioutil.ReadDir(path)
+		if err == nil && len(entries) == 0 {
+			os.Remove(path)
+		}
+	}
+	return removed, nil
+}
+
+// sub-packages of the fetched tree at dir that the filter keeps, the ones
+// to follow dependencies from when Include picks parts of a repo
+func filterKeptPackages(dir string, p string, info *ggvPackage) []string {
+	var pkgs []string
+	filepath.Walk(dir, func(path string, f os.FileInfo, err error) error {
+		if err != nil || !f.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return nil
+		}
+		rel = filepath.ToSlash(rel)
+		if rel == "." {
+			rel = ""
+		}
+		name := f.Name()
+		if rel != "" && (strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_") || name == "testdata" || name == "vendor") {
+			return filepath.SkipDir
+		}
+		if rel != "" && filterAnyMatches(info.Exclude, rel) {
+			return filepath.SkipDir
+		}
+		if !info.keepsPath(rel) {
+			return nil
+		}
+		matches, _ := filepath.Glob(filepath.Join(path, "*.go"))
+		if len(matches) > 0 {
+			pkgs = append(pkgs, joinImportPath(p, rel))
+		}
+		return nil
+	})
+	return pkgs
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestFilterGlobMatches(t *testing.T) {
+	tests := []struct {
+		glob string
+		rel  string
+		want bool
+	}{
+		// no slash, a name at any depth
+		{"*_test.go", "a_test.go", true},
+		{"*_test.go", "sub/dir/a_test.go", true},
+		{"*_test.go", "a.go", false},
+		{"testdata", "testdata", true},
+		{"testdata", "sub/testdata/x.json", true},
+		{"testdata", "sub/mytestdata/x.json", false},
+		{"/docs/", "docs", true},
+
+		// with a slash, from the package directory, directories take what is in them
+		{"cmd/tool", "cmd/tool", true},
+		{"cmd/tool", "cmd/tool/main.go", true},
+		{"cmd/tool", "cmd/toolbox/main.go", false},
+		{"cmd/tool", "sub/cmd/tool/main.go", false},
+		{"cmd/*", "cmd/a/b.go", true},
+		{"cmd/*.go", "cmd/a.go", true},
+		{"cmd/*.go", "cmd/a/b.go", false},
+		{"/internal/x", "internal/x/y.go", true},
+
+		{"[", "a", false},
+	}
+	for _, test := range tests {
+		if got := filterGlobMatches(test.glob, test.rel); got != test.want {
+			t.Errorf("filterGlobMatches %q %q = %v, want %v", test.glob, test.rel, got, test.want)
+		}
+	}
+}
+
+func TestKeepsPath(t *testing.T) {
+	tests := []struct {
+		include []string
+		exclude []string
+		rel     string
+		want    bool
+	}{
+		{nil, nil, "", true},
+		{nil, nil, "a.go", true},
+		{[]string{"proto"}, nil, "", false},
+		{[]string{"proto"}, nil, "proto/a.go", true},
+		{[]string{"proto"}, nil, "grpc/a.go", false},
+		{nil, []string{"examples"}, "examples/a/main.go", false},
+		{nil, []string{"examples"}, "a.go", true},
+		{nil, []string{"*_test.go"}, "sub/a_test.go", false},
+		{[]string{"proto"}, []string{"*_test.go"}, "proto/a_test.go", false},
+		{[]string{"proto"}, []string{"proto"}, "proto/a.go", false},
+	}
+	for _, test := range tests {
+		info := &ggvPackage{Include: test.include, Exclude: test.exclude}
+		if got := info.keepsPath(test.rel); got != test.want {
+			t.Errorf("keepsPath %v %v %q = %v, want %v", test.include, test.exclude, test.rel, got, test.want)
+		}
+	}
+}
+
+func testFilterTree(t *testing.T, files []string) string {
+	dir := t.TempDir()
+	for _, file := range files {
+		path := filepath.Join(dir, filepath.FromSlash(file))
+		err := os.MkdirAll(filepath.Dir(path), 0755)
+		if err == nil {
+			err = ioutil.WriteFile(path, []byte("package x\n"), 0644)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func testFilterFiles(t *testing.T, dir string) []string {
+	var files []string
+	filepath.Walk(dir, func(path string, f os.FileInfo, err error) error {
+		if err != nil {
+			t.Fatal(err)
+		}
+		rel, _ := filepath.Rel(dir, path)
+		if f.IsDir() && rel != "." {
+			rel += "/"
+		}
+		if rel != "." {
+			files = append(files, filepath.ToSlash(rel))
+		}
+		return nil
+	})
+	sort.Strings(files)
+	return files
+}
+
+func TestFilterPackageDir(t *testing.T) {
+	dir := testFilterTree(t, []string{
+		"LICENSE",
+		"README.md",
+		"a.go",
+		".git/HEAD",
+		"proto/p.go",
+		"proto/p_test.go",
+		"proto/examples/e.go",
+		"grpc/g.go",
+		"grpc/LICENSE",
+	})
+	info := &ggvPackage{Include: []string{"proto"}, Exclude: []string{"examples", "*_test.go"}}
+	removed, err := filterPackageDir(dir, info)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(removed)
+
+	wantRemoved := []string{"README.md", "a.go", "grpc/LICENSE", "grpc/g.go", "proto/examples/", "proto/p_test.go"}
+	if !reflect.DeepEqual(removed, wantRemoved) {
+		t.Errorf("filterPackageDir removed %v, want %v", removed, wantRemoved)
+	}
+	wantFiles := []string{".git/", ".git/HEAD", "LICENSE", "proto/", "proto/p.go"}
+	if files := testFilterFiles(t, dir); !reflect.DeepEqual(files, wantFiles) {
+		t.Errorf("filterPackageDir left %v, want %v", files, wantFiles)
+	}
+}
+
+func TestFilterKeptPackages(t *testing.T) {
+	dir := testFilterTree(t, []string{
+		"a.go",
+		"proto/p.go",
+		"proto/v2/p.go",
+		"proto/examples/e.go",
+		"proto/testdata/t.go",
+		"grpc/g.go",
+	})
+	info := &ggvPackage{Include: []string{"proto"}, Exclude: []string{"examples"}}
+	pkgs := filterKeptPackages(dir, "example.com/a", info)
+	want := []string{"example.com/a/proto", "example.com/a/proto/v2"}
+	if !reflect.DeepEqual(pkgs, want) {
+		t.Errorf("filterKeptPackages = %v, want %v", pkgs, want)
+	}
+
+	pkgs = filterKeptPackages(dir, "example.com/a", &ggvPackage{})
+	if len(pkgs) != 5 || pkgs[0] != "example.com/a" {
+		t.Errorf("filterKeptPackages without a filter = %v", pkgs)
+	}
+}
@@ -18,12 +18,74 @@ type ggcmd struct {
 
 	// track seen vendored or internal directories
 	astmodSpecialDirs map[string]*string
+
+	// imports of the vendor tree on disk, for reapplying prunes
+	pruneVendorGraph importGraph
+
+	// user settings, and url rewrites with the vendor root rules added
+	config      *ggConfig
+	urlRewrites []*ggvUrlRewrite
+
+	// $NETRC or ~/.netrc, loaded on first use
+	netrcEntries map[string]netrcEntry
+
+	// GGHOME/cache/meta.json, loaded on first use, --refresh skips it
+	metaCacheEntries map[string]*metaCacheEntry
+	metaRefresh      bool
+
+	// known host rules, user ones first
+	knownHostRules []*ggKnownHost
+
+	// GGHOME/map.json and _ggmap.json of the vendor root
+	importMaps []*ggImportMap
+
+	// trees fetched for the dependency analysis, reused by downloadPkg
+	fetchedTrees map[string]*fetchedTree
+
+	// GOOS/GOARCH and tags dependencies are collected for
+	depPlatforms []depPlatform
+
+	// vendor root of vadd, vupdate and vrebuild, a source when offline
+	offlineVendor *offlineVendor
+}
+
+// temp trees and the like, removed when gg exits, ggFatal included
+var ggCleanups []func()
+
+// run f on exit, last registered first
+func ggAtExit(f func()) {
+	ggCleanups = append(ggCleanups, f)
+}
+
+// remove dir on exit
+func ggRemoveAtExit(dir string) {
+	ggAtExit(func() { os.RemoveAll(dir) })
+}
+
+// run the registered cleanups, once
+func ggCleanup() {
+	for len(ggCleanups) > 0 {
+		f := ggCleanups[len(ggCleanups)-1]
+		ggCleanups = ggCleanups[:len(ggCleanups)-1]
+		f()
+	}
+}
+
+// clean up, exit with code
+func ggExit(code int) {
+	ggCleanup()
+	os.Exit(code)
 }
 
 // print out stderr "ERROR: <message>", exit
 func ggFatal(format string, a ...interface{}) {
 	_, _ = fmt.Fprintf(os.Stderr, "ERROR: "+format+"\n", a...)
-	os.Exit(1)
+	ggExit(1)
+}
+
+// print out stderr "WARNING: <message>", carry on
+func ggWarn(format string, a ...interface{}) {
+	_, _ = fmt.Fprintf(os.Stderr, "WARNING: "+format+"\n", a...)
 }
 
 func (cmd *ggcmd) init() {
@@ -38,12 +100,12 @@ func (cmd *ggcmd) run() {
 	}
 
 	doAction.helper()
-	os.Exit(0)
+	ggExit(0)
 }
 
 func (cmd *ggcmd) getCommand() (doAction *action) {
 	if len(os.Args) <= 1 {
-		fmt.Printf(cmd.commands[""].usage)
+		fmt.Print(cmd.commands[""].usage)
 		os.Exit(0)
 	}
 
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestGgCleanup(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	var order []int
+	ggAtExit(func() { order = append(order, 1) })
+	ggRemoveAtExit(dir)
+	ggAtExit(func() { order = append(order, 2) })
+	ggCleanup()
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		t.Errorf("%s not removed, %v", dir, err)
+	}
+	if !reflect.DeepEqual(order, []int{2, 1}) {
+		t.Errorf("cleanups ran in order %v, want last registered first", order)
+	}
+
+	// run once
+	ggCleanup()
+	if len(order) != 2 || len(ggCleanups) != 0 {
+		t.Errorf("cleanups ran again, %v %d left", order, len(ggCleanups))
+	}
+}
@@ -11,27 +11,89 @@ import (
 )
 
 type ggvPackage struct {
-	LastUpdate     string // date-time of last update or touch
-	Vcs            string // git, hg, manual for now
+	LastUpdate     string `json:",omitempty"` // date-time of last update or touch
+	Vcs            string // git, hg, proxy, manual for now
 	VcsSource      string
-	Revision       string
-	Lock           bool // do not update on update
-	RewriteImports bool // on update do import rewrites, or not
-	ShallowUpdate  bool // do not recuse on go get dependencies
-	SaveRepo       bool // keep copy of .git or .hg
-	DepTests       bool // check dependencies of tests (when not shallow)
+	Revision       string `json:",omitempty"`
+	Lock           bool   // do not update on update
+	RewriteImports bool   // on update do import rewrites, or not
+	ShallowUpdate  bool   // do not recuse on go get dependencies
+	SaveRepo       bool   // keep copy of .git or .hg
+	DepTests       bool   // check dependencies of tests (when not shallow)
 	Notes          string
+	Prune          *ggvPrune `json:",omitempty"` // reapplied on every download
+	License        string    `json:",omitempty"` // spdx expression, see vlicenses
+	Patches        []string  `json:",omitempty"` // relative to _ggv.json, applied in order
+	Fallbacks      []string  `json:",omitempty"` // other sources, tried in order when VcsSource fails
+	Version        string    `json:",omitempty"` // proxy: module version fetched
+	ZipHash        string    `json:",omitempty"` // proxy: h1: hash of the module zip, checked on vrebuild
+	Subdir         string    `json:",omitempty"` // package directory in the repo, from go-import
+	TreeHash       string    `json:",omitempty"` // h1: hash of the vendored files as gg wrote them, see vstatus
+	Include        []string  `json:",omitempty"` // globs of what to vendor, everything if empty, see filter.go
+	Exclude        []string  `json:",omitempty"` // globs of what not to vendor, wins over Include
+}
+
+// what vprune removes from a vendored repo
+type ggvPrune struct {
+	UnusedPackages bool     // sub-packages not reachable from Used
+	Tests          bool     // _test.go files
+	Testdata       bool     // testdata directories
+	NonGo          bool     // files that are not go sources, licenses are kept
+	Used           []string `json:",omitempty"` // sub-packages imported from outside the repo
+}
+
+// allow and deny lists of spdx ids
+type ggvLicensePolicy struct {
+	Allow []string `json:",omitempty"` // when set, anything else is flagged
+	Deny  []string `json:",omitempty"`
+}
+
+// fetch from Url + rest instead of sources starting with InsteadOf
+type ggvUrlRewrite struct {
+	Url       string
+	InsteadOf string
 }
 
 // handling the vendor package file
 type ggvJson struct {
-	Version      string
-	VendorPrefix string
-	Packages     map[string]*ggvPackage // key is canonical pkg name
+	Version       string
+	VendorPrefix  string
+	Packages      map[string]*ggvPackage // key is canonical pkg name
+	LicensePolicy *ggvLicensePolicy      `json:",omitempty"`
+	UrlRewrites   []*ggvUrlRewrite       `json:",omitempty"` // user rules in GGHOME/config.json win ties
+	Platforms     []string               `json:",omitempty"` // GOOS/GOARCH dependencies are collected for, host if empty
+	BuildTags     []string               `json:",omitempty"` // extra build tags for every platform
+
+	readVersion   string   // schema version of the file as read, "" for a new one
+	migrations    []string // notes of the migrations applied when read
+	original      []byte   // file content as read, for the backup
+	unknownFields []string // in the file, but not in ggvJson
+	split         bool     // resolved state in _ggv.lock.json
 }
 
 func (ggv *ggvJson) saveGvv(vendorFilename string) error {
-	ggv.Version = "0.1" // force version
+	if len(ggv.unknownFields) > 0 {
+		ggFatal("Not saving %s, it has fields this gg does not know: %s. Please update gg, or remove them with \"gg vmigrate-config --drop-unknown\".", vendorFilename, strings.Join(ggv.unknownFields, ", "))
+	}
+	// keep the file older gg can read, before the first save in a new version
+	if ggv.original != nil && ggv.readVersion != ggvSchemaVersion {
+		_, err := ggv.saveBackup(vendorFilename)
+		if err != nil {
+			ggFatal("Unable to back up %s. %s", vendorFilename, err)
+		}
+	}
+
+	ggv.Version = ggvSchemaVersion // force version
+	ggv.readVersion = ggvSchemaVersion
+	if ggv.split {
+		err := ggv.saveSplit(vendorFilename)
+		if err != nil {
+			ggFatal("Unable to write %s. %s", vendorFilename, err)
+		}
+		ggv.original, _ = ioutil.ReadFile(vendorFilename)
+		return nil
+	}
+
 	b, err := json.MarshalIndent(ggv, "", "    ")
 	if err != nil {
 		ggFatal("Unable to marshal _ggv.json file. %s", err)
@@ -41,11 +103,17 @@ func (ggv *ggvJson) saveGvv(vendorFilename string) error {
 	if err != nil {
 		ggFatal("Unable to write %s. %s", vendorFilename, err)
 	}
+	ggv.original = b
 	return nil
 }
 
+// _ggv.json.v<version>.bak, the file as read
+func (ggv *ggvJson) saveBackup(vendorFilename string) (string, error) {
+	backupFilename := vendorFilename + ".v" + ggv.readVersion + ".bak"
+	return backupFilename, ioutil.WriteFile(backupFilename, ggv.original, 0644)
+}
+
 func readGvvFromFile(filename string) (*ggvJson, error) {
-	var ggv ggvJson
 	file, err := os.Open(filename)
 	if err != nil {
 		ggFatal("%s", err)
@@ -63,12 +131,16 @@ func readGvvFromFile(filename string) (*ggvJson, error) {
 		ggFatal("%s", err)
 	}
 
-	err = json.Unmarshal(buffer, &ggv)
+	ggv, err := decodeGgv(buffer, filename)
+	if err != nil {
+		ggFatal("%s", err)
+	}
+	err = ggv.readLock(filename)
 	if err != nil {
 		ggFatal("%s", err)
 	}
 
-	return &ggv, nil
+	return ggv, nil
 }
 
 func resolveVendorConfigFilename(optVendor string, userSpecified bool) (string, *ggvJson, error) {
@@ -139,3 +211,17 @@ func resolveVendorConfigFilename(optVendor string, userSpecified bool) (string,
 
 	return "", nil, errors.New("Unable to find _ggv.json at the default locations.")
 }
+
+// vendored package containing import path p, longest match
+func (ggv *ggvJson) packageFor(p string) (string, *ggvPackage) {
+	var found string
+	for pkgName := range ggv.Packages {
+		if (p == pkgName || strings.HasPrefix(p, pkgName+"/")) && len(pkgName) > len(found) {
+			found = pkgName
+		}
+	}
+	if found == "" {
+		return "", nil
+	}
+	return found, ggv.Packages[found]
+}
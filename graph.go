@@ -0,0 +1,258 @@
+package main
+
+//
+// dependency graph export: Graphviz dot, json adjacency lists, mermaid
+//
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+type depGraphNode struct {
+	Name       string
+	Status     string `json:",omitempty"` // vendored, canonical, missing
+	Revision   string `json:",omitempty"`
+	ImportedBy int
+}
+
+type depGraph struct {
+	Nodes map[string]*depGraphNode
+	Edges importGraph // canonical names
+}
+
+// build from raw edges, imports may be rewritten or canonical. Without a
+// vendor config, nodes are not annotated.
+func (cmd *ggcmd) newDepGraph(rawEdges importGraph, ggv *ggvJson, vendorDir string) *depGraph {
+	g := &depGraph{Nodes: map[string]*depGraphNode{}, Edges: importGraph{}}
+	vendorRoot := ""
+	if ggv != nil {
+		vendorRoot = ggv.VendorPrefix
+	}
+
+	node := func(name string) *depGraphNode {
+		if g.Nodes[name] == nil {
+			g.Nodes[name] = &depGraphNode{Name: name}
+			g.Nodes[name].Status, g.Nodes[name].Revision = depGraphStatus(ggv, vendorDir, name)
+		}
+		return g.Nodes[name]
+	}
+
+	for pkg, imports := range rawEdges {
+		from := canonicalImport(vendorRoot, pkg)
+		node(from)
+		seen := map[string]bool{}
+		for _, imp := range g.Edges[from] {
+			seen[imp] = true
+		}
+		for _, imp := range imports {
+			to := canonicalImport(vendorRoot, imp)
+			toNode := node(to)
+			// vendored, but imported by its canonical path
+			if imp == to && toNode.Status == "vendored" && vendorRoot != "" {
+				toNode.Status = "canonical"
+			}
+			if seen[to] || to == from {
+				continue
+			}
+			seen[to] = true
+			g.Edges[from] = append(g.Edges[from], to)
+			toNode.ImportedBy++
+		}
+		sort.Strings(g.Edges[from])
+	}
+	g.clearConsumerStatus(ggv)
+	return g
+}
+
+// consumers are not vendored, but nothing is missing either
+func (g *depGraph) clearConsumerStatus(ggv *ggvJson) {
+	for name, node := range g.Nodes {
+		if node.ImportedBy == 0 && ggv != nil {
+			if pkgName, _ := ggv.packageFor(name); pkgName == "" {
+				node.Status = ""
+			}
+		}
+	}
+}
+
+// status, revision
+func depGraphStatus(ggv *ggvJson, vendorDir string, name string) (string, string) {
+	if ggv == nil {
+		return "", ""
+	}
+	pkgName, info := ggv.packageFor(name)
+	if info == nil {
+		return "missing", ""
+	}
+	_, err := os.Stat(filepath.Join(vendorDir, name))
+	if err != nil && name != pkgName {
+		return "missing", info.Revision
+	}
+	_, err = os.Stat(filepath.Join(vendorDir, pkgName))
+	if err != nil {
+		return "missing", info.Revision
+	}
+	return "vendored", info.Revision
+}
+
+// collapse packages into the vendored package (repo) they belong to
+func (cmd *ggcmd) repoDepGraph(g *depGraph, ggv *ggvJson, vendorDir string) *depGraph {
+	repoOf := func(name string) string {
+		if ggv != nil {
+			if pkgName, _ := ggv.packageFor(name); pkgName != "" {
+				return pkgName
+			}
+		}
+		return cmd.trimPackageToRepo(name)
+	}
+
+	repoEdges := importGraph{}
+	for from, imports := range g.Edges {
+		repoFrom := repoOf(from)
+		if repoEdges[repoFrom] == nil {
+			repoEdges[repoFrom] = []string{}
+		}
+		for _, to := range imports {
+			repoEdges[repoFrom] = append(repoEdges[repoFrom], repoOf(to))
+		}
+	}
+	for name := range g.Nodes {
+		if repoEdges[repoOf(name)] == nil {
+			repoEdges[repoOf(name)] = []string{}
+		}
+	}
+
+	repoGraph := cmd.newDepGraph(repoEdges, nil, vendorDir)
+	for name, node := range repoGraph.Nodes {
+		node.Status, node.Revision = depGraphStatus(ggv, vendorDir, name)
+	}
+	// canonical if any package of the repo is
+	for name, node := range g.Nodes {
+		if node.Status == "canonical" {
+			repoGraph.Nodes[repoOf(name)].Status = "canonical"
+		}
+	}
+	repoGraph.clearConsumerStatus(ggv)
+	return repoGraph
+}
+
+func (g *depGraph) sortedNames() []string {
+	var names []string
+	for name := range g.Nodes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (g *depGraph) write(w io.Writer, format string) error {
+	switch format {
+	case "dot":
+		g.writeDot(w)
+	case "json":
+		return g.writeJson(w)
+	case "mermaid":
+		g.writeMermaid(w)
+	default:
+		return fmt.Errorf("Unknown graph format %s, use dot, json or mermaid", format)
+	}
+	return nil
+}
+
+var depGraphColors = map[string]string{
+	"vendored":  "palegreen",
+	"canonical": "khaki",
+	"missing":   "lightpink",
+}
+
+var depGraphMermaidColors = map[string]string{
+	"vendored":  "#dfd",
+	"canonical": "#ffc",
+	"missing":   "#fdd",
+}
+
+func (g *depGraph) label(node *depGraphNode) string {
+	label := node.Name
+	if node.Revision != "" {
+		rev := node.Revision
+		if len(rev) > 12 {
+			rev = rev[:12]
+		}
+		label += "\\n" + rev
+	}
+	if node.Status != "" {
+		label += "\\n" + node.Status
+	}
+	return label
+}
+
+func (g *depGraph) writeDot(w io.Writer) {
+	fmt.Fprintf(w, "digraph gg {\n\trankdir=LR;\n\tnode [shape=box, style=filled, fillcolor=white];\n")
+	for _, name := range g.sortedNames() {
+		node := g.Nodes[name]
+		attrs := fmt.Sprintf("label=%q", g.label(node))
+		attrs = strings.Replace(attrs, "\\\\n", "\\n", -1)
+		if color := depGraphColors[node.Status]; color != "" {
+			attrs += ", fillcolor=" + color
+		}
+		fmt.Fprintf(w, "\t%q [%s];\n", name, attrs)
+	}
+	for _, name := range g.sortedNames() {
+		for _, imp := range g.Edges[name] {
+			fmt.Fprintf(w, "\t%q -> %q;\n", name, imp)
+		}
+	}
+	fmt.Fprintf(w, "}\n")
+}
+
+func (g *depGraph) writeJson(w io.Writer) error {
+	out := struct {
+		Nodes []*depGraphNode
+		Edges importGraph
+	}{[]*depGraphNode{}, importGraph{}}
+	for _, name := range g.sortedNames() {
+		out.Nodes = append(out.Nodes, g.Nodes[name])
+		out.Edges[name] = g.Edges[name]
+		if out.Edges[name] == nil {
+			out.Edges[name] = []string{}
+		}
+	}
+	b, err := json.MarshalIndent(out, "", "    ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "%s\n", b)
+	return err
+}
+
+func (g *depGraph) writeMermaid(w io.Writer) {
+	ids := map[string]string{}
+	fmt.Fprintf(w, "graph LR\n")
+	for i, name := range g.sortedNames() {
+		ids[name] = fmt.Sprintf("n%d", i)
+		label := strings.Replace(g.label(g.Nodes[name]), "\\n", "<br/>", -1)
+		fmt.Fprintf(w, "    %s[\"%s\"]\n", ids[name], label)
+	}
+	for _, name := range g.sortedNames() {
+		for _, imp := range g.Edges[name] {
+			fmt.Fprintf(w, "    %s --> %s\n", ids[name], ids[imp])
+		}
+	}
+	for _, status := range []string{"vendored", "canonical", "missing"} {
+		var members []string
+		for _, name := range g.sortedNames() {
+			if g.Nodes[name].Status == status {
+				members = append(members, ids[name])
+			}
+		}
+		if len(members) > 0 {
+			fmt.Fprintf(w, "    classDef %s fill:%s\n    class %s %s\n", status, depGraphMermaidColors[status], strings.Join(members, ","), status)
+		}
+	}
+}
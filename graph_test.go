@@ -0,0 +1,49 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestNewDepGraphStatus(t *testing.T) {
+	vendorDir := t.TempDir()
+	err := os.MkdirAll(filepath.Join(vendorDir, "example.com", "a", "sub"), 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ggv := &ggvJson{VendorPrefix: "proj/vendor", Packages: map[string]*ggvPackage{
+		"example.com/a": {Revision: "abc"},
+		"example.com/b": {Revision: "def"},
+	}}
+	tests := []struct {
+		ggv   *ggvJson
+		edges importGraph
+		want  map[string]string
+	}{
+		// local code, vendored packages imported by their canonical path
+		{ggv, importGraph{"proj/app": {"proj/vendor/example.com/a", "example.com/a/sub", "example.com/b", "example.com/c"}},
+			map[string]string{"proj/app": "", "example.com/a": "vendored", "example.com/a/sub": "canonical",
+				"example.com/b": "missing", "example.com/c": "missing"}},
+		// remote code as rdep graphs it, without the vendor prefix
+		{&ggvJson{Packages: ggv.Packages}, importGraph{"example.com/r": {"example.com/a/sub", "example.com/c"}},
+			map[string]string{"example.com/r": "", "example.com/a/sub": "vendored", "example.com/c": "missing"}},
+		// no vendor root
+		{nil, importGraph{"example.com/r": {"example.com/a"}},
+			map[string]string{"example.com/r": "", "example.com/a": ""}},
+	}
+	for _, test := range tests {
+		g := (&ggcmd{}).newDepGraph(test.edges, test.ggv, vendorDir)
+		if len(g.Nodes) != len(test.want) {
+			t.Errorf("newDepGraph %v has nodes %v, want %v", test.edges, g.Nodes, test.want)
+		}
+		for name, status := range test.want {
+			if node := g.Nodes[name]; node == nil || node.Status != status {
+				t.Errorf("newDepGraph %v: %s is %+v, want %q", test.edges, name, node, status)
+			}
+		}
+	}
+	if g := (&ggcmd{}).newDepGraph(importGraph{"proj/app": {"example.com/a"}}, ggv, vendorDir); g.Nodes["example.com/a"].Revision != "abc" {
+		t.Errorf("vendored node %+v without its revision", g.Nodes["example.com/a"])
+	}
+}
@@ -0,0 +1,199 @@
+package main
+
+//
+// package level import graphs built by parsing source files on disk
+//
+
+import (
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// package import path -> imported packages, sorted
+type importGraph map[string][]string
+
+// walk dir, whose import path is importPrefix, and collect the imports of
+// every package found. All build tags are considered. Skips dot, "_" and
+// testdata directories as well as nested vendor roots.
+func scanImportGraph(dir string, importPrefix string, includeTests bool) (importGraph, error) {
+	graph := importGraph{}
+	seen := map[string]map[string]bool{}
+	fset := token.NewFileSet()
+
+	err := filepath.Walk(dir, func(path string, f os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if f.IsDir() {
+			if path == dir {
+				return nil
+			}
+			name := f.Name()
+			if strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_") || name == "testdata" {
+				return filepath.SkipDir
+			}
+			stat, err := os.Stat(filepath.Join(path, "_ggv.json"))
+			if err == nil && !stat.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if !strings.HasSuffix(f.Name(), ".go") {
+			return nil
+		}
+		if !includeTests && strings.HasSuffix(f.Name(), "_test.go") {
+			return nil
+		}
+
+		rel, err := filepath.Rel(dir, filepath.Dir(path))
+		if err != nil {
+			return err
+		}
+		pkg := importPrefix
+		if rel != "." {
+			pkg = joinImportPath(importPrefix, filepath.ToSlash(rel))
+		}
+
+		file, err := parser.ParseFile(fset, path, nil, parser.ImportsOnly)
+		if err != nil {
+			// broken files should not stop the whole graph
+			gglog.Printf("Unable to parse %s %s\n", path, err)
+			return nil
+		}
+
+		if seen[pkg] == nil {
+			seen[pkg] = map[string]bool{}
+			graph[pkg] = []string{}
+		}
+		for _, impNode := range file.Imports {
+			imp, err := strconv.Unquote(impNode.Path.Value)
+			if err != nil || seen[pkg][imp] {
+				continue
+			}
+			seen[pkg][imp] = true
+			graph[pkg] = append(graph[pkg], imp)
+		}
+		return nil
+	})
+
+	for pkg := range graph {
+		sort.Strings(graph[pkg])
+	}
+	return graph, err
+}
+
+func joinImportPath(prefix string, rel string) string {
+	if prefix == "" {
+		return rel
+	}
+	if rel == "" {
+		return prefix
+	}
+	return prefix + "/" + rel
+}
+
+// strip vendor prefix from a rewritten import
+func canonicalImport(vendorRoot string, imp string) string {
+	if vendorRoot != "" && strings.HasPrefix(imp, vendorRoot+"/") {
+		return imp[len(vendorRoot)+1:]
+	}
+	return imp
+}
+
+// graph of everything vendored, keyed by canonical import paths, core
+// packages dropped
+func (cmd *ggcmd) vendorImportGraph(vendorDir string, ggv *ggvJson, includeTests bool, canonicalize bool) (importGraph, error) {
+	graph := importGraph{}
+	for pkgName := range ggv.Packages {
+		pkgGraph, err := scanImportGraph(filepath.Join(vendorDir, pkgName), pkgName, includeTests)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+		cmd.mergeImportGraph(graph, pkgGraph, ggv.VendorPrefix, canonicalize)
+	}
+	return graph, nil
+}
+
+// merge src into dest, dropping core imports. Rewritten imports are mapped
+// back to canonical when canonicalize.
+func (cmd *ggcmd) mergeImportGraph(dest importGraph, src importGraph, vendorRoot string, canonicalize bool) {
+	for pkg, imports := range src {
+		seen := map[string]bool{}
+		for _, imp := range dest[pkg] {
+			seen[imp] = true
+		}
+		if dest[pkg] == nil {
+			dest[pkg] = []string{}
+		}
+		for _, imp := range imports {
+			canonical := canonicalImport(vendorRoot, imp)
+			if cmd.isCorePackage(canonical) {
+				continue
+			}
+			if canonicalize {
+				imp = canonical
+			}
+			if seen[imp] {
+				continue
+			}
+			seen[imp] = true
+			dest[pkg] = append(dest[pkg], imp)
+		}
+		sort.Strings(dest[pkg])
+	}
+}
+
+// package -> packages importing it
+func (graph importGraph) reverse() importGraph {
+	rgraph := importGraph{}
+	for pkg, imports := range graph {
+		for _, imp := range imports {
+			rgraph[imp] = append(rgraph[imp], pkg)
+		}
+	}
+	for pkg := range rgraph {
+		sort.Strings(rgraph[pkg])
+	}
+	return rgraph
+}
+
+// import path of a directory under GOPATH/src, or "" if not under it
+func importPathForDir(dir string) string {
+	gopath, err := getCurrentGopath()
+	if err != nil {
+		return ""
+	}
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return ""
+	}
+	rel, err := filepath.Rel(filepath.Join(gopath, "src"), absDir)
+	if err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+		return ""
+	}
+	return filepath.ToSlash(rel)
+}
+
+// graph of consumer project directories, comma separated
+func (cmd *ggcmd) consumersImportGraph(consumers string, vendorRoot string, includeTests bool, canonicalize bool) (importGraph, error) {
+	graph := importGraph{}
+	for _, dir := range splitCommaList(consumers) {
+		prefix := importPathForDir(dir)
+		if prefix == "" {
+			prefix = filepath.ToSlash(filepath.Clean(dir))
+		}
+		dirGraph, err := scanImportGraph(dir, prefix, includeTests)
+		if err != nil {
+			return nil, err
+		}
+		cmd.mergeImportGraph(graph, dirGraph, vendorRoot, canonicalize)
+	}
+	return graph, nil
+}
@@ -0,0 +1,125 @@
+package main
+
+//
+// import path prefix -> vcs and source, for hosts without go-get meta pages
+// GGHOME/map.json for your machine, _ggmap.json next to _ggv.json for
+// everyone using the vendor root
+//
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Prefix elements may be {1}, {2}, ... matching any one path element, Source
+// uses the same placeholders, e.g.
+// code.corp/{1}/{2} -> ssh://git@code.corp/{1}/{2}.git
+type ggImportMap struct {
+	Prefix string
+	Vcs    string
+	Source string
+}
+
+type ggImportMapFile struct {
+	Mappings []*ggImportMap
+}
+
+func userImportMapFilename() string {
+	return filepath.Join(ggHomeDir(), "map.json")
+}
+
+// a missing file has no mappings
+func readImportMapFile(filename string) ([]*ggImportMap, error) {
+	content, err := ioutil.ReadFile(filename)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var mapFile ggImportMapFile
+	err = json.Unmarshal(content, &mapFile)
+	if err != nil {
+		return nil, err
+	}
+	for _, m := range mapFile.Mappings {
+		if m.Prefix == "" || m.Vcs == "" || m.Source == "" {
+			return nil, fmt.Errorf("mapping needs Prefix, Vcs and Source, got %+v", *m)
+		}
+	}
+	return mapFile.Mappings, nil
+}
+
+// user mappings, then the ones of the vendor root, if there is one
+func (cmd *ggcmd) loadImportMaps(vendorDir string) {
+	filenames := []string{userImportMapFilename()}
+	if vendorDir != "" {
+		filenames = append(filenames, filepath.Join(vendorDir, "_ggmap.json"))
+	}
+	cmd.importMaps = nil
+	for _, filename := range filenames {
+		mappings, err := readImportMapFile(filename)
+		if err != nil {
+			ggFatal("Unable to read %s %s", filename, err)
+		}
+		cmd.importMaps = append(cmd.importMaps, mappings...)
+	}
+}
+
+// for commands without a vendor root option, the one found from here, if any
+func (cmd *ggcmd) loadImportMapsHere() {
+	vendorDir := ""
+	if vendorFilename, _, err := resolveVendorConfigFilename("", false); err == nil {
+		vendorDir = filepath.Dir(vendorFilename)
+	}
+	cmd.loadImportMaps(vendorDir)
+}
+
+// root, source, number of literal elements, ok
+func (m *ggImportMap) match(p string) (string, string, int, bool) {
+	prefixParts := strings.Split(strings.Trim(m.Prefix, "/"), "/")
+	pParts := strings.Split(p, "/")
+	if len(pParts) < len(prefixParts) {
+		return "", "", 0, false
+	}
+
+	source := m.Source
+	literals := 0
+	for i, part := range prefixParts {
+		if strings.HasPrefix(part, "{") && strings.HasSuffix(part, "}") {
+			source = strings.Replace(source, part, pParts[i], -1)
+		} else if part != pParts[i] {
+			return "", "", 0, false
+		} else {
+			literals++
+		}
+	}
+	return strings.Join(pParts[:len(prefixParts)], "/"), source, literals, true
+}
+
+// nil when no mapping matches
+// the longest prefix wins, then the one with fewer placeholders, then the user one
+func (cmd *ggcmd) importMapMeta(p string) *pkgMeta {
+	var best *pkgMeta
+	bestLiterals := 0
+	for _, m := range cmd.importMaps {
+		root, source, literals, ok := m.match(p)
+		if !ok {
+			continue
+		}
+		if best != nil && (len(root) < len(best.Prefix) || (len(root) == len(best.Prefix) && literals <= bestLiterals)) {
+			continue
+		}
+		best = &pkgMeta{Prefix: root, Vcs: m.Vcs, RepoRoot: source}
+		bestLiterals = literals
+	}
+	if best != nil {
+		gglog.Printf("importMapMeta %s %v\n", p, best)
+	}
+	return best
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestImportMapMatch(t *testing.T) {
+	tests := []struct {
+		m        ggImportMap
+		p        string
+		root     string
+		source   string
+		literals int
+		ok       bool
+	}{
+		{ggImportMap{"code.corp/lib", "git", "ssh://git@code.corp/lib.git"}, "code.corp/lib/sub", "code.corp/lib", "ssh://git@code.corp/lib.git", 2, true},
+		{ggImportMap{"/code.corp/lib/", "git", "ssh://git@code.corp/lib.git"}, "code.corp/lib", "code.corp/lib", "ssh://git@code.corp/lib.git", 2, true},
+		{ggImportMap{"code.corp/lib", "git", "ssh://git@code.corp/lib.git"}, "code.corp/library", "", "", 0, false},
+		{ggImportMap{"code.corp/lib", "git", "ssh://git@code.corp/lib.git"}, "code.corp", "", "", 0, false},
+
+		// placeholders match one element each, every use in Source is replaced
+		{ggImportMap{"code.corp/{1}/{2}", "git", "ssh://git@code.corp/{1}/{2}.git"}, "code.corp/team/repo/pkg", "code.corp/team/repo", "ssh://git@code.corp/team/repo.git", 1, true},
+		{ggImportMap{"code.corp/{1}", "hg", "https://hg.corp/{1}/{1}"}, "code.corp/repo", "code.corp/repo", "https://hg.corp/repo/repo", 1, true},
+		{ggImportMap{"{1}/go/{2}", "git", "https://{1}/scm/{2}.git"}, "eu.corp/go/repo/sub", "eu.corp/go/repo", "https://eu.corp/scm/repo.git", 1, true},
+		{ggImportMap{"{1}.corp/go", "git", "https://{1}.corp/go.git"}, "eu.corp/go", "", "", 0, false},
+		{ggImportMap{"code.corp/{1}/{2}", "git", "ssh://git@code.corp/{1}/{2}.git"}, "code.corp/team", "", "", 0, false},
+		{ggImportMap{"code.corp/{1}/go", "git", "ssh://git@code.corp/{1}.git"}, "code.corp/team/java", "", "", 0, false},
+	}
+	for _, test := range tests {
+		root, source, literals, ok := test.m.match(test.p)
+		if root != test.root || source != test.source || literals != test.literals || ok != test.ok {
+			t.Errorf("match %s %s = %q %q %d %v, want %q %q %d %v", test.m.Prefix, test.p,
+				root, source, literals, ok, test.root, test.source, test.literals, test.ok)
+		}
+	}
+}
+
+func TestImportMapMeta(t *testing.T) {
+	gglogDisable()
+	cmd := &ggcmd{importMaps: []*ggImportMap{
+		// user map first, as loadImportMaps orders them
+		{"code.corp/{1}", "git", "https://user.corp/{1}"},
+		{"code.corp/lib", "git", "https://user.corp/lib-mirror"},
+		{"code.corp/{1}", "git", "https://repo.corp/{1}"},
+		{"code.corp/lib", "hg", "https://repo.corp/lib"},
+		{"code.corp/{1}/{2}", "git", "https://repo.corp/{1}/{2}"},
+		{"code.corp/team/{1}", "git", "https://repo.corp/team/{1}"},
+	}}
+	tests := []struct {
+		p    string
+		want *pkgMeta
+	}{
+		// same length and placeholders, the user map wins
+		{"code.corp/app", &pkgMeta{Prefix: "code.corp/app", Vcs: "git", RepoRoot: "https://user.corp/app"}},
+		{"code.corp/lib", &pkgMeta{Prefix: "code.corp/lib", Vcs: "git", RepoRoot: "https://user.corp/lib-mirror"}},
+		// the longest prefix wins, then the one with fewer placeholders
+		{"code.corp/other/repo", &pkgMeta{Prefix: "code.corp/other/repo", Vcs: "git", RepoRoot: "https://repo.corp/other/repo"}},
+		{"code.corp/team/repo/pkg", &pkgMeta{Prefix: "code.corp/team/repo", Vcs: "git", RepoRoot: "https://repo.corp/team/repo"}},
+		{"other.corp/app", nil},
+	}
+	for _, test := range tests {
+		if meta := cmd.importMapMeta(test.p); !reflect.DeepEqual(meta, test.want) {
+			t.Errorf("importMapMeta %s = %+v, want %+v", test.p, meta, test.want)
+		}
+	}
+}
+
+func TestLoadImportMaps(t *testing.T) {
+	testAuthEnv(t, "")
+	vendorDir := t.TempDir()
+	files := map[string]string{
+		userImportMapFilename():                 `{"Mappings": [{"Prefix": "code.corp/{1}", "Vcs": "git", "Source": "https://user.corp/{1}"}]}`,
+		filepath.Join(vendorDir, "_ggmap.json"): `{"Mappings": [{"Prefix": "code.corp/{1}", "Vcs": "git", "Source": "https://repo.corp/{1}"}]}`,
+	}
+	for filename, content := range files {
+		err := os.MkdirAll(filepath.Dir(filename), 0755)
+		if err == nil {
+			err = ioutil.WriteFile(filename, []byte(content), 0644)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	cmd := &ggcmd{}
+	cmd.loadImportMaps(vendorDir)
+	if len(cmd.importMaps) != 2 || cmd.importMaps[0].Source != "https://user.corp/{1}" {
+		t.Fatalf("loadImportMaps = %v, want the user map first", cmd.importMaps)
+	}
+	if meta := cmd.importMapMeta("code.corp/app"); meta == nil || meta.RepoRoot != "https://user.corp/app" {
+		t.Errorf("importMapMeta = %+v, want the user mapping", meta)
+	}
+
+	cmd.loadImportMaps("")
+	if len(cmd.importMaps) != 1 {
+		t.Errorf("loadImportMaps without a vendor root = %v", cmd.importMaps)
+	}
+}
@@ -0,0 +1,108 @@
+package main
+
+//
+// repo roots of well known hosts, resolved from the import path alone as the
+// go tool does, the go-get meta lookup is only needed for other hosts
+//
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Pattern has a named group "root" for the repo root, Vcs and Source may use
+// any named group as {name}, e.g. "https://{root}"
+type ggKnownHost struct {
+	Pattern string
+	Vcs     string
+	Source  string
+
+	re *regexp.Regexp
+}
+
+const knownHostElem = `[A-Za-z0-9_.\-]+`
+
+var knownHostsBuiltin = []*ggKnownHost{
+	{Pattern: `^(?P<root>github\.com/` + knownHostElem + `/` + knownHostElem + `)(/` + knownHostElem + `)*$`,
+		Vcs: "git", Source: "https://{root}"},
+	{Pattern: `^(?P<root>bitbucket\.org/` + knownHostElem + `/` + knownHostElem + `)(/` + knownHostElem + `)*$`,
+		Vcs: "git", Source: "https://{root}"},
+	// no sub groups, those need the meta lookup
+	{Pattern: `^(?P<root>gitlab\.com/` + knownHostElem + `/` + knownHostElem + `)(/` + knownHostElem + `)*$`,
+		Vcs: "git", Source: "https://{root}"},
+	{Pattern: `^(?P<root>launchpad\.net/((` + knownHostElem + `)(/` + knownHostElem + `)?|~` + knownHostElem + `/(\+junk|` + knownHostElem + `)/` + knownHostElem + `))(/` + knownHostElem + `)*$`,
+		Vcs: "bzr", Source: "https://{root}"},
+	// gopkg.in/pkg.v1 is github.com/go-pkg/pkg, gopkg.in/user/pkg.v1 is
+	// github.com/user/pkg, gopkg.in serves the branch or tag of the version
+	{Pattern: `^(?P<root>gopkg\.in/([a-zA-Z0-9][-a-zA-Z0-9]*/)?[a-zA-Z][-.a-zA-Z0-9]*\.v[0-9]+(-unstable)?)(\.git)?(/[a-zA-Z0-9][-.a-zA-Z0-9]*)*$`,
+		Vcs: "git", Source: "https://{root}"},
+	{Pattern: `^(?P<root>golang\.org/x/(?P<repo>` + knownHostElem + `))(/` + knownHostElem + `)*$`,
+		Vcs: "git", Source: "https://go.googlesource.com/{repo}"},
+	{Pattern: `^(?P<root>google\.golang\.org/grpc)(/` + knownHostElem + `)*$`,
+		Vcs: "git", Source: "https://github.com/grpc/grpc-go"},
+	{Pattern: `^(?P<root>google\.golang\.org/protobuf)(/` + knownHostElem + `)*$`,
+		Vcs: "git", Source: "https://go.googlesource.com/protobuf"},
+	{Pattern: `^(?P<root>google\.golang\.org/api)(/` + knownHostElem + `)*$`,
+		Vcs: "git", Source: "https://github.com/googleapis/google-api-go-client"},
+	{Pattern: `^(?P<root>google\.golang\.org/appengine)(/` + knownHostElem + `)*$`,
+		Vcs: "git", Source: "https://github.com/golang/appengine"},
+	{Pattern: `^(?P<root>google\.golang\.org/genproto)(/` + knownHostElem + `)*$`,
+		Vcs: "git", Source: "https://github.com/googleapis/go-genproto"},
+	{Pattern: `^(?P<root>go\.googlesource\.com/` + knownHostElem + `)(/` + knownHostElem + `)*$`,
+		Vcs: "git", Source: "https://{root}"},
+	{Pattern: `^(?P<root>hub\.jazz\.net/git/[a-z0-9]+/` + knownHostElem + `)(/` + knownHostElem + `)*$`,
+		Vcs: "git", Source: "https://{root}"},
+	// example.org/repo.git/pkg, the suffix names the vcs
+	{Pattern: `^(?P<root>([a-z0-9\-]+\.)+[a-z0-9\-]+(:[0-9]+)?(/~?` + knownHostElem + `)+?\.(?P<vcs>git|hg))(/~?` + knownHostElem + `)*$`,
+		Vcs: "{vcs}", Source: "https://{root}"},
+}
+
+// user rules from GGHOME/config.json first, compiled once
+func (cmd *ggcmd) knownHosts() []*ggKnownHost {
+	if cmd.knownHostRules == nil {
+		rules := append([]*ggKnownHost{}, cmd.userConfig().KnownHosts...)
+		rules = append(rules, knownHostsBuiltin...)
+		for _, rule := range rules {
+			if rule.re != nil {
+				continue
+			}
+			re, err := regexp.Compile(rule.Pattern)
+			if err != nil {
+				ggFatal("Bad KnownHosts pattern %s in %s %s", rule.Pattern, ggConfigFilename(), err)
+			}
+			if re.SubexpIndex("root") < 0 {
+				ggFatal("KnownHosts pattern %s in %s has no (?P<root>...) group", rule.Pattern, ggConfigFilename())
+			}
+			rule.re = re
+		}
+		cmd.knownHostRules = rules
+	}
+	return cmd.knownHostRules
+}
+
+// nil when no rule knows p
+func (cmd *ggcmd) knownHostMeta(p string) *pkgMeta {
+	for _, rule := range cmd.knownHosts() {
+		match := rule.re.FindStringSubmatch(p)
+		if match == nil {
+			continue
+		}
+
+		expand := func(template string) string {
+			for i, name := range rule.re.SubexpNames() {
+				if name != "" {
+					template = strings.Replace(template, "{"+name+"}", match[i], -1)
+				}
+			}
+			return template
+		}
+		meta := &pkgMeta{
+			Prefix:   match[rule.re.SubexpIndex("root")],
+			Vcs:      expand(rule.Vcs),
+			RepoRoot: expand(rule.Source),
+		}
+		gglog.Printf("knownHostMeta %s %v\n", p, meta)
+		return meta
+	}
+	return nil
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestKnownHostMeta(t *testing.T) {
+	gglogDisable()
+	cmd := &ggcmd{config: &ggConfig{KnownHosts: []*ggKnownHost{
+		// user rules are tried first, before the built in github.com one
+		{Pattern: `^(?P<root>github\.com/corp/(?P<repo>[a-z]+))(/.*)?$`, Vcs: "git", Source: "https://git.corp.example/mirror/{repo}"},
+		{Pattern: `^(?P<root>code\.corp\.example/[a-z]+)(/.*)?$`, Vcs: "hg", Source: "ssh://hg@{root}"},
+	}}}
+	tests := []struct {
+		p    string
+		want *pkgMeta
+	}{
+		{"github.com/user/repo", &pkgMeta{Prefix: "github.com/user/repo", Vcs: "git", RepoRoot: "https://github.com/user/repo"}},
+		{"github.com/user/repo/sub/pkg", &pkgMeta{Prefix: "github.com/user/repo", Vcs: "git", RepoRoot: "https://github.com/user/repo"}},
+		{"github.com/user", nil},
+
+		// gopkg.in/pkg.vN and gopkg.in/user/pkg.vN
+		{"gopkg.in/yaml.v2", &pkgMeta{Prefix: "gopkg.in/yaml.v2", Vcs: "git", RepoRoot: "https://gopkg.in/yaml.v2"}},
+		{"gopkg.in/check.v1/sub", &pkgMeta{Prefix: "gopkg.in/check.v1", Vcs: "git", RepoRoot: "https://gopkg.in/check.v1"}},
+		{"gopkg.in/user/pkg.v3/sub", &pkgMeta{Prefix: "gopkg.in/user/pkg.v3", Vcs: "git", RepoRoot: "https://gopkg.in/user/pkg.v3"}},
+		{"gopkg.in/mgo.v2-unstable", &pkgMeta{Prefix: "gopkg.in/mgo.v2-unstable", Vcs: "git", RepoRoot: "https://gopkg.in/mgo.v2-unstable"}},
+		{"gopkg.in/yaml.v2.git/sub", &pkgMeta{Prefix: "gopkg.in/yaml.v2", Vcs: "git", RepoRoot: "https://gopkg.in/yaml.v2"}},
+		{"gopkg.in/yaml", nil},
+
+		// golang.org/x/repo lives on go.googlesource.com
+		{"golang.org/x/net/context", &pkgMeta{Prefix: "golang.org/x/net", Vcs: "git", RepoRoot: "https://go.googlesource.com/net"}},
+		{"golang.org/x/tools", &pkgMeta{Prefix: "golang.org/x/tools", Vcs: "git", RepoRoot: "https://go.googlesource.com/tools"}},
+		{"golang.org/x", nil},
+		{"google.golang.org/grpc/codes", &pkgMeta{Prefix: "google.golang.org/grpc", Vcs: "git", RepoRoot: "https://github.com/grpc/grpc-go"}},
+
+		// a .git or .hg element names the vcs
+		{"example.org/repo.git/pkg", &pkgMeta{Prefix: "example.org/repo.git", Vcs: "git", RepoRoot: "https://example.org/repo.git"}},
+		{"example.org/user/repo.hg", &pkgMeta{Prefix: "example.org/user/repo.hg", Vcs: "hg", RepoRoot: "https://example.org/user/repo.hg"}},
+		{"example.org:8080/repo.git", &pkgMeta{Prefix: "example.org:8080/repo.git", Vcs: "git", RepoRoot: "https://example.org:8080/repo.git"}},
+		{"example.org/repo.gitx/pkg", nil},
+		{"example.org/repo", nil},
+
+		// user rules
+		{"github.com/corp/tool/cmd", &pkgMeta{Prefix: "github.com/corp/tool", Vcs: "git", RepoRoot: "https://git.corp.example/mirror/tool"}},
+		{"code.corp.example/lib/sub", &pkgMeta{Prefix: "code.corp.example/lib", Vcs: "hg", RepoRoot: "ssh://hg@code.corp.example/lib"}},
+	}
+	for _, test := range tests {
+		if meta := cmd.knownHostMeta(test.p); !reflect.DeepEqual(meta, test.want) {
+			t.Errorf("knownHostMeta %s = %+v, want %+v", test.p, meta, test.want)
+		}
+	}
+}
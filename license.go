@@ -0,0 +1,283 @@
+package main
+
+//
+// offline license detection, word trigram similarity against known texts
+//
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+const (
+	licenseNone      = "NOASSERTION"        // no license file found
+	licenseUnknown   = "LicenseRef-Unknown" // license file found, not recognized
+	licenseThreshold = 0.75                 // part of the template found in the file
+)
+
+// distinctive parts of the license texts, copyright lines left out
+var licenseTemplates = map[string]string{
+	"MIT": `Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT.`,
+
+	"BSD-2-Clause": `Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+Redistributions of source code must retain the above copyright notice, this
+list of conditions and the following disclaimer.
+Redistributions in binary form must reproduce the above copyright notice,
+this list of conditions and the following disclaimer in the documentation
+and/or other materials provided with the distribution.
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED.`,
+
+	"BSD-3-Clause": `Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+Redistributions of source code must retain the above copyright notice, this
+list of conditions and the following disclaimer.
+Redistributions in binary form must reproduce the above copyright notice,
+this list of conditions and the following disclaimer in the documentation
+and/or other materials provided with the distribution.
+Neither the name of the copyright holder nor the names of its
+contributors may be used to endorse or promote products derived from
+this software without specific prior written permission.
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED.`,
+
+	"ISC": `Permission to use, copy, modify, and/or distribute this software for any
+purpose with or without fee is hereby granted, provided that the above
+copyright notice and this permission notice appear in all copies.
+THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+MERCHANTABILITY AND FITNESS.`,
+
+	"Apache-2.0": `Apache License Version 2.0, January 2004 http://www.apache.org/licenses/
+TERMS AND CONDITIONS FOR USE, REPRODUCTION, AND DISTRIBUTION
+1. Definitions.
+"License" shall mean the terms and conditions for use, reproduction,
+and distribution as defined by Sections 1 through 9 of this document.
+"Licensor" shall mean the copyright owner or entity authorized by
+the copyright owner that is granting the License.`,
+
+	"GPL-2.0": `GNU GENERAL PUBLIC LICENSE Version 2, June 1991
+Everyone is permitted to copy and distribute verbatim copies
+of this license document, but changing it is not allowed.
+Preamble
+The licenses for most software are designed to take away your
+freedom to share and change it. By contrast, the GNU General Public
+License is intended to guarantee your freedom to share and change free
+software--to make sure the software is free for all its users.`,
+
+	"GPL-3.0": `GNU GENERAL PUBLIC LICENSE Version 3, 29 June 2007
+Everyone is permitted to copy and distribute verbatim copies
+of this license document, but changing it is not allowed.
+Preamble
+The GNU General Public License is a free, copyleft license for
+software and other kinds of works.`,
+
+	"LGPL-2.1": `GNU LESSER GENERAL PUBLIC LICENSE Version 2.1, February 1999
+Everyone is permitted to copy and distribute verbatim copies
+of this license document, but changing it is not allowed.
+[This is the first released version of the Lesser GPL. It also counts
+as the successor of the GNU Library Public License, version 2, hence
+the version number 2.1.]`,
+
+	"LGPL-3.0": `GNU LESSER GENERAL PUBLIC LICENSE Version 3, 29 June 2007
+Everyone is permitted to copy and distribute verbatim copies
+of this license document, but changing it is not allowed.
+This version of the GNU Lesser General Public License incorporates
+the terms and conditions of version 3 of the GNU General Public
+License, supplemented by the additional permissions listed below.`,
+
+	"AGPL-3.0": `GNU AFFERO GENERAL PUBLIC LICENSE Version 3, 19 November 2007
+Everyone is permitted to copy and distribute verbatim copies
+of this license document, but changing it is not allowed.
+Preamble
+The GNU Affero General Public License is a free, copyleft license for
+software and other kinds of works, specifically designed to ensure
+cooperation with the community in the case of network server software.`,
+
+	"MPL-2.0": `Mozilla Public License Version 2.0
+1. Definitions
+1.1. "Contributor" means each individual or legal entity that creates,
+contributes to the creation of, or owns Covered Software.
+1.2. "Contributor Version" means the combination of the Contributions
+of others (if any) used by a Contributor and that particular
+Contributor's Contribution.`,
+
+	"Unlicense": `This is free and unencumbered software released into the public domain.
+Anyone is free to copy, modify, publish, use, compile, sell, or
+distribute this software, either in source code form or as a compiled
+binary, for any purpose, commercial or non-commercial, and by any
+means.`,
+
+	"CC0-1.0": `Creative Commons Legal Code CC0 1.0 Universal
+CREATIVE COMMONS CORPORATION IS NOT A LAW FIRM AND DOES NOT PROVIDE
+LEGAL SERVICES. DISTRIBUTION OF THIS DOCUMENT DOES NOT CREATE AN
+ATTORNEY-CLIENT RELATIONSHIP.`,
+
+	"Zlib": `This software is provided 'as-is', without any express or implied
+warranty. In no event will the authors be held liable for any damages
+arising from the use of this software.
+Permission is granted to anyone to use this software for any purpose,
+including commercial applications, and to alter it and redistribute it
+freely, subject to the following restrictions:`,
+}
+
+// template trigrams, built on first use
+var licenseTemplateShingles map[string]map[string]bool
+
+func licenseWords(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+func licenseShingles(text string) map[string]bool {
+	words := licenseWords(text)
+	shingles := map[string]bool{}
+	for i := 0; i+3 <= len(words); i++ {
+		shingles[strings.Join(words[i:i+3], " ")] = true
+	}
+	return shingles
+}
+
+// spdx id, score
+func classifyLicense(text string) (string, float64) {
+	if licenseTemplateShingles == nil {
+		licenseTemplateShingles = map[string]map[string]bool{}
+		for id, template := range licenseTemplates {
+			licenseTemplateShingles[id] = licenseShingles(template)
+		}
+	}
+
+	docShingles := licenseShingles(text)
+	bestId, bestScore, bestMatched := licenseUnknown, 0.0, 0
+	for id, templateShingles := range licenseTemplateShingles {
+		matched := 0
+		for shingle := range templateShingles {
+			if docShingles[shingle] {
+				matched++
+			}
+		}
+		score := float64(matched) / float64(len(templateShingles))
+		if score < licenseThreshold {
+			continue
+		}
+		// on close scores, longer templates are more specific, e.g. BSD-3 over BSD-2
+		closeScore := score-bestScore < 0.05 && bestScore-score < 0.05
+		if (closeScore && matched > bestMatched) || (!closeScore && score > bestScore) {
+			bestId, bestScore, bestMatched = id, score, matched
+		}
+	}
+	return bestId, bestScore
+}
+
+// files holding the license itself, as opposed to notices or authors
+func isLicenseTextFile(name string) bool {
+	lower := strings.ToLower(name)
+	for _, prefix := range []string{"license", "licence", "copying", "unlicense"} {
+		if strings.HasPrefix(lower, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+type licenseFile struct {
+	Path    string // relative to package dir
+	License string // spdx id, empty for notice files
+}
+
+// license and notice files in a vendored package tree
+func detectLicenses(dir string) ([]licenseFile, error) {
+	var found []licenseFile
+	err := filepath.Walk(dir, func(path string, f os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if f.IsDir() {
+			// samples in testdata, other packages' licenses in vendor
+			if path != dir && (strings.HasPrefix(f.Name(), ".") || f.Name() == "testdata" || f.Name() == "vendor") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		// not license.go and friends, see isLicenseFile
+		if !isLicenseFile(f.Name()) {
+			return nil
+		}
+
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		lf := licenseFile{Path: filepath.ToSlash(rel)}
+		if isLicenseTextFile(f.Name()) {
+			content, err := readFileString(path)
+			if err != nil {
+				return err
+			}
+			lf.License, _ = classifyLicense(content)
+		}
+		found = append(found, lf)
+		return nil
+	})
+	return found, err
+}
+
+// combined spdx expression for the license files found
+func licenseExpression(files []licenseFile) string {
+	seen := map[string]bool{}
+	var ids []string
+	for _, lf := range files {
+		if lf.License != "" && !seen[lf.License] {
+			seen[lf.License] = true
+			ids = append(ids, lf.License)
+		}
+	}
+	if len(ids) == 0 {
+		return licenseNone
+	}
+	sort.Strings(ids)
+	return strings.Join(ids, " AND ")
+}
+
+// reasons a license expression is flagged under the policy, empty if fine
+func licensePolicyFlag(expression string, policy *ggvLicensePolicy) string {
+	if expression == licenseNone {
+		return "no license found"
+	}
+
+	var flags []string
+	for _, id := range strings.Split(expression, " AND ") {
+		if id == licenseUnknown {
+			flags = append(flags, "unknown license")
+			continue
+		}
+		if policy == nil {
+			continue
+		}
+		if stringInSlice(id, policy.Deny) {
+			flags = append(flags, id+" denied")
+		} else if len(policy.Allow) > 0 && !stringInSlice(id, policy.Allow) {
+			flags = append(flags, id+" not allowed")
+		}
+	}
+	return strings.Join(flags, ", ")
+}
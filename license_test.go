@@ -0,0 +1,191 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+const testMitLicense = `The MIT License (MIT)
+
+Copyright (c) 2014 Some One
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+`
+
+const testBsd2License = `Copyright (c) 2013, Some One
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice, this
+   list of conditions and the following disclaimer.
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT OWNER OR CONTRIBUTORS BE LIABLE FOR
+ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES.
+`
+
+const testBsd3License = `Copyright (c) 2009 The Go Authors. All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are
+met:
+
+   * Redistributions of source code must retain the above copyright
+notice, this list of conditions and the following disclaimer.
+   * Redistributions in binary form must reproduce the above
+copyright notice, this list of conditions and the following disclaimer
+in the documentation and/or other materials provided with the
+distribution.
+   * Neither the name of Google Inc. nor the names of its
+contributors may be used to endorse or promote products derived from
+this software without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+"AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+A PARTICULAR PURPOSE ARE DISCLAIMED.
+`
+
+const testIscLicense = `ISC License
+
+Copyright (c) 2012 Some One
+
+Permission to use, copy, modify, and/or distribute this software for any
+purpose with or without fee is hereby granted, provided that the above
+copyright notice and this permission notice appear in all copies.
+
+THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES.
+`
+
+const testApacheLicense = `
+                                 Apache License
+                           Version 2.0, January 2004
+                        http://www.apache.org/licenses/
+
+   TERMS AND CONDITIONS FOR USE, REPRODUCTION, AND DISTRIBUTION
+
+   1. Definitions.
+
+      "License" shall mean the terms and conditions for use, reproduction,
+      and distribution as defined by Sections 1 through 9 of this document.
+
+      "Licensor" shall mean the copyright owner or entity authorized by
+      the copyright owner that is granting the License.
+
+      "Legal Entity" shall mean the union of the acting entity and all
+      other entities that control, are controlled by, or are under common
+      control with that entity.
+`
+
+func TestClassifyLicense(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		id   string
+	}{
+		{"mit", testMitLicense, "MIT"},
+		{"bsd-2", testBsd2License, "BSD-2-Clause"},
+		{"bsd-3", testBsd3License, "BSD-3-Clause"},
+		{"isc", testIscLicense, "ISC"},
+		{"apache", testApacheLicense, "Apache-2.0"},
+		{"unlicense", licenseTemplates["Unlicense"], "Unlicense"},
+		{"mpl", licenseTemplates["MPL-2.0"], "MPL-2.0"},
+		{"gpl-3", licenseTemplates["GPL-3.0"], "GPL-3.0"},
+		{"lgpl-3", licenseTemplates["LGPL-3.0"], "LGPL-3.0"},
+		{"not a license", "Copyright 2015 Some One. Do what you like, but tell me about it.", licenseUnknown},
+		{"empty", "", licenseUnknown},
+	}
+	for _, test := range tests {
+		id, score := classifyLicense(test.text)
+		if id != test.id {
+			t.Errorf("%s: classifyLicense = %s (%.2f), want %s", test.name, id, score, test.id)
+		}
+	}
+}
+
+func TestDetectLicenses(t *testing.T) {
+	dir := testPruneTree(t, map[string]string{
+		"LICENSE":                      testMitLicense,
+		"NOTICE":                       "Some One",
+		"license.go":                   "package a\n\n// license checks\n",
+		"license_test.go":              "package a\n",
+		"sub/COPYING":                  testBsd2License,
+		"testdata/LICENSE":             "a sample license to test against",
+		"vendor/example.com/b/LICENSE": "GPL something",
+		".git/LICENSE":                 "not a file of the package",
+	})
+	found, err := detectLicenses(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []licenseFile{{"LICENSE", "MIT"}, {"NOTICE", ""}, {"sub/COPYING", "BSD-2-Clause"}}
+	if !reflect.DeepEqual(found, want) {
+		t.Errorf("detectLicenses = %v, want %v", found, want)
+	}
+	if expression := licenseExpression(found); expression != "BSD-2-Clause AND MIT" {
+		t.Errorf("licenseExpression of the package = %s", expression)
+	}
+}
+
+func TestLicenseExpression(t *testing.T) {
+	tests := []struct {
+		files      []licenseFile
+		expression string
+	}{
+		{nil, licenseNone},
+		{[]licenseFile{{"NOTICE", ""}}, licenseNone},
+		{[]licenseFile{{"LICENSE", "MIT"}, {"NOTICE", ""}}, "MIT"},
+		{[]licenseFile{{"LICENSE", "MIT"}, {"sub/LICENSE", "Apache-2.0"}, {"other/LICENSE", "MIT"}}, "Apache-2.0 AND MIT"},
+	}
+	for _, test := range tests {
+		if expression := licenseExpression(test.files); expression != test.expression {
+			t.Errorf("licenseExpression(%v) = %s, want %s", test.files, expression, test.expression)
+		}
+	}
+}
+
+func TestLicensePolicyFlag(t *testing.T) {
+	tests := []struct {
+		expression string
+		policy     *ggvLicensePolicy
+		flag       string
+	}{
+		{"MIT", nil, ""},
+		{licenseNone, nil, "no license found"},
+		{licenseUnknown, nil, "unknown license"},
+		{"GPL-3.0", &ggvLicensePolicy{Deny: []string{"GPL-3.0"}}, "GPL-3.0 denied"},
+		{"MIT AND MPL-2.0", &ggvLicensePolicy{Allow: []string{"MIT"}}, "MPL-2.0 not allowed"},
+		{"MIT AND " + licenseUnknown, &ggvLicensePolicy{Allow: []string{"MIT"}}, "unknown license"},
+	}
+	for _, test := range tests {
+		if flag := licensePolicyFlag(test.expression, test.policy); flag != test.flag {
+			t.Errorf("licensePolicyFlag(%s, %v) = %q, want %q", test.expression, test.policy, flag, test.flag)
+		}
+	}
+}
@@ -0,0 +1,148 @@
+package main
+
+//
+// split layout, _ggv.json is what is wanted and edited by hand,
+// _ggv.lock.json next to it is what was resolved and only written by gg
+//
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+)
+
+// resolved state of a package
+type ggvLockedPackage struct {
+	Revision   string
+	LastUpdate string
+	Version    string `json:",omitempty"`
+	ZipHash    string `json:",omitempty"`
+	TreeHash   string `json:",omitempty"`
+}
+
+type ggvLockJson struct {
+	Version  string
+	Packages map[string]*ggvLockedPackage // key is canonical pkg name, as in _ggv.json
+}
+
+func ggvLockFilename(vendorFilename string) string {
+	return filepath.Join(filepath.Dir(vendorFilename), "_ggv.lock.json")
+}
+
+func (info *ggvPackage) locked() *ggvLockedPackage {
+	return &ggvLockedPackage{
+		Revision:   info.Revision,
+		LastUpdate: info.LastUpdate,
+		Version:    info.Version,
+		ZipHash:    info.ZipHash,
+		TreeHash:   info.TreeHash,
+	}
+}
+
+func (info *ggvPackage) setLocked(locked *ggvLockedPackage) {
+	info.Revision = locked.Revision
+	info.LastUpdate = locked.LastUpdate
+	info.Version = locked.Version
+	info.ZipHash = locked.ZipHash
+	info.TreeHash = locked.TreeHash
+}
+
+// to fetch info again: its settings, LastUpdate until something changes,
+// the module version and zip hash to check a proxy zip against, and the
+// revision when kept. TreeHash is left to the download.
+func (info *ggvPackage) refetch(keepRevision bool) *ggvPackage {
+	refetch := *info
+	refetch.TreeHash = ""
+	if !keepRevision {
+		refetch.Revision = ""
+	}
+	return &refetch
+}
+
+// the resolved state from the lock file next to vendorFilename, when there
+// is one. What the manifest says about it is ignored.
+func (ggv *ggvJson) readLock(vendorFilename string) error {
+	lockFilename := ggvLockFilename(vendorFilename)
+	content, err := ioutil.ReadFile(lockFilename)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	var raw map[string]interface{}
+	err = json.Unmarshal(content, &raw)
+	if err != nil {
+		return fmt.Errorf("%s %s", lockFilename, err)
+	}
+	version, _ := raw["Version"].(string)
+	if compareVersions(version, ggvSchemaVersion) > 0 {
+		return fmt.Errorf("%s is schema version %s, this gg only knows up to %s. Please update gg.", lockFilename, version, ggvSchemaVersion)
+	}
+
+	var lock ggvLockJson
+	err = json.Unmarshal(content, &lock)
+	if err != nil {
+		return fmt.Errorf("%s %s", lockFilename, err)
+	}
+	unknown := unknownJsonFields(raw, reflect.TypeOf(lock), "")
+	if len(unknown) > 0 {
+		ggWarn("%s has fields this gg does not know, they are ignored: %s", lockFilename, strings.Join(unknown, ", "))
+		for _, field := range unknown {
+			ggv.unknownFields = append(ggv.unknownFields, filepath.Base(lockFilename)+" "+field)
+		}
+	}
+
+	ggv.split = true
+	for p, info := range ggv.Packages {
+		if *info.locked() != (ggvLockedPackage{}) {
+			ggWarn("Resolved state of %s in %s is ignored, it is kept in %s.", p, filepath.Base(vendorFilename), filepath.Base(lockFilename))
+		}
+		locked := lock.Packages[p]
+		if locked == nil {
+			locked = &ggvLockedPackage{}
+		}
+		info.setLocked(locked)
+	}
+	for p := range lock.Packages {
+		if ggv.Packages[p] == nil {
+			ggWarn("%s has %s, which is not in %s. It is ignored.", filepath.Base(lockFilename), p, filepath.Base(vendorFilename))
+		}
+	}
+	return nil
+}
+
+// manifest without the resolved state, and the lock file
+func (ggv *ggvJson) saveSplit(vendorFilename string) error {
+	manifest := *ggv
+	manifest.Packages = map[string]*ggvPackage{}
+	lock := ggvLockJson{Version: ggvSchemaVersion, Packages: map[string]*ggvLockedPackage{}}
+	for p, info := range ggv.Packages {
+		if locked := info.locked(); *locked != (ggvLockedPackage{}) {
+			lock.Packages[p] = locked
+		}
+		intent := *info
+		intent.setLocked(&ggvLockedPackage{})
+		manifest.Packages[p] = &intent
+	}
+
+	b, err := json.MarshalIndent(&manifest, "", "    ")
+	if err != nil {
+		return err
+	}
+	err = ioutil.WriteFile(vendorFilename, b, os.ModePerm)
+	if err != nil {
+		return err
+	}
+
+	lockContent, err := json.MarshalIndent(&lock, "", "    ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(ggvLockFilename(vendorFilename), lockContent, 0644)
+}
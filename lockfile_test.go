@@ -0,0 +1,82 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestSaveSplit(t *testing.T) {
+	gglogDisable()
+	dir := t.TempDir()
+	vendorFilename := filepath.Join(dir, "_ggv.json")
+	info := &ggvPackage{
+		LastUpdate: "2020-01-02T03:04:05",
+		Vcs:        "git",
+		VcsSource:  "https://git.example.com/a",
+		Revision:   "abc",
+		Lock:       true,
+		License:    "MIT",
+		TreeHash:   "h1:tree",
+		Include:    []string{"*.go"},
+	}
+	ggv := &ggvJson{VendorPrefix: "example.com/vendor", Packages: map[string]*ggvPackage{"example.com/a": info}, split: true}
+	err := ggv.saveGvv(vendorFilename)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var manifest ggvJson
+	content, _ := ioutil.ReadFile(vendorFilename)
+	json.Unmarshal(content, &manifest)
+	want := ggvPackage{Vcs: "git", VcsSource: "https://git.example.com/a", Lock: true, License: "MIT", Include: []string{"*.go"}}
+	if got := manifest.Packages["example.com/a"]; got == nil || !reflect.DeepEqual(*got, want) {
+		t.Errorf("_ggv.json has %+v, want %+v", got, want)
+	}
+
+	var lock ggvLockJson
+	content, _ = ioutil.ReadFile(ggvLockFilename(vendorFilename))
+	json.Unmarshal(content, &lock)
+	wantLocked := ggvLockedPackage{Revision: "abc", LastUpdate: "2020-01-02T03:04:05", TreeHash: "h1:tree"}
+	if got := lock.Packages["example.com/a"]; got == nil || *got != wantLocked {
+		t.Errorf("_ggv.lock.json has %+v, want %+v", got, wantLocked)
+	}
+
+	read := &ggvJson{Packages: manifest.Packages}
+	err = read.readLock(vendorFilename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := read.Packages["example.com/a"]; !reflect.DeepEqual(got, info) || !read.split {
+		t.Errorf("read back %+v, want %+v", got, info)
+	}
+}
+
+func TestRefetch(t *testing.T) {
+	info := &ggvPackage{
+		LastUpdate: "2020-01-02T03:04:05",
+		Vcs:        "proxy",
+		VcsSource:  "https://proxy.example.com/example.com/a",
+		Revision:   "v1.0.0",
+		Notes:      "keep",
+		License:    "MIT",
+		Version:    "v1.0.0",
+		ZipHash:    "h1:zip",
+		TreeHash:   "h1:tree",
+		Patches:    []string{"_ggpatches/a.patch"},
+	}
+	want := *info
+	want.TreeHash = ""
+	if got := info.refetch(true); !reflect.DeepEqual(*got, want) {
+		t.Errorf("refetch(true) = %+v, want %+v", got, want)
+	}
+	want.Revision = ""
+	if got := info.refetch(false); !reflect.DeepEqual(*got, want) {
+		t.Errorf("refetch(false) = %+v, want %+v", got, want)
+	}
+	if info.TreeHash != "h1:tree" || info.Revision != "v1.0.0" {
+		t.Errorf("refetch changed the package it copies, %+v", info)
+	}
+}
@@ -0,0 +1,301 @@
+package main
+
+//
+// go-get meta discovery, as cmd/go does it
+// https://golang.org/cmd/go/#hdr-Remote_import_paths
+//
+
+import (
+	"encoding/xml"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"time"
+)
+
+const (
+	metaTimeout = 30 * time.Second
+	metaRetries = 3
+)
+
+// wait before retry n is n times this
+var metaRetryDelay = time.Second
+
+// go-import, with the go-source of the same prefix when there is one
+type pkgMeta struct {
+	Prefix   string // import path of the repo root
+	Vcs      string // git, hg, proxy (for mod)
+	RepoRoot string
+	Subdir   string         `json:",omitempty"` // optional fourth field, package dir in the repo
+	Source   *pkgMetaSource `json:",omitempty"` // nil without a go-source tag
+}
+
+// go-source, links for documentation sites
+type pkgMetaSource struct {
+	Prefix    string
+	Home      string
+	Directory string
+	File      string
+}
+
+type pkgMetaTag struct {
+	Name    string
+	Content []string
+}
+
+// no go-import for the path, as opposed to network trouble
+var errNoMeta = errors.New("no go-import meta tag found")
+
+// timeouts on connecting and waiting for an answer, not on the download itself
+var ggHttpClient = &http.Client{
+	Transport: &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		DialContext:           (&net.Dialer{Timeout: metaTimeout}).DialContext,
+		TLSHandshakeTimeout:   metaTimeout,
+		ResponseHeaderTimeout: metaTimeout,
+	},
+	CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		if len(via) >= 10 {
+			return errors.New("stopped after 10 redirects")
+		}
+		// no downgrade behind our back
+		if via[0].URL.Scheme == "https" && req.URL.Scheme != "https" && !isInsecureHost(req.URL.Hostname()) {
+			return errors.New("refusing redirect from https to " + req.URL.String())
+		}
+		// credentials only go where they were meant for
+		if req.URL.Scheme != "https" || req.URL.Host != via[0].URL.Host {
+			req.Header.Del("Authorization")
+		}
+		return nil
+	},
+}
+
+// hosts allowed over plain http, GOINSECURE patterns as for go
+func isInsecureHost(host string) bool {
+	for _, pattern := range splitCommaList(os.Getenv("GOINSECURE")) {
+		pattern = strings.SplitN(pattern, "/", 2)[0]
+		if matched, _ := path.Match(pattern, host); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// charsets that are plain ascii as far as meta tags go
+func metaCharsetReader(charset string, input io.Reader) (io.Reader, error) {
+	switch strings.ToLower(charset) {
+	case "utf-8", "utf8", "ascii", "us-ascii", "iso-8859-1", "latin1":
+		return input, nil
+	}
+	return nil, errors.New("can't decode XML document using charset " + charset)
+}
+
+// meta tags in the head, html is read with the non strict xml decoder
+func parseMetaTags(r io.Reader) ([]pkgMetaTag, error) {
+	d := xml.NewDecoder(r)
+	d.CharsetReader = metaCharsetReader
+	d.Strict = false
+	d.AutoClose = xml.HTMLAutoClose
+	d.Entity = xml.HTMLEntity
+
+	var tags []pkgMetaTag
+	for {
+		t, err := d.RawToken()
+		if err != nil {
+			if err == io.EOF || len(tags) > 0 {
+				err = nil
+			}
+			return tags, err
+		}
+		if e, ok := t.(xml.StartElement); ok && strings.EqualFold(e.Name.Local, "body") {
+			return tags, nil
+		}
+		if e, ok := t.(xml.EndElement); ok && strings.EqualFold(e.Name.Local, "head") {
+			return tags, nil
+		}
+		e, ok := t.(xml.StartElement)
+		if !ok || !strings.EqualFold(e.Name.Local, "meta") {
+			continue
+		}
+
+		var tag pkgMetaTag
+		for _, attr := range e.Attr {
+			if strings.EqualFold(attr.Name.Local, "name") {
+				tag.Name = strings.ToLower(attr.Value)
+			} else if strings.EqualFold(attr.Name.Local, "content") {
+				tag.Content = strings.Fields(attr.Value)
+			}
+		}
+		if tag.Name == "go-import" || tag.Name == "go-source" {
+			tags = append(tags, tag)
+		}
+	}
+}
+
+// is prefix p, or a parent of p
+func metaPrefixMatches(prefix string, p string) bool {
+	return p == prefix || strings.HasPrefix(p, prefix+"/")
+}
+
+// longest go-import prefix of p, with its go-source
+func chooseMeta(p string, tags []pkgMetaTag) (*pkgMeta, error) {
+	var best *pkgMeta
+	for _, tag := range tags {
+		if tag.Name != "go-import" || (len(tag.Content) != 3 && len(tag.Content) != 4) {
+			continue
+		}
+		if !metaPrefixMatches(tag.Content[0], p) {
+			continue
+		}
+		if best != nil && len(tag.Content[0]) < len(best.Prefix) {
+			continue
+		}
+		if best != nil && len(tag.Content[0]) == len(best.Prefix) {
+			// mod entries come along with the vcs one, prefer the vcs
+			if tag.Content[1] == "mod" {
+				continue
+			}
+			if best.Vcs != "proxy" {
+				return nil, errors.New("multiple go-import meta tags for " + tag.Content[0])
+			}
+		}
+
+		meta := &pkgMeta{Prefix: tag.Content[0], Vcs: tag.Content[1], RepoRoot: tag.Content[2]}
+		if len(tag.Content) == 4 {
+			meta.Subdir = strings.Trim(tag.Content[3], "/")
+		}
+		if meta.Vcs == "mod" {
+			// module proxy for this path
+			meta.Vcs = "proxy"
+			meta.RepoRoot = strings.TrimSuffix(meta.RepoRoot, "/") + "/" + proxyEscapePath(meta.Prefix)
+		}
+		best = meta
+	}
+	if best == nil {
+		return nil, errNoMeta
+	}
+
+	for _, tag := range tags {
+		if tag.Name == "go-source" && len(tag.Content) == 4 && tag.Content[0] == best.Prefix {
+			best.Source = &pkgMetaSource{tag.Content[0], tag.Content[1], tag.Content[2], tag.Content[3]}
+		}
+	}
+	return best, nil
+}
+
+// with retries on timeouts and server errors
+func (cmd *ggcmd) metaGet(url string) (*http.Response, error) {
+	var resp *http.Response
+	var err error
+	for attempt := 1; attempt <= metaRetries; attempt++ {
+		resp, err = cmd.httpGetWithAuth(url)
+		if _, ok := err.(*authError); ok {
+			return nil, err
+		}
+		if err == nil && resp.StatusCode < 500 {
+			return resp, nil
+		}
+		if err == nil {
+			resp.Body.Close()
+			err = errors.New(url + " " + resp.Status)
+		}
+		var netErr net.Error
+		if resp == nil && !(errors.As(err, &netErr) && netErr.Timeout()) {
+			// no such host, bad certificate and the like, asking again will not help
+			return nil, err
+		}
+		gglog.Printf("metaGet %s attempt %d %v\n", url, attempt, err)
+		if attempt < metaRetries {
+			time.Sleep(time.Duration(attempt) * metaRetryDelay)
+		}
+	}
+	return nil, err
+}
+
+// meta of p from its own page
+func (cmd *ggcmd) fetchPkgMeta(p string) (*pkgMeta, error) {
+	host := strings.SplitN(p, "/", 2)[0]
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	schemes := []string{"https"}
+	if isInsecureHost(host) {
+		schemes = append(schemes, "http")
+	}
+
+	var err error
+	for _, scheme := range schemes {
+		var resp *http.Response
+		resp, err = cmd.metaGet(scheme + "://" + p + "?go-get=1")
+		if err != nil {
+			if _, ok := err.(*authError); ok {
+				return nil, err
+			}
+			continue
+		}
+
+		tags, parseErr := parseMetaTags(resp.Body)
+		resp.Body.Close()
+		if parseErr != nil {
+			gglog.Printf("fetchPkgMeta %s %v\n", p, parseErr)
+		}
+
+		meta, err := chooseMeta(p, tags)
+		if err == errNoMeta {
+			gglog.Printf("fetchPkgMeta %s no meta, %s\n", p, resp.Status)
+		}
+		return meta, err
+	}
+	return nil, err
+}
+
+// authError when the host wants credentials, errNoMeta when there is no
+// go-import for p or a parent
+// import maps and known hosts need no lookup, otherwise answers from the meta
+// cache unless refreshing, offline it is the only source
+func (cmd *ggcmd) getPkgMeta(p string) (*pkgMeta, error) {
+	if meta := cmd.importMapMeta(p); meta != nil {
+		return meta, nil
+	}
+	if meta := cmd.knownHostMeta(p); meta != nil {
+		return meta, nil
+	}
+	if !cmd.metaRefresh || isOffline() {
+		if meta, found, err := cmd.cachedPkgMeta(p, isOffline()); found {
+			gglog.Printf("getPkgMeta %s from cache %v\n", p, err)
+			return meta, err
+		}
+	}
+	if isOffline() {
+		return nil, &offlineError{p, ""}
+	}
+
+	meta, err := cmd.lookupPkgMeta(p)
+	cmd.cacheMeta(p, meta, err)
+	return meta, err
+}
+
+func (cmd *ggcmd) lookupPkgMeta(p string) (*pkgMeta, error) {
+	// servers should answer for any path in the repo, some only do for the root
+	try := p
+	for {
+		meta, err := cmd.fetchPkgMeta(try)
+		if err == nil {
+			if !metaPrefixMatches(meta.Prefix, p) {
+				return nil, errors.New("go-import prefix " + meta.Prefix + " does not match " + p)
+			}
+			return meta, nil
+		}
+		gglog.Printf("getPkgMeta %s %v\n", try, err)
+
+		// only a clean not found moves on to the parent
+		if err != errNoMeta || !strings.Contains(try, "/") {
+			return nil, err
+		}
+		try = path.Dir(try)
+	}
+}
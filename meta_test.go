@@ -0,0 +1,249 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseMetaTags(t *testing.T) {
+	page := `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta content="example.com/a git https://git.example.com/a" name="go-import">
+<META NAME='go-source' CONTENT='example.com/a https://example.com/a https://example.com/a/tree{/dir} https://example.com/a/blob{/dir}/{file}#L{line}'>
+<meta name=go-import content="example.com/b   hg   https://hg.example.com/b">
+<meta name="description" content="not ours">
+<link rel="stylesheet" href="x.css">
+</head>
+<body>
+<meta name="go-import" content="example.com/c git https://git.example.com/c">
+</body>
+</html>`
+	tags, err := parseMetaTags(strings.NewReader(page))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []pkgMetaTag{
+		{"go-import", []string{"example.com/a", "git", "https://git.example.com/a"}},
+		{"go-source", []string{"example.com/a", "https://example.com/a", "https://example.com/a/tree{/dir}", "https://example.com/a/blob{/dir}/{file}#L{line}"}},
+		{"go-import", []string{"example.com/b", "hg", "https://hg.example.com/b"}},
+	}
+	if !reflect.DeepEqual(tags, want) {
+		t.Errorf("parseMetaTags = %v, want %v", tags, want)
+	}
+}
+
+func TestChooseMeta(t *testing.T) {
+	goImport := func(content string) pkgMetaTag {
+		return pkgMetaTag{"go-import", strings.Fields(content)}
+	}
+	goSource := func(content string) pkgMetaTag {
+		return pkgMetaTag{"go-source", strings.Fields(content)}
+	}
+
+	tests := []struct {
+		p    string
+		tags []pkgMetaTag
+		want *pkgMeta
+		err  string
+	}{
+		{"example.com/a/b", []pkgMetaTag{
+			goImport("example.com/a git https://git.example.com/a"),
+			goImport("example.com/a/b git https://git.example.com/ab"),
+			goImport("example.com/ab git https://git.example.com/other"),
+		}, &pkgMeta{Prefix: "example.com/a/b", Vcs: "git", RepoRoot: "https://git.example.com/ab"}, ""},
+		{"example.com/a/b/c", []pkgMetaTag{
+			goImport("example.com/a/b git https://git.example.com/ab"),
+			goImport("example.com/a git https://git.example.com/a"),
+		}, &pkgMeta{Prefix: "example.com/a/b", Vcs: "git", RepoRoot: "https://git.example.com/ab"}, ""},
+		{"example.com/mono/x", []pkgMetaTag{
+			goImport("example.com/mono/x git https://git.example.com/mono /go/x/"),
+		}, &pkgMeta{Prefix: "example.com/mono/x", Vcs: "git", RepoRoot: "https://git.example.com/mono", Subdir: "go/x"}, ""},
+		{"example.com/a", []pkgMetaTag{
+			goSource("example.com/a https://example.com/a https://example.com/a/tree{/dir} https://example.com/a/blob{/dir}/{file}#L{line}"),
+			goSource("example.com/other https://example.com/o {dir} {file}"),
+			goImport("example.com/a git https://git.example.com/a"),
+		}, &pkgMeta{Prefix: "example.com/a", Vcs: "git", RepoRoot: "https://git.example.com/a",
+			Source: &pkgMetaSource{"example.com/a", "https://example.com/a", "https://example.com/a/tree{/dir}", "https://example.com/a/blob{/dir}/{file}#L{line}"}}, ""},
+
+		// mod comes along with the vcs entry, in either order
+		{"example.com/m", []pkgMetaTag{
+			goImport("example.com/m mod https://proxy.example.com"),
+			goImport("example.com/m git https://git.example.com/m"),
+		}, &pkgMeta{Prefix: "example.com/m", Vcs: "git", RepoRoot: "https://git.example.com/m"}, ""},
+		{"example.com/m", []pkgMetaTag{
+			goImport("example.com/m git https://git.example.com/m"),
+			goImport("example.com/m mod https://proxy.example.com"),
+		}, &pkgMeta{Prefix: "example.com/m", Vcs: "git", RepoRoot: "https://git.example.com/m"}, ""},
+		{"example.com/M/sub", []pkgMetaTag{
+			goImport("example.com/M mod https://proxy.example.com/"),
+		}, &pkgMeta{Prefix: "example.com/M", Vcs: "proxy", RepoRoot: "https://proxy.example.com/example.com/!m"}, ""},
+		{"example.com/d", []pkgMetaTag{
+			goImport("example.com/d git https://git.example.com/d"),
+			goImport("example.com/d hg https://hg.example.com/d"),
+		}, nil, "multiple go-import meta tags for example.com/d"},
+
+		{"example.com/ab", []pkgMetaTag{
+			goImport("example.com/a git https://git.example.com/a"),
+			goImport("example.com/ab/c git https://git.example.com/abc"),
+			goImport("example.com/ab git"),
+		}, nil, errNoMeta.Error()},
+		{"example.com/a", nil, nil, errNoMeta.Error()},
+	}
+	for _, test := range tests {
+		meta, err := chooseMeta(test.p, test.tags)
+		if test.err != "" {
+			if err == nil || err.Error() != test.err {
+				t.Errorf("chooseMeta %s error %v, want %s", test.p, err, test.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("chooseMeta %s %v", test.p, err)
+			continue
+		}
+		if !reflect.DeepEqual(meta, test.want) {
+			t.Errorf("chooseMeta %s = %+v, want %+v", test.p, meta, test.want)
+		}
+	}
+}
+
+func testMetaPage(prefix string, vcs string, repoRoot string) string {
+	return fmt.Sprintf(`<html><head><meta name="go-import" content="%s %s %s"></head></html>`, prefix, vcs, repoRoot)
+}
+
+func TestFetchPkgMetaRetry(t *testing.T) {
+	testAuthEnv(t, "")
+	delay := metaRetryDelay
+	metaRetryDelay = 0
+	defer func() { metaRetryDelay = delay }()
+
+	requests := 0
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.URL.Query().Get("go-get") != "1" {
+			t.Errorf("request %s without go-get=1", r.URL)
+		}
+		switch r.URL.Path {
+		case "/flaky":
+			if requests < metaRetries {
+				http.Error(w, "busy", http.StatusServiceUnavailable)
+				return
+			}
+			fmt.Fprint(w, testMetaPage("example.com/flaky", "git", "https://git.example.com/flaky"))
+		default:
+			http.Error(w, "broken", http.StatusInternalServerError)
+		}
+	}))
+	defer server.Close()
+	testHttpHosts(t, map[string]*httptest.Server{"example.com": server})
+	cmd := &ggcmd{config: &ggConfig{}}
+
+	meta, err := cmd.fetchPkgMeta("example.com/flaky")
+	if err != nil || meta.RepoRoot != "https://git.example.com/flaky" {
+		t.Errorf("fetchPkgMeta flaky = %v, %v", meta, err)
+	}
+	if requests != metaRetries {
+		t.Errorf("fetchPkgMeta flaky took %d requests, want %d", requests, metaRetries)
+	}
+
+	requests = 0
+	_, err = cmd.fetchPkgMeta("example.com/broken")
+	if err == nil || err == errNoMeta || !strings.Contains(err.Error(), "500") {
+		t.Errorf("fetchPkgMeta broken error %v, want the 500", err)
+	}
+	if requests != metaRetries {
+		t.Errorf("fetchPkgMeta broken took %d requests, want %d", requests, metaRetries)
+	}
+}
+
+func TestFetchPkgMetaDowngrade(t *testing.T) {
+	testAuthEnv(t, "")
+	t.Setenv("GOINSECURE", "")
+	plain := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, testMetaPage("example.com/a", "git", "http://git.example.com/a"))
+	}))
+	defer plain.Close()
+	secure := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "http://example.com"+r.URL.RequestURI(), http.StatusFound)
+	}))
+	defer secure.Close()
+	testHttpHosts(t, map[string]*httptest.Server{"example.com": secure, "example.com:80": plain})
+	cmd := &ggcmd{config: &ggConfig{}}
+
+	meta, err := cmd.fetchPkgMeta("example.com/a")
+	if err == nil || !strings.Contains(err.Error(), "refusing redirect from https") {
+		t.Errorf("fetchPkgMeta through a redirect to http = %v, %v, want it refused", meta, err)
+	}
+
+	// unless the host is allowed to be insecure
+	t.Setenv("GOINSECURE", "example.com")
+	meta, err = cmd.fetchPkgMeta("example.com/a")
+	if err != nil || meta.RepoRoot != "http://git.example.com/a" {
+		t.Errorf("fetchPkgMeta with GOINSECURE = %v, %v", meta, err)
+	}
+}
+
+func TestLookupPkgMetaParent(t *testing.T) {
+	testAuthEnv(t, "")
+	delay := metaRetryDelay
+	metaRetryDelay = 0
+	defer func() { metaRetryDelay = delay }()
+
+	var paths []string
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		switch {
+		case r.URL.Path == "/org/repo":
+			fmt.Fprint(w, testMetaPage("example.com/org/repo", "git", "https://git.example.com/org/repo"))
+		case strings.HasPrefix(r.URL.Path, "/org/repo/"):
+			http.NotFound(w, r)
+		case strings.HasPrefix(r.URL.Path, "/down/"):
+			http.Error(w, "down", http.StatusBadGateway)
+		case strings.HasPrefix(r.URL.Path, "/private/"):
+			http.Error(w, "who are you", http.StatusUnauthorized)
+		case r.URL.Path == "/other/sub":
+			fmt.Fprint(w, testMetaPage("example.com/other", "git", "https://git.example.com/other"))
+		default:
+			fmt.Fprint(w, testMetaPage("example.com/elsewhere", "git", "https://git.example.com/elsewhere"))
+		}
+	}))
+	defer server.Close()
+	testHttpHosts(t, map[string]*httptest.Server{"example.com": server})
+	cmd := &ggcmd{config: &ggConfig{}}
+
+	tests := []struct {
+		p     string
+		paths []string
+		root  string
+		err   string
+	}{
+		// a plain not found page has no meta, up to the parents
+		{"example.com/org/repo/a/b", []string{"/org/repo/a/b", "/org/repo/a", "/org/repo"}, "https://git.example.com/org/repo", ""},
+		// server or auth trouble is not the same as no meta
+		{"example.com/down/a", []string{"/down/a", "/down/a", "/down/a"}, "", "502"},
+		{"example.com/private/a", []string{"/private/a"}, "", "401"},
+		// a parent named by the page of p is fine, a go-import for another path is no meta
+		{"example.com/other/sub", []string{"/other/sub"}, "https://git.example.com/other", ""},
+		{"example.com/x/y", []string{"/x/y", "/x", "/"}, "", errNoMeta.Error()},
+	}
+	for _, test := range tests {
+		paths = nil
+		meta, err := cmd.lookupPkgMeta(test.p)
+		if test.err != "" {
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("lookupPkgMeta %s error %v, want %s", test.p, err, test.err)
+			}
+		} else if err != nil || meta.RepoRoot != test.root {
+			t.Errorf("lookupPkgMeta %s = %v, %v, want %s", test.p, meta, err, test.root)
+		}
+		if !reflect.DeepEqual(paths, test.paths) {
+			t.Errorf("lookupPkgMeta %s asked for %v, want %v", test.p, paths, test.paths)
+		}
+	}
+}
@@ -0,0 +1,139 @@
+package main
+
+//
+// go-get meta results kept under GGHOME, so a large vadd does not ask the
+// same hosts again for every package of a repo
+//
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net"
+	"os"
+	"path"
+	"path/filepath"
+	"time"
+)
+
+const (
+	metaCacheTTL         = 7 * 24 * time.Hour
+	metaCacheNegativeTTL = time.Hour
+)
+
+// Meta for a found repo root, Error for a failed lookup
+type metaCacheEntry struct {
+	Meta    *pkgMeta `json:",omitempty"`
+	Error   string   `json:",omitempty"`
+	Fetched time.Time
+}
+
+func (entry *metaCacheEntry) expired(now time.Time) bool {
+	ttl := metaCacheTTL
+	if entry.Meta == nil {
+		ttl = metaCacheNegativeTTL
+	}
+	return now.Sub(entry.Fetched) > ttl
+}
+
+func (entry *metaCacheEntry) err() error {
+	if entry.Error == errNoMeta.Error() {
+		return errNoMeta
+	}
+	return errors.New(entry.Error)
+}
+
+func metaCacheFilename() string {
+	return filepath.Join(ggHomeDir(), "cache", "meta.json")
+}
+
+// import path -> entry, loaded once, a broken file is an empty cache
+func (cmd *ggcmd) metaCache() map[string]*metaCacheEntry {
+	if cmd.metaCacheEntries == nil {
+		cmd.metaCacheEntries = map[string]*metaCacheEntry{}
+		content, err := ioutil.ReadFile(metaCacheFilename())
+		if err == nil {
+			err = json.Unmarshal(content, &cmd.metaCacheEntries)
+			if err != nil {
+				gglog.Printf("metaCache ignoring %s %v\n", metaCacheFilename(), err)
+				cmd.metaCacheEntries = map[string]*metaCacheEntry{}
+			}
+		}
+	}
+	return cmd.metaCacheEntries
+}
+
+// the cache is only a shortcut, failing to save it is not fatal
+func (cmd *ggcmd) saveMetaCache() {
+	filename := metaCacheFilename()
+	content, err := json.MarshalIndent(cmd.metaCache(), "", "    ")
+	if err == nil {
+		err = os.MkdirAll(filepath.Dir(filename), os.ModePerm)
+	}
+	if err == nil {
+		// replace in one go, another gg may be reading it
+		err = ioutil.WriteFile(filename+".tmp", content, 0644)
+	}
+	if err == nil {
+		err = os.Rename(filename+".tmp", filename)
+	}
+	if err != nil {
+		gglog.Printf("saveMetaCache %s %v\n", filename, err)
+	}
+}
+
+// meta, found, error
+// p itself, or the repo root of a parent, anyAge also takes expired entries
+func (cmd *ggcmd) cachedPkgMeta(p string, anyAge bool) (*pkgMeta, bool, error) {
+	entries := cmd.metaCache()
+	now := time.Now()
+
+	if entry := entries[p]; entry != nil && (anyAge || !entry.expired(now)) {
+		if entry.Meta != nil {
+			return entry.Meta, true, nil
+		}
+		// a failure is only known for p itself, and not worth reporting offline
+		if !isOffline() {
+			return nil, true, entry.err()
+		}
+	}
+
+	for try := path.Dir(p); try != "." && try != "/"; try = path.Dir(try) {
+		entry := entries[try]
+		if entry == nil || entry.Meta == nil || (!anyAge && entry.expired(now)) {
+			continue
+		}
+		if metaPrefixMatches(entry.Meta.Prefix, p) {
+			return entry.Meta, true, nil
+		}
+	}
+	return nil, false, nil
+}
+
+// found roots, and failures that asking again soon will not change
+// missing credentials and timeouts are worth another try
+func metaCacheable(err error) bool {
+	if err == nil {
+		return true
+	}
+	if _, ok := err.(*authError); ok {
+		return false
+	}
+	var netErr net.Error
+	return !(errors.As(err, &netErr) && netErr.Timeout())
+}
+
+func (cmd *ggcmd) cacheMeta(p string, meta *pkgMeta, err error) {
+	if !metaCacheable(err) {
+		return
+	}
+	entries := cmd.metaCache()
+	now := time.Now()
+	if err != nil {
+		entries[p] = &metaCacheEntry{Error: err.Error(), Fetched: now}
+	} else {
+		entries[p] = &metaCacheEntry{Meta: meta, Fetched: now}
+		entries[meta.Prefix] = &metaCacheEntry{Meta: meta, Fetched: now}
+	}
+	cmd.saveMetaCache()
+}
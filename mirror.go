@@ -0,0 +1,190 @@
+package main
+
+//
+// persistent repository mirrors, kept under GGHOME, for commands that need
+// history rather than a single checkout
+//
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// user level gg directory, $GGHOME or $HOME/.gghome
+func ggHomeDir() string {
+	if home := os.Getenv("GGHOME"); home != "" {
+		return home
+	}
+	return filepath.Join(os.Getenv("HOME"), ".gghome")
+}
+
+var reMirrorUnsafe = regexp.MustCompile("[^A-Za-z0-9._-]+")
+
+// https://github.com/a/b -> GGHOME/mirrors/git/github.com_a_b
+func mirrorDirFor(vcs string, vcsSource string) string {
+	name := vcsSource
+	if i := strings.Index(name, "://"); i >= 0 {
+		name = name[i+3:]
+	}
+	name = strings.Trim(reMirrorUnsafe.ReplaceAllString(name, "_"), "_")
+	return filepath.Join(ggHomeDir(), "mirrors", vcs, name)
+}
+
+// mirror dir, error
+// clone the repo if we have never seen it, otherwise pull when refresh. The
+// mirror is named after the canonical source and fetched like a download,
+// from the rewritten source and then the fallbacks.
+func (cmd *ggcmd) mirrorRepo(info *ggvPackage, refresh bool) (string, error) {
+	mirrorDir := mirrorDirFor(info.Vcs, info.VcsSource)
+	_, err := os.Stat(mirrorDir)
+	exists := err == nil
+
+	if isOffline() {
+		if !exists {
+			return "", &offlineError{info.VcsSource, ""}
+		}
+		return mirrorDir, nil
+	}
+	if info.Vcs != "git" && info.Vcs != "hg" {
+		return "", errors.New("Unknown vcs specified " + info.Vcs)
+	}
+	if exists && !refresh {
+		return mirrorDir, nil
+	}
+
+	if !exists {
+		err = os.MkdirAll(filepath.Dir(mirrorDir), os.ModePerm)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	sources := cmd.vcsSourcesFor(info)
+	for i, fetchSource := range sources {
+		err = cmd.mirrorFetch(info.Vcs, fetchSource, mirrorDir, exists)
+		if err == nil {
+			return mirrorDir, nil
+		}
+		if i < len(sources)-1 {
+			fmt.Fprintf(ggMessagesOut, "%s. Trying %s.\n", err, sources[i+1])
+		}
+	}
+	return "", err
+}
+
+// clone fetchSource into mirrorDir, or pull from it into the existing mirror
+func (cmd *ggcmd) mirrorFetch(vcs string, fetchSource string, mirrorDir string, exists bool) error {
+	var subcmd *exec.Cmd
+	if vcs == "git" {
+		if !exists {
+			subcmd = exec.Command("git", "clone", "--mirror", fetchSource, mirrorDir)
+		} else {
+			subcmd = exec.Command("git", "fetch", "--prune", fetchSource, "+refs/*:refs/*")
+			subcmd.Dir = mirrorDir
+		}
+	} else {
+		if !exists {
+			subcmd = exec.Command("hg", "clone", "-U", fetchSource, mirrorDir)
+		} else {
+			subcmd = exec.Command("hg", "pull", fetchSource)
+			subcmd.Dir = mirrorDir
+		}
+	}
+
+	gglog.Printf("mirrorFetch %v in %s\n", subcmd.Args, subcmd.Dir)
+	subcmd.Env = cmd.vcsEnv(vcs, fetchSource)
+	out, err := subcmd.CombinedOutput()
+	if err != nil {
+		if !exists {
+			os.RemoveAll(mirrorDir)
+		}
+		if authErr := vcsAuthError(fetchSource, out); authErr != nil {
+			return authErr
+		}
+		return errors.New("Unable to mirror " + fetchSource + " " + err.Error() + " " + strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// latest revision of the default branch in a mirror
+func mirrorHeadRevision(vcs string, mirrorDir string) (string, error) {
+	var subcmd *exec.Cmd
+	if vcs == "git" {
+		subcmd = exec.Command("git", "rev-parse", "HEAD")
+	} else if vcs == "hg" {
+		subcmd = exec.Command("hg", "log", "-r", "default", "--template", "{node|short}")
+	} else {
+		return "", errors.New("Unknown vcs specified " + vcs)
+	}
+	subcmd.Dir = mirrorDir
+	out, err := subcmd.Output()
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// is ancestor reachable from descendant
+func mirrorIsAncestor(vcs string, mirrorDir string, ancestor string, descendant string) (bool, error) {
+	if vcs == "git" {
+		subcmd := exec.Command("git", "merge-base", "--is-ancestor", ancestor, descendant)
+		subcmd.Dir = mirrorDir
+		err := subcmd.Run()
+		if err == nil {
+			return true, nil
+		}
+		if exitErr, ok := err.(*exec.ExitError); ok && exitErr.ExitCode() == 1 {
+			return false, nil
+		}
+		return false, err
+	} else if vcs == "hg" {
+		subcmd := exec.Command("hg", "log", "-r", ancestor+" and ancestors("+descendant+")", "--template", "{node|short}")
+		subcmd.Dir = mirrorDir
+		out, err := subcmd.Output()
+		if err != nil {
+			return false, err
+		}
+		return strings.TrimSpace(string(out)) != "", nil
+	}
+	return false, errors.New("Unknown vcs specified " + vcs)
+}
+
+type mirrorLogEntry struct {
+	Revision string
+	Author   string
+	Date     string
+	Subject  string
+}
+
+// commits reachable from to but not from from, newest first
+func mirrorLog(vcs string, mirrorDir string, from string, to string) ([]mirrorLogEntry, error) {
+	var subcmd *exec.Cmd
+	if vcs == "git" {
+		subcmd = exec.Command("git", "log", "--pretty=format:%h%x09%an%x09%ad%x09%s", "--date=short", from+".."+to)
+	} else if vcs == "hg" {
+		subcmd = exec.Command("hg", "log", "-r", "reverse(ancestors("+to+") - ancestors("+from+"))",
+			"--template", "{node|short}\t{author|person}\t{date|shortdate}\t{desc|firstline}\n")
+	} else {
+		return nil, errors.New("Unknown vcs specified " + vcs)
+	}
+	subcmd.Dir = mirrorDir
+	out, err := subcmd.Output()
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []mirrorLogEntry
+	for _, line := range strings.Split(string(out), "\n") {
+		parts := strings.SplitN(line, "\t", 4)
+		if len(parts) != 4 {
+			continue
+		}
+		entries = append(entries, mirrorLogEntry{parts[0], parts[1], parts[2], parts[3]})
+	}
+	return entries, nil
+}
@@ -0,0 +1,185 @@
+package main
+
+//
+// offline mode, --offline or GG_OFFLINE=1, nothing goes to the network
+//
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// set by the global --offline option
+var ggOfflineFlag bool
+
+func isOffline() bool {
+	if ggOfflineFlag {
+		return true
+	}
+	value := strings.ToLower(os.Getenv("GG_OFFLINE"))
+	return value != "" && value != "0" && value != "false"
+}
+
+// what we would have fetched
+type offlineError struct {
+	Package  string
+	Revision string // "" for latest
+}
+
+func (e *offlineError) Error() string {
+	revision := e.Revision
+	if revision == "" {
+		revision = "latest"
+	}
+	return "Offline, " + e.Package + " at " + revision + " is not available from the local mirrors, caches or vendor tree"
+}
+
+// the packages offline could not find, one error for all of them
+func offlineMissingError(missing map[string]bool) error {
+	var names []string
+	for p := range missing {
+		names = append(names, p)
+	}
+	sort.Strings(names)
+	return fmt.Errorf("Offline, not available from the local mirrors, caches or vendor tree: %s. Fill the mirrors with gg vlog --refresh, or run without --offline.",
+		strings.Join(names, ", "))
+}
+
+// the vendor root a command works on, offline its packages stand in for
+// their repos
+type offlineVendor struct {
+	Dir      string
+	Prefix   string // imports of rewritten packages start with it
+	Packages map[string]*ggvPackage
+}
+
+func (cmd *ggcmd) loadOfflineVendor(vendorDir string, ggv *ggvJson) {
+	cmd.offlineVendor = &offlineVendor{vendorDir, ggv.VendorPrefix, ggv.Packages}
+}
+
+// tempdir with a copy of the vendored p and its record, "" when there is
+// none or it is not what fetching info and vendoring it would give: another
+// revision, other settings, or changed since gg wrote it. For the dependency
+// analysis anyRevision takes it whatever revision info asks for.
+func (cmd *ggcmd) offlineVendoredTree(p string, info *ggvPackage, anyRevision bool) (string, *ggvPackage) {
+	if !isOffline() || cmd.offlineVendor == nil {
+		return "", nil
+	}
+	vendored := cmd.offlineVendor.Packages[p]
+	if vendored == nil || vendored.TreeHash == "" {
+		return "", nil
+	}
+
+	revision := info.Revision
+	if info.Vcs == "proxy" && revision == "" {
+		revision = info.Version
+	}
+	sameRevision := revision != "" && (revision == vendored.Revision || (info.Vcs == "proxy" && revision == vendored.Version))
+	if !sameRevision && !anyRevision {
+		return "", nil
+	}
+	if info.Vcs != vendored.Vcs || info.VcsSource != vendored.VcsSource || info.Subdir != vendored.Subdir ||
+		info.RewriteImports != vendored.RewriteImports || !reflect.DeepEqual(info.Prune, vendored.Prune) ||
+		!reflect.DeepEqual(info.Patches, vendored.Patches) || !reflect.DeepEqual(info.Include, vendored.Include) ||
+		!reflect.DeepEqual(info.Exclude, vendored.Exclude) {
+		return "", nil
+	}
+
+	dir := filepath.Join(cmd.offlineVendor.Dir, filepath.FromSlash(p))
+	hash, err := vendorTreeHash(dir)
+	if err != nil || hash != vendored.TreeHash {
+		gglog.Printf("offlineVendoredTree %s changed since vendored %v\n", p, err)
+		return "", nil
+	}
+
+	tempDir, err := ioutil.TempDir("", "gg")
+	if err != nil {
+		return "", nil
+	}
+	err = copyDir(dir, tempDir)
+	if err != nil {
+		os.RemoveAll(tempDir)
+		return "", nil
+	}
+	gglog.Printf("offlineVendoredTree %s from %s\n", p, dir)
+	return tempDir, vendored
+}
+
+// local repos that may hold the package, mirrors first, then GOPATH/src
+func offlineRepoCandidates(p string, info *ggvPackage) []string {
+	var candidates []string
+	for _, source := range append([]string{info.VcsSource}, info.Fallbacks...) {
+		candidates = append(candidates, mirrorDirFor(info.Vcs, source))
+	}
+	if gopath, err := getCurrentGopath(); err == nil {
+		candidates = append(candidates, filepath.Join(gopath, "src", filepath.FromSlash(p)))
+	}
+
+	var repos []string
+	for _, dir := range candidates {
+		if offlineIsRepo(info.Vcs, dir) {
+			repos = append(repos, dir)
+		}
+	}
+	return repos
+}
+
+func offlineIsRepo(vcs string, dir string) bool {
+	markers := map[string][]string{
+		"git": {".git", "HEAD"}, // checkout, or bare mirror
+		"hg":  {".hg"},
+	}
+	for _, marker := range markers[vcs] {
+		if _, err := os.Stat(filepath.Join(dir, marker)); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// tempdir, revision, error
+func (cmd *ggcmd) fetchPackageOffline(p string, info *ggvPackage) (string, string, error) {
+	for _, repo := range offlineRepoCandidates(p, info) {
+		tempDir, revision, err := cmd.fetchPackage(info.Vcs, repo, info.Revision, info.SaveRepo)
+		gglog.Printf("fetchPackageOffline %s %s %s %v\n", p, repo, revision, err)
+		if err == nil {
+			return tempDir, revision, nil
+		}
+	}
+	return "", "", &offlineError{p, info.Revision}
+}
+
+// only file:// proxies and the module cache, repos go through fetchPackageOffline
+func offlineSources(p string, info *ggvPackage, sources []string) []string {
+	if info.Vcs != "proxy" {
+		return nil
+	}
+	var local []string
+	for _, source := range sources {
+		if strings.HasPrefix(source, "file://") {
+			local = append(local, source)
+		}
+	}
+	if cache := offlineProxySource(p); cache != "" {
+		local = append(local, cache)
+	}
+	return local
+}
+
+// module cache of the go tool, same layout as a proxy
+func offlineProxySource(modulePath string) string {
+	cache := os.Getenv("GOMODCACHE")
+	if cache == "" {
+		gopath, err := getCurrentGopath()
+		if err != nil {
+			return ""
+		}
+		cache = filepath.Join(strings.Split(gopath, string(os.PathListSeparator))[0], "pkg", "mod")
+	}
+	return "file://" + filepath.ToSlash(filepath.Join(cache, "cache", "download", proxyEscapePath(modulePath)))
+}
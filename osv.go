@@ -0,0 +1,567 @@
+package main
+
+//
+// OSV format advisories (https://ossf.github.io/osv-schema/) read from a
+// local directory or archive, matched against vendored revisions
+//
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type osvEvent struct {
+	Introduced   string `json:"introduced,omitempty"`
+	Fixed        string `json:"fixed,omitempty"`
+	LastAffected string `json:"last_affected,omitempty"`
+	Limit        string `json:"limit,omitempty"`
+}
+
+type osvRange struct {
+	Type   string     `json:"type"` // GIT, SEMVER, ECOSYSTEM
+	Repo   string     `json:"repo,omitempty"`
+	Events []osvEvent `json:"events"`
+}
+
+type osvAffected struct {
+	Package struct {
+		Ecosystem string `json:"ecosystem"`
+		Name      string `json:"name"`
+	} `json:"package"`
+	Ranges            []osvRange             `json:"ranges"`
+	Versions          []string               `json:"versions"`
+	EcosystemSpecific map[string]interface{} `json:"ecosystem_specific"`
+	DatabaseSpecific  map[string]interface{} `json:"database_specific"`
+}
+
+type osvSeverity struct {
+	Type  string `json:"type"`
+	Score string `json:"score"`
+}
+
+type osvAdvisory struct {
+	Id               string                 `json:"id"`
+	Summary          string                 `json:"summary"`
+	Aliases          []string               `json:"aliases"`
+	Withdrawn        string                 `json:"withdrawn"`
+	Severity         []osvSeverity          `json:"severity"`
+	Affected         []osvAffected          `json:"affected"`
+	DatabaseSpecific map[string]interface{} `json:"database_specific"`
+}
+
+// advisory matched against a vendored package
+type auditFinding struct {
+	Package  string
+	Revision string
+	Id       string
+	Aliases  []string
+	Summary  string
+	Severity string // LOW, MEDIUM, HIGH, CRITICAL, UNKNOWN
+	Fixed    []string
+}
+
+var auditSeverityRank = map[string]int{
+	"LOW":      1,
+	"MEDIUM":   2,
+	"UNKNOWN":  2, // not knowing is not the same as low
+	"HIGH":     3,
+	"CRITICAL": 4,
+}
+
+// load every advisory from a directory, .zip or .tar.gz
+func loadOsvDatabase(path string) ([]*osvAdvisory, error) {
+	var advisories []*osvAdvisory
+	add := func(name string, content []byte) error {
+		if !strings.HasSuffix(name, ".json") {
+			return nil
+		}
+		parsed, err := parseOsv(content)
+		if err != nil {
+			return fmt.Errorf("%s: %s", name, err)
+		}
+		advisories = append(advisories, parsed...)
+		return nil
+	}
+
+	stat, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if stat.IsDir() {
+		err = filepath.Walk(path, func(fpath string, f os.FileInfo, err error) error {
+			if err != nil || f.IsDir() {
+				return err
+			}
+			content, err := ioutil.ReadFile(fpath)
+			if err != nil {
+				return err
+			}
+			return add(fpath, content)
+		})
+		return advisories, err
+	}
+
+	if strings.HasSuffix(path, ".zip") {
+		reader, err := zip.OpenReader(path)
+		if err != nil {
+			return nil, err
+		}
+		defer reader.Close()
+		for _, f := range reader.File {
+			rc, err := f.Open()
+			if err != nil {
+				return nil, err
+			}
+			content, err := ioutil.ReadAll(rc)
+			rc.Close()
+			if err != nil {
+				return nil, err
+			}
+			err = add(f.Name, content)
+			if err != nil {
+				return nil, err
+			}
+		}
+		return advisories, nil
+	}
+
+	if strings.HasSuffix(path, ".tar.gz") || strings.HasSuffix(path, ".tgz") {
+		file, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+		gz, err := gzip.NewReader(file)
+		if err != nil {
+			return nil, err
+		}
+		tr := tar.NewReader(gz)
+		for {
+			hdr, err := tr.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return nil, err
+			}
+			if hdr.Typeflag != tar.TypeReg {
+				continue
+			}
+			content, err := ioutil.ReadAll(tr)
+			if err != nil {
+				return nil, err
+			}
+			err = add(hdr.Name, content)
+			if err != nil {
+				return nil, err
+			}
+		}
+		return advisories, nil
+	}
+
+	return nil, fmt.Errorf("Unknown advisory database format %s, use a directory, .zip or .tar.gz", path)
+}
+
+// one advisory per file, some exports use arrays
+func parseOsv(content []byte) ([]*osvAdvisory, error) {
+	content = bytes.TrimSpace(content)
+	if len(content) > 0 && content[0] == '[' {
+		var advisories []*osvAdvisory
+		err := json.Unmarshal(content, &advisories)
+		return advisories, err
+	}
+	var advisory osvAdvisory
+	err := json.Unmarshal(content, &advisory)
+	if err != nil {
+		return nil, err
+	}
+	return []*osvAdvisory{&advisory}, nil
+}
+
+func osvNameMatches(pkgName string, name string) bool {
+	return name == pkgName || strings.HasPrefix(name, pkgName+"/") || strings.HasPrefix(pkgName, name+"/")
+}
+
+// match advisories against vendored packages
+func (cmd *ggcmd) auditPackages(ggv *ggvJson, pkgNames []string, advisories []*osvAdvisory, refresh bool) []auditFinding {
+	var findings []auditFinding
+	for _, p := range pkgNames {
+		info := ggv.Packages[p]
+		for _, advisory := range advisories {
+			if advisory.Withdrawn != "" {
+				continue
+			}
+			for _, affected := range advisory.Affected {
+				if !osvNameMatches(p, affected.Package.Name) {
+					continue
+				}
+				isAffected, fixed := cmd.auditAffected(p, info, &affected, refresh)
+				if !isAffected {
+					continue
+				}
+				findings = append(findings, auditFinding{
+					Package:  p,
+					Revision: info.Revision,
+					Id:       advisory.Id,
+					Aliases:  advisory.Aliases,
+					Summary:  advisory.Summary,
+					Severity: osvSeverityLevel(advisory, &affected),
+					Fixed:    fixed,
+				})
+				break
+			}
+		}
+	}
+	return findings
+}
+
+// affected, fixed revisions or versions
+func (cmd *ggcmd) auditAffected(p string, info *ggvPackage, affected *osvAffected, refresh bool) (bool, []string) {
+	var fixed []string
+	for _, r := range affected.Ranges {
+		for _, event := range r.Events {
+			if event.Fixed != "" {
+				fixed = append(fixed, event.Fixed)
+			}
+		}
+	}
+
+	if info.Revision == "" || info.Vcs == "manual" {
+		gglog.Printf("%s has no revision to audit\n", p)
+		return false, fixed
+	}
+
+	var mirrorDir string
+	mirror := func() string {
+		if mirrorDir == "" {
+			var err error
+			mirrorDir, err = cmd.mirrorRepo(info, refresh)
+			if err != nil {
+				ggFatal("%s", err)
+			}
+		}
+		return mirrorDir
+	}
+
+	version := info.Version // proxy packages know theirs
+	for _, v := range affected.Versions {
+		if v == info.Revision {
+			return true, fixed
+		}
+	}
+
+	for _, r := range affected.Ranges {
+		switch r.Type {
+		case "GIT":
+			if info.Vcs == "proxy" {
+				continue
+			}
+			if cmd.auditInGitRange(info, mirror(), r.Events) {
+				return true, fixed
+			}
+		case "SEMVER", "ECOSYSTEM":
+			if version == "" {
+				version = mirrorVersionAt(info.Vcs, mirror(), info.Revision)
+				gglog.Printf("%s %s is version %s\n", p, info.Revision, version)
+			}
+			if version == "" {
+				continue
+			}
+			for _, v := range affected.Versions {
+				if v == version {
+					return true, fixed
+				}
+			}
+			if auditInVersionRange(version, r.Events) {
+				return true, fixed
+			}
+		}
+	}
+	return false, fixed
+}
+
+// commit ranges resolved through the mirror
+func (cmd *ggcmd) auditInGitRange(info *ggvPackage, mirrorDir string, events []osvEvent) bool {
+	isAncestor := func(ancestor string, descendant string) bool {
+		ok, err := mirrorIsAncestor(info.Vcs, mirrorDir, ancestor, descendant)
+		if err != nil {
+			gglog.Printf("Unable to compare %s to %s %s\n", ancestor, descendant, err)
+			return false
+		}
+		return ok
+	}
+	return gitRangeAffects(info.Revision, events, isAncestor)
+}
+
+// revision is affected through an introduced commit it descends from, unless
+// a fixed commit between the two, or a last_affected one before revision,
+// ends that interval. With limits, only commits before one of them count.
+func gitRangeAffects(revision string, events []osvEvent, isAncestor func(ancestor string, descendant string) bool) bool {
+	var limits []string
+	for _, event := range events {
+		if event.Limit != "" {
+			limits = append(limits, event.Limit)
+		}
+	}
+	if len(limits) > 0 {
+		beforeLimit := false
+		for _, limit := range limits {
+			if limit != revision && isAncestor(revision, limit) {
+				beforeLimit = true
+				break
+			}
+		}
+		if !beforeLimit {
+			return false
+		}
+	}
+
+	for _, start := range events {
+		if start.Introduced == "" {
+			continue
+		}
+		fromRoot := start.Introduced == "0"
+		if !fromRoot && !isAncestor(start.Introduced, revision) {
+			continue
+		}
+		// only ends after this introduced belong to its interval
+		after := func(rev string) bool {
+			return fromRoot || isAncestor(start.Introduced, rev)
+		}
+		ended := false
+		for _, event := range events {
+			if event.Fixed != "" && isAncestor(event.Fixed, revision) && after(event.Fixed) {
+				ended = true
+			}
+			if event.LastAffected != "" && event.LastAffected != revision && isAncestor(event.LastAffected, revision) && after(event.LastAffected) {
+				ended = true
+			}
+			if ended {
+				break
+			}
+		}
+		if !ended {
+			return true
+		}
+	}
+	return false
+}
+
+// events in version order, an introduced starts an affected interval and
+// the fixed or last_affected after it ends it, as the OSV spec reads them
+func auditInVersionRange(version string, events []osvEvent) bool {
+	eventVersion := func(event osvEvent) string {
+		for _, v := range []string{event.Introduced, event.Fixed, event.LastAffected, event.Limit} {
+			if v != "" {
+				return v
+			}
+		}
+		return ""
+	}
+	sorted := append([]osvEvent{}, events...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		a, b := eventVersion(sorted[i]), eventVersion(sorted[j])
+		if a == "0" || b == "0" {
+			return a == "0" && b != "0"
+		}
+		return compareVersions(a, b) < 0
+	})
+
+	affected := false
+	for _, event := range sorted {
+		switch {
+		case event.Introduced != "":
+			if event.Introduced == "0" || compareVersions(version, event.Introduced) >= 0 {
+				affected = true
+			}
+		case event.Fixed != "":
+			if compareVersions(version, event.Fixed) >= 0 {
+				affected = false
+			}
+		case event.LastAffected != "":
+			if compareVersions(version, event.LastAffected) > 0 {
+				affected = false
+			}
+		case event.Limit != "":
+			if compareVersions(version, event.Limit) >= 0 {
+				affected = false
+			}
+		}
+	}
+	return affected
+}
+
+// closest tag at or before revision, "" if none
+func mirrorVersionAt(vcs string, mirrorDir string, revision string) string {
+	var subcmd *exec.Cmd
+	if vcs == "git" {
+		subcmd = exec.Command("git", "describe", "--tags", "--abbrev=0", revision)
+	} else if vcs == "hg" {
+		subcmd = exec.Command("hg", "log", "-r", revision, "--template", "{latesttag}")
+	} else {
+		return ""
+	}
+	subcmd.Dir = mirrorDir
+	out, err := subcmd.Output()
+	if err != nil {
+		return ""
+	}
+	version := strings.TrimSpace(string(out))
+	if version == "null" {
+		return ""
+	}
+	return version
+}
+
+// semver like comparison, leading v optional, pre-releases sort first
+func compareVersions(a string, b string) int {
+	split := func(v string) ([]string, string) {
+		v = strings.TrimPrefix(v, "v")
+		if i := strings.IndexByte(v, '+'); i >= 0 {
+			v = v[:i]
+		}
+		pre := ""
+		if i := strings.IndexByte(v, '-'); i >= 0 {
+			v, pre = v[:i], v[i+1:]
+		}
+		return strings.Split(v, "."), pre
+	}
+
+	aParts, aPre := split(a)
+	bParts, bPre := split(b)
+	for i := 0; i < len(aParts) || i < len(bParts); i++ {
+		var an, bn int
+		if i < len(aParts) {
+			an, _ = strconv.Atoi(aParts[i])
+		}
+		if i < len(bParts) {
+			bn, _ = strconv.Atoi(bParts[i])
+		}
+		if an != bn {
+			if an < bn {
+				return -1
+			}
+			return 1
+		}
+	}
+
+	if aPre == bPre {
+		return 0
+	}
+	if aPre == "" {
+		return 1
+	}
+	if bPre == "" {
+		return -1
+	}
+	if aPre < bPre {
+		return -1
+	}
+	return 1
+}
+
+func osvSeverityLevel(advisory *osvAdvisory, affected *osvAffected) string {
+	for _, specific := range []map[string]interface{}{affected.DatabaseSpecific, affected.EcosystemSpecific, advisory.DatabaseSpecific} {
+		if level, ok := specific["severity"].(string); ok && level != "" {
+			level = strings.ToUpper(level)
+			if level == "MODERATE" {
+				level = "MEDIUM"
+			}
+			if auditSeverityRank[level] > 0 {
+				return level
+			}
+		}
+	}
+
+	for _, severity := range advisory.Severity {
+		if strings.HasPrefix(severity.Score, "CVSS:3") {
+			score := cvss3BaseScore(severity.Score)
+			switch {
+			case score >= 9.0:
+				return "CRITICAL"
+			case score >= 7.0:
+				return "HIGH"
+			case score >= 4.0:
+				return "MEDIUM"
+			case score > 0:
+				return "LOW"
+			}
+		}
+	}
+	return "UNKNOWN"
+}
+
+// https://www.first.org/cvss/v3.1/specification-document base score
+func cvss3BaseScore(vector string) float64 {
+	metrics := map[string]string{}
+	for _, part := range strings.Split(vector, "/")[1:] {
+		kv := strings.SplitN(part, ":", 2)
+		if len(kv) == 2 {
+			metrics[kv[0]] = kv[1]
+		}
+	}
+
+	changed := metrics["S"] == "C"
+	weights := map[string]map[string]float64{
+		"AV": {"N": 0.85, "A": 0.62, "L": 0.55, "P": 0.2},
+		"AC": {"L": 0.77, "H": 0.44},
+		"UI": {"N": 0.85, "R": 0.62},
+		"C":  {"H": 0.56, "L": 0.22, "N": 0},
+		"I":  {"H": 0.56, "L": 0.22, "N": 0},
+		"A":  {"H": 0.56, "L": 0.22, "N": 0},
+	}
+	if changed {
+		weights["PR"] = map[string]float64{"N": 0.85, "L": 0.68, "H": 0.5}
+	} else {
+		weights["PR"] = map[string]float64{"N": 0.85, "L": 0.62, "H": 0.27}
+	}
+	for metric, values := range weights {
+		if _, ok := values[metrics[metric]]; !ok {
+			return 0
+		}
+	}
+	w := func(metric string) float64 {
+		return weights[metric][metrics[metric]]
+	}
+
+	iss := 1 - (1-w("C"))*(1-w("I"))*(1-w("A"))
+	var impact float64
+	if changed {
+		impact = 7.52*(iss-0.029) - 3.25*math.Pow(iss-0.02, 15)
+	} else {
+		impact = 6.42 * iss
+	}
+	if impact <= 0 {
+		return 0
+	}
+	exploitability := 8.22 * w("AV") * w("AC") * w("PR") * w("UI")
+
+	score := impact + exploitability
+	if changed {
+		score = 1.08 * score
+	}
+	return math.Ceil(math.Min(score, 10)*10) / 10
+}
+
+func sortAuditFindings(findings []auditFinding) {
+	sort.SliceStable(findings, func(i, j int) bool {
+		if findings[i].Package != findings[j].Package {
+			return findings[i].Package < findings[j].Package
+		}
+		return findings[i].Id < findings[j].Id
+	})
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestAuditInVersionRange(t *testing.T) {
+	twoIntervals := []osvEvent{
+		{Introduced: "1.0.0"}, {Fixed: "1.2.0"},
+		{Introduced: "2.0.0"}, {Fixed: "2.1.0"},
+	}
+	// same intervals, listed out of order
+	unordered := []osvEvent{
+		{Fixed: "2.1.0"}, {Introduced: "2.0.0"},
+		{Fixed: "1.2.0"}, {Introduced: "1.0.0"},
+	}
+	lastAffected := []osvEvent{
+		{Introduced: "0"}, {LastAffected: "1.4.2"},
+		{Introduced: "1.6.0"}, {LastAffected: "1.6.3"},
+	}
+
+	tests := []struct {
+		version  string
+		events   []osvEvent
+		affected bool
+	}{
+		{"0.9.0", twoIntervals, false},
+		{"1.0.0", twoIntervals, true},
+		{"v1.1.5", twoIntervals, true},
+		{"1.2.0", twoIntervals, false},
+		{"1.5.0", twoIntervals, false},
+		{"2.0.0", twoIntervals, true},
+		{"2.0.5", twoIntervals, true},
+		{"2.1.0", twoIntervals, false},
+		{"3.0.0", twoIntervals, false},
+		{"1.1.0", unordered, true},
+		{"1.9.0", unordered, false},
+		{"2.0.5", unordered, true},
+		{"0.1.0", lastAffected, true},
+		{"1.4.2", lastAffected, true},
+		{"1.4.3", lastAffected, false},
+		{"1.6.0", lastAffected, true},
+		{"1.6.3", lastAffected, true},
+		{"1.6.4", lastAffected, false},
+		{"1.0.0-rc.1", []osvEvent{{Introduced: "0"}, {Fixed: "1.0.0"}}, true},
+		{"5.0.0", []osvEvent{{Introduced: "1.0.0"}}, true},
+		{"5.0.0", []osvEvent{{Introduced: "1.0.0"}, {Limit: "4.0.0"}}, false},
+	}
+	for _, test := range tests {
+		if affected := auditInVersionRange(test.version, test.events); affected != test.affected {
+			t.Errorf("auditInVersionRange(%s, %v) = %v, want %v", test.version, test.events, affected, test.affected)
+		}
+	}
+}
+
+func TestGitRangeAffects(t *testing.T) {
+	// a1 - a2 - a3 - a4 - a5 - a6 - a7 on the main line, b1 - b2 branched
+	// off a2, with b2 a backport of the fix in a3
+	parents := map[string][]string{
+		"a2": {"a1"}, "a3": {"a2"}, "a4": {"a3"}, "a5": {"a4"}, "a6": {"a5"}, "a7": {"a6"},
+		"b1": {"a2"}, "b2": {"b1"},
+	}
+	var isAncestor func(ancestor string, descendant string) bool
+	isAncestor = func(ancestor string, descendant string) bool {
+		if ancestor == descendant {
+			return true
+		}
+		for _, parent := range parents[descendant] {
+			if isAncestor(ancestor, parent) {
+				return true
+			}
+		}
+		return false
+	}
+
+	twoIntervals := []osvEvent{
+		{Introduced: "a2"}, {Fixed: "a3"},
+		{Introduced: "a5"}, {Fixed: "a6"},
+	}
+	backported := []osvEvent{
+		{Introduced: "a2"}, {Fixed: "a3"}, {Fixed: "b2"},
+	}
+	lastAffected := []osvEvent{
+		{Introduced: "0"}, {LastAffected: "a2"},
+		{Introduced: "a4"}, {LastAffected: "a5"},
+	}
+
+	tests := []struct {
+		revision string
+		events   []osvEvent
+		affected bool
+	}{
+		{"a1", twoIntervals, false},
+		{"a2", twoIntervals, true},
+		{"a3", twoIntervals, false},
+		{"a4", twoIntervals, false},
+		{"a5", twoIntervals, true},
+		{"a6", twoIntervals, false},
+		{"a7", twoIntervals, false},
+		{"b1", twoIntervals, true},
+		{"b1", backported, true},
+		{"b2", backported, false},
+		{"a4", backported, false},
+		{"a1", lastAffected, true},
+		{"a2", lastAffected, true},
+		{"a3", lastAffected, false},
+		{"a5", lastAffected, true},
+		{"a6", lastAffected, false},
+		{"b1", lastAffected, false},
+		{"a4", []osvEvent{{Introduced: "0"}, {Limit: "a5"}}, true},
+		{"a5", []osvEvent{{Introduced: "0"}, {Limit: "a5"}}, false},
+		{"b1", []osvEvent{{Introduced: "0"}, {Limit: "a5"}}, false},
+	}
+	for _, test := range tests {
+		if affected := gitRangeAffects(test.revision, test.events, isAncestor); affected != test.affected {
+			t.Errorf("gitRangeAffects(%s, %v) = %v, want %v", test.revision, test.events, affected, test.affected)
+		}
+	}
+}
+
+func TestCompareVersions(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"1.0.0", "1.0.0", 0},
+		{"v1.0.0", "1.0.0", 0},
+		{"1.0", "1.0.0", 0},
+		{"1.2.0", "1.10.0", -1},
+		{"2.0.0", "1.99.99", 1},
+		{"1.0.0-rc.1", "1.0.0", -1},
+		{"1.0.0", "1.0.0-rc.1", 1},
+		{"1.0.0-alpha", "1.0.0-beta", -1},
+		{"1.0.0+build.5", "1.0.0", 0},
+		{"0.3", "0.2", 1},
+	}
+	for _, test := range tests {
+		if got := compareVersions(test.a, test.b); got != test.want {
+			t.Errorf("compareVersions(%s, %s) = %d, want %d", test.a, test.b, got, test.want)
+		}
+	}
+}
+
+func TestCvss3BaseScore(t *testing.T) {
+	tests := []struct {
+		vector string
+		score  float64
+	}{
+		{"CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:U/C:H/I:H/A:H", 9.8},
+		{"CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:C/C:H/I:H/A:H", 10.0},
+		{"CVSS:3.1/AV:N/AC:L/PR:N/UI:R/S:C/C:L/I:L/A:N", 6.1},
+		{"CVSS:3.1/AV:L/AC:L/PR:L/UI:N/S:U/C:H/I:H/A:H", 7.8},
+		{"CVSS:3.1/AV:N/AC:H/PR:N/UI:N/S:U/C:H/I:N/A:N", 5.9},
+		{"CVSS:3.0/AV:N/AC:L/PR:N/UI:N/S:U/C:N/I:N/A:H", 7.5},
+		{"CVSS:3.1/AV:P/AC:H/PR:H/UI:R/S:U/C:L/I:N/A:N", 1.6},
+		{"CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:U/C:N/I:N/A:N", 0},
+		{"CVSS:3.1/AV:N/AC:L", 0},
+		{"garbage", 0},
+	}
+	for _, test := range tests {
+		if score := cvss3BaseScore(test.vector); score != test.score {
+			t.Errorf("cvss3BaseScore(%s) = %.1f, want %.1f", test.vector, score, test.score)
+		}
+	}
+}
+
+func TestOsvSeverityLevel(t *testing.T) {
+	advisory := &osvAdvisory{}
+	affected := &osvAffected{}
+	if level := osvSeverityLevel(advisory, affected); level != "UNKNOWN" {
+		t.Errorf("osvSeverityLevel without severity = %s, want UNKNOWN", level)
+	}
+	advisory.Severity = []osvSeverity{{Type: "CVSS_V3", Score: "CVSS:3.1/AV:N/AC:L/PR:N/UI:R/S:C/C:L/I:L/A:N"}}
+	if level := osvSeverityLevel(advisory, affected); level != "MEDIUM" {
+		t.Errorf("osvSeverityLevel of cvss 6.1 = %s, want MEDIUM", level)
+	}
+	advisory.DatabaseSpecific = map[string]interface{}{"severity": "moderate"}
+	affected.DatabaseSpecific = map[string]interface{}{"severity": "critical"}
+	if level := osvSeverityLevel(advisory, affected); level != "CRITICAL" {
+		t.Errorf("osvSeverityLevel with affected database_specific = %s, want CRITICAL", level)
+	}
+}
@@ -0,0 +1,149 @@
+package main
+
+//
+// --json and --format, records for scripts on stdout, messages printed along
+// the way go to stderr instead
+//
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+// set by --json and --format of the commands printing records
+var ggOutputJson bool
+var ggOutputFormat string
+
+// where records go, and progress and messages of the commands printing them
+var ggRecordsOut io.Writer = os.Stdout
+var ggMessagesOut io.Writer = os.Stdout
+
+func structuredOutput() bool {
+	return ggOutputJson || ggOutputFormat != ""
+}
+
+// progress and messages printed along the way stay out of the records
+func setOutputMode(outputJson bool, format string) {
+	if outputJson && format != "" {
+		ggFatal("Please use only one of --json and --format.")
+	}
+	ggOutputJson = outputJson
+	ggOutputFormat = format
+	if structuredOutput() {
+		ggMessagesOut = os.Stderr
+	}
+}
+
+var outputTemplateFuncs = template.FuncMap{
+	"join": strings.Join,
+	"json": func(v interface{}) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+}
+
+// records is a slice, an indented json array for --json, one line per
+// record for --format
+func printRecords(records interface{}) {
+	if ggOutputJson {
+		b, err := json.MarshalIndent(records, "", "    ")
+		if err != nil {
+			ggFatal("%s", err)
+		}
+		fmt.Fprintf(ggRecordsOut, "%s\n", b)
+		return
+	}
+
+	tmpl, err := template.New("format").Funcs(outputTemplateFuncs).Parse(ggOutputFormat)
+	if err != nil {
+		ggFatal("Bad --format template. %s", err)
+	}
+	list := reflect.ValueOf(records)
+	for i := 0; i < list.Len(); i++ {
+		err = tmpl.Execute(ggRecordsOut, list.Index(i).Interface())
+		if err != nil {
+			ggFatal("Bad --format template. %s", err)
+		}
+		fmt.Fprintf(ggRecordsOut, "\n")
+	}
+}
+
+// rdep and ldep, Platforms only for dependencies some platforms need,
+// Status for ldep --status
+type depRecord struct {
+	Path      string
+	Platforms []string `json:",omitempty"`
+	Status    string   `json:",omitempty"`
+}
+
+func depRecords(deps []string, specific map[string][]string, statuses map[string]string) []depRecord {
+	records := []depRecord{}
+	for _, pkg := range deps {
+		records = append(records, depRecord{pkg, specific[pkg], statuses[pkg]})
+	}
+	return records
+}
+
+// what vadd, vupdate and vrebuild did to a package
+// Change is added, updated or rebuilt, OldRevision is empty when added
+type changeRecord struct {
+	Package     string
+	Change      string
+	Vcs         string
+	VcsSource   string
+	OldRevision string `json:",omitempty"`
+	NewRevision string
+}
+
+// by package, packages whose revision did not move are left out unless
+// unchanged names the change to report them as
+func packageChanges(oldPkgs map[string]*ggvPackage, newPkgs map[string]*ggvPackage, unchanged string) []changeRecord {
+	var names []string
+	for pkg := range newPkgs {
+		names = append(names, pkg)
+	}
+	sort.Strings(names)
+
+	changes := []changeRecord{}
+	for _, pkg := range names {
+		pkgInfo := newPkgs[pkg]
+		change := changeRecord{Package: pkg, Vcs: pkgInfo.Vcs, VcsSource: pkgInfo.VcsSource, NewRevision: pkgInfo.Revision}
+		oldInfo := oldPkgs[pkg]
+		switch {
+		case oldInfo == nil:
+			change.Change = "added"
+		case oldInfo.Revision != pkgInfo.Revision:
+			change.Change = "updated"
+			change.OldRevision = oldInfo.Revision
+		case unchanged != "":
+			change.Change = unchanged
+			change.OldRevision = oldInfo.Revision
+		default:
+			continue
+		}
+		changes = append(changes, change)
+	}
+	return changes
+}
+
+// records, or the Added and Updated lines
+func printChanges(changes []changeRecord) {
+	if structuredOutput() {
+		printRecords(changes)
+		return
+	}
+	for _, change := range changes {
+		switch change.Change {
+		case "added":
+			fmt.Printf("Added %s - %s %s - %s\n", change.Package, change.Vcs, change.VcsSource, change.NewRevision)
+		case "updated":
+			fmt.Printf("Updated %s - %s %s - %s to %s\n", change.Package, change.Vcs, change.VcsSource, change.OldRevision, change.NewRevision)
+		}
+	}
+}
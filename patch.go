@@ -0,0 +1,315 @@
+package main
+
+//
+// applying unified diffs, as written by diff.go, to a directory
+//
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+type patchHunk struct {
+	OldStart int
+	OldLen   int
+	NewStart int
+	NewLen   int
+	Ops      []diffOp
+}
+
+type patchFile struct {
+	OldPath string // without a/ prefix, /dev/null when added
+	NewPath string // without b/ prefix, /dev/null when removed
+	Hunks   []*patchHunk
+}
+
+var rePatchHunk = regexp.MustCompile(`^@@ -(\d+)(?:,(\d+))? \+(\d+)(?:,(\d+))? @@`)
+
+// a/foo/bar.go -> foo/bar.go, drop trailing timestamps
+func patchStripPath(path string) string {
+	if i := strings.IndexByte(path, '\t'); i >= 0 {
+		path = path[:i]
+	}
+	path = strings.TrimSpace(path)
+	if path == "/dev/null" {
+		return path
+	}
+	if i := strings.IndexByte(path, '/'); i >= 0 {
+		return path[i+1:]
+	}
+	return path
+}
+
+func parsePatch(content string) ([]*patchFile, error) {
+	var files []*patchFile
+	var file *patchFile
+	var hunk *patchHunk
+	oldLeft, newLeft := 0, 0 // lines still expected in the current hunk
+
+	lines := strings.Split(content, "\n")
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+
+		if hunk != nil && (oldLeft > 0 || newLeft > 0) {
+			if strings.HasPrefix(line, "\\") {
+				// no newline at end of file, of the line before a change
+				if len(hunk.Ops) > 0 {
+					last := &hunk.Ops[len(hunk.Ops)-1]
+					last.Line = strings.TrimSuffix(last.Line, "\n")
+				}
+				continue
+			}
+			if line == "" {
+				if i == len(lines)-1 {
+					// after the last newline, not a line
+					break
+				}
+				// context line with its trailing space stripped
+				line = " "
+			}
+			kind := line[0]
+			if kind != ' ' && kind != '-' && kind != '+' {
+				return nil, fmt.Errorf("line %d: hunk is shorter than its header says", i+1)
+			}
+			hunk.Ops = append(hunk.Ops, diffOp{kind, line[1:] + "\n"})
+			if kind != '+' {
+				oldLeft--
+			}
+			if kind != '-' {
+				newLeft--
+			}
+			continue
+		}
+
+		switch {
+		case strings.HasPrefix(line, "\\"):
+			// no newline at end of file, applies to the previous line
+			if hunk != nil && len(hunk.Ops) > 0 {
+				last := &hunk.Ops[len(hunk.Ops)-1]
+				last.Line = strings.TrimSuffix(last.Line, "\n")
+			}
+
+		case strings.HasPrefix(line, "--- ") && i+1 < len(lines) && strings.HasPrefix(lines[i+1], "+++ "):
+			file = &patchFile{OldPath: patchStripPath(line[4:]), NewPath: patchStripPath(lines[i+1][4:])}
+			files = append(files, file)
+			hunk = nil
+			i++
+
+		case strings.HasPrefix(line, "@@ "):
+			if file == nil {
+				return nil, fmt.Errorf("line %d: hunk without file header", i+1)
+			}
+			m := rePatchHunk.FindStringSubmatch(line)
+			if m == nil {
+				return nil, fmt.Errorf("line %d: bad hunk header %s", i+1, line)
+			}
+			atoi := func(s string, def int) int {
+				if s == "" {
+					return def
+				}
+				n, _ := strconv.Atoi(s)
+				return n
+			}
+			hunk = &patchHunk{atoi(m[1], 0), atoi(m[2], 1), atoi(m[3], 0), atoi(m[4], 1), nil}
+			oldLeft, newLeft = hunk.OldLen, hunk.NewLen
+			file.Hunks = append(file.Hunks, hunk)
+
+		case strings.HasPrefix(line, "Binary files "):
+			return nil, fmt.Errorf("line %d: binary patches are not supported", i+1)
+		}
+		// anything else, e.g. "diff ..." or "index ..." headers, is ignored
+	}
+
+	if oldLeft > 0 || newLeft > 0 {
+		return nil, errors.New("patch ends in the middle of a hunk")
+	}
+	return files, nil
+}
+
+// apply hunks to lines, hunks may have moved but must match exactly
+func patchApplyHunks(lines []string, hunks []*patchHunk, name string) ([]string, error) {
+	offset := 0
+	for n, hunk := range hunks {
+		var oldLines, newLines []string
+		for _, op := range hunk.Ops {
+			if op.Kind != '+' {
+				oldLines = append(oldLines, op.Line)
+			}
+			if op.Kind != '-' {
+				newLines = append(newLines, op.Line)
+			}
+		}
+
+		matchesAt := func(pos int) bool {
+			if pos < 0 || pos+len(oldLines) > len(lines) {
+				return false
+			}
+			for i, line := range oldLines {
+				if lines[pos+i] != line {
+					return false
+				}
+			}
+			return true
+		}
+
+		want := hunk.OldStart - 1 + offset
+		if hunk.OldLen == 0 {
+			want = hunk.OldStart + offset
+		}
+		found := -1
+		for delta := 0; delta <= len(lines); delta++ {
+			if matchesAt(want - delta) {
+				found = want - delta
+				break
+			}
+			if matchesAt(want + delta) {
+				found = want + delta
+				break
+			}
+		}
+		if found < 0 {
+			return nil, fmt.Errorf("hunk #%d of %s does not apply at line %d", n+1, name, hunk.OldStart)
+		}
+
+		patched := make([]string, 0, len(lines)-len(oldLines)+len(newLines))
+		patched = append(patched, lines[:found]...)
+		patched = append(patched, newLines...)
+		patched = append(patched, lines[found+len(oldLines):]...)
+		lines = patched
+		offset = found - (hunk.OldStart - 1) + len(newLines) - len(oldLines)
+		if hunk.OldLen == 0 {
+			offset = found - hunk.OldStart + len(newLines)
+		}
+	}
+	return lines, nil
+}
+
+// the file path names inside dir, refused when it would lead out of dir,
+// through .., as an absolute path or through a symlink
+func patchTargetPath(dir string, path string) (string, error) {
+	clean := filepath.Clean(filepath.FromSlash(path))
+	if strings.HasPrefix(path, "/") || filepath.IsAbs(clean) || filepath.VolumeName(clean) != "" ||
+		clean == "." || clean == ".." || strings.HasPrefix(clean, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("%s is outside of the patched directory", path)
+	}
+
+	target := dir
+	for _, elem := range strings.Split(clean, string(filepath.Separator)) {
+		target = filepath.Join(target, elem)
+		if stat, err := os.Lstat(target); err == nil && stat.Mode()&os.ModeSymlink != 0 {
+			return "", fmt.Errorf("%s is reached through the symlink %s", path, elem)
+		}
+	}
+	return target, nil
+}
+
+// apply a patch file to dir, nothing is written unless every file applies
+func applyPatchToDir(dir string, content string) error {
+	files, err := parsePatch(content)
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		for _, path := range []string{file.OldPath, file.NewPath} {
+			if path == "/dev/null" {
+				continue
+			}
+			_, err = patchTargetPath(dir, path)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	results := map[string][]string{}
+	removed := map[string]bool{}
+	for _, file := range files {
+		var lines []string
+		if file.OldPath != "/dev/null" {
+			old, err := ioutil.ReadFile(filepath.Join(dir, filepath.FromSlash(file.OldPath)))
+			if err != nil {
+				return fmt.Errorf("%s is missing", file.OldPath)
+			}
+			lines = diffSplitLines(old)
+		}
+
+		name := file.NewPath
+		if name == "/dev/null" {
+			name = file.OldPath
+		}
+		lines, err = patchApplyHunks(lines, file.Hunks, name)
+		if err != nil {
+			return err
+		}
+
+		if file.NewPath == "/dev/null" {
+			if len(lines) > 0 {
+				return fmt.Errorf("%s is not empty after removing its content", file.OldPath)
+			}
+			removed[file.OldPath] = true
+			continue
+		}
+		results[file.NewPath] = lines
+	}
+
+	for path := range removed {
+		err = os.Remove(filepath.Join(dir, filepath.FromSlash(path)))
+		if err != nil {
+			return err
+		}
+	}
+	for path, lines := range results {
+		target := filepath.Join(dir, filepath.FromSlash(path))
+		mode := os.FileMode(0644)
+		if stat, err := os.Stat(target); err == nil {
+			mode = stat.Mode()
+		}
+		err = os.MkdirAll(filepath.Dir(target), os.ModePerm)
+		if err != nil {
+			return err
+		}
+		err = ioutil.WriteFile(target, []byte(strings.Join(lines, "")), mode)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// unified diff of two trees in patch form, paths relative to the trees
+func patchFromTrees(oldDir string, newDir string) (string, error) {
+	diffs, err := diffTrees(oldDir, newDir)
+	if err != nil {
+		return "", err
+	}
+
+	var patch strings.Builder
+	for _, fd := range diffs {
+		if fd.Binary {
+			return "", errors.New("binary file " + fd.Path + " can not be patched")
+		}
+		patch.WriteString(fd.unified("a/"+fd.Path, "b/"+fd.Path))
+	}
+	return patch.String(), nil
+}
+
+// apply the patches of a package in order to a downloaded tree
+func (cmd *ggcmd) applyPackagePatches(vendorDir string, p string, patches []string, dir string) error {
+	for _, patchName := range patches {
+		content, err := readFileString(filepath.Join(vendorDir, filepath.FromSlash(patchName)))
+		if err != nil {
+			return fmt.Errorf("Unable to read patch %s for %s. %s", patchName, p, err)
+		}
+		err = applyPatchToDir(dir, content)
+		if err != nil {
+			return fmt.Errorf("Patch %s no longer applies to %s. %s. Update it or remove it with gg vpatch drop.", patchName, p, err)
+		}
+	}
+	return nil
+}
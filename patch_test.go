@@ -0,0 +1,250 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func patchTestWriteTree(t *testing.T, dir string, files map[string]string) {
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		err := os.MkdirAll(filepath.Dir(path), os.ModePerm)
+		if err == nil {
+			err = ioutil.WriteFile(path, []byte(content), 0644)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func patchTestReadTree(t *testing.T, dir string) map[string]string {
+	files, err := diffListFiles(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree := map[string]string{}
+	for name := range files {
+		content, err := ioutil.ReadFile(filepath.Join(dir, filepath.FromSlash(name)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		tree[name] = string(content)
+	}
+	return tree
+}
+
+func patchTestNumbered(from int, to int) string {
+	var lines []string
+	for i := from; i <= to; i++ {
+		lines = append(lines, "line "+string(rune('a'+i%26))+" "+strings.Repeat("x", i)+"\n")
+	}
+	return strings.Join(lines, "")
+}
+
+var patchTestOld = map[string]string{
+	"a.go":         "package a\n\nfunc A() int {\n\treturn 1\n}\n",
+	"long.txt":     patchTestNumbered(0, 40),
+	"removed.txt":  "going away\n",
+	"sub/b.go":     "package sub\n\nconst B = 2",
+	"sub/keep.txt": "unchanged\n",
+}
+
+var patchTestNew = map[string]string{
+	"a.go":         "package a\n\n// A is one\nfunc A() int {\n\treturn 1\n}\n",
+	"long.txt":     strings.Replace(strings.Replace(patchTestNumbered(0, 40), "line d xxx\n", "line d three\n", 1), "line n xxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxx\n", "", 1),
+	"sub/b.go":     "package sub\n\nconst B = 3\n",
+	"sub/keep.txt": "unchanged\n",
+	"new/c.go":     "package c\n",
+}
+
+func TestPatchRoundTrip(t *testing.T) {
+	oldDir, newDir, workDir := t.TempDir(), t.TempDir(), t.TempDir()
+	patchTestWriteTree(t, oldDir, patchTestOld)
+	patchTestWriteTree(t, newDir, patchTestNew)
+	patchTestWriteTree(t, workDir, patchTestOld)
+
+	patch, err := patchFromTrees(oldDir, newDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = applyPatchToDir(workDir, patch)
+	if err != nil {
+		t.Fatalf("applyPatchToDir: %s\n%s", err, patch)
+	}
+
+	got := patchTestReadTree(t, workDir)
+	for name, content := range patchTestNew {
+		if got[name] != content {
+			t.Errorf("%s after patching is %q, want %q", name, got[name], content)
+		}
+	}
+	for name := range got {
+		if _, ok := patchTestNew[name]; !ok {
+			t.Errorf("%s is left after patching", name)
+		}
+	}
+}
+
+func TestPatchOffset(t *testing.T) {
+	oldDir, newDir, workDir := t.TempDir(), t.TempDir(), t.TempDir()
+	patchTestWriteTree(t, oldDir, patchTestOld)
+	patchTestWriteTree(t, newDir, patchTestNew)
+
+	// upstream added lines above and between the hunks
+	moved := map[string]string{}
+	for name, content := range patchTestOld {
+		moved[name] = content
+	}
+	moved["long.txt"] = "new first\nnew second\n" + strings.Replace(patchTestOld["long.txt"], "line h xxxxxxx\n", "line h xxxxxxx\nadded upstream\n", 1)
+	patchTestWriteTree(t, workDir, moved)
+
+	patch, err := patchFromTrees(oldDir, newDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = applyPatchToDir(workDir, patch)
+	if err != nil {
+		t.Fatalf("applyPatchToDir with moved hunks: %s", err)
+	}
+	got := patchTestReadTree(t, workDir)["long.txt"]
+	want := "new first\nnew second\n" + strings.Replace(patchTestNew["long.txt"], "line h xxxxxxx\n", "line h xxxxxxx\nadded upstream\n", 1)
+	if got != want {
+		t.Errorf("long.txt after patching moved hunks is\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestPatchNoLongerApplies(t *testing.T) {
+	oldDir, newDir, workDir := t.TempDir(), t.TempDir(), t.TempDir()
+	patchTestWriteTree(t, oldDir, patchTestOld)
+	patchTestWriteTree(t, newDir, patchTestNew)
+
+	changed := map[string]string{}
+	for name, content := range patchTestOld {
+		changed[name] = content
+	}
+	changed["sub/b.go"] = "package sub\n\nconst B = 20\n"
+	patchTestWriteTree(t, workDir, changed)
+
+	patch, err := patchFromTrees(oldDir, newDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = applyPatchToDir(workDir, patch)
+	if err == nil || !strings.Contains(err.Error(), "sub/b.go") {
+		t.Fatalf("applyPatchToDir on changed sub/b.go = %v, want an error about it", err)
+	}
+
+	// nothing is written unless every file applies
+	got := patchTestReadTree(t, workDir)
+	for name, content := range changed {
+		if got[name] != content {
+			t.Errorf("%s changed although the patch failed", name)
+		}
+	}
+	if _, ok := got["new/c.go"]; ok {
+		t.Errorf("new/c.go added although the patch failed")
+	}
+}
+
+func TestPatchOutsideDir(t *testing.T) {
+	parent := t.TempDir()
+	dir := filepath.Join(parent, "pkg")
+	patchTestWriteTree(t, dir, map[string]string{"a.go": "package a\n"})
+	err := os.Symlink(parent, filepath.Join(dir, "up"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, path := range []string{"../evil.go", "sub/../../evil.go", "/evil.go", "up/evil.go"} {
+		patch := "--- /dev/null\n+++ b/" + path + "\n@@ -0,0 +1 @@\n+package evil\n"
+		err := applyPatchToDir(dir, patch)
+		if err == nil {
+			t.Errorf("patch adding %s applied, want it refused", path)
+		}
+		if _, err := os.Stat(filepath.Join(parent, "evil.go")); err == nil {
+			t.Fatalf("patch adding %s wrote outside of the directory", path)
+		}
+	}
+}
+
+func TestParsePatch(t *testing.T) {
+	files, err := parsePatch(`diff --git a/x.go b/x.go
+index 1234567..89abcde 100644
+--- a/x.go	2020-01-01 00:00:00
++++ b/x.go	2020-01-02 00:00:00
+@@ -1,2 +1,2 @@
+ package x
+-const X = 1
+\ No newline at end of file
++const X = 2
+--- /dev/null
++++ b/y.go
+@@ -0,0 +1 @@
++package x
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 2 {
+		t.Fatalf("parsePatch found %d files, want 2", len(files))
+	}
+	x := files[0]
+	if x.OldPath != "x.go" || x.NewPath != "x.go" || len(x.Hunks) != 1 {
+		t.Fatalf("first file %+v", x)
+	}
+	ops := x.Hunks[0].Ops
+	if len(ops) != 3 || ops[1].Line != "const X = 1" || ops[2].Line != "const X = 2\n" {
+		t.Errorf("first hunk ops %q", ops)
+	}
+	y := files[1]
+	if y.OldPath != "/dev/null" || y.NewPath != "y.go" || y.Hunks[0].OldLen != 0 || y.Hunks[0].NewLen != 1 {
+		t.Errorf("second file %+v, hunk %+v", y, y.Hunks[0])
+	}
+
+	for _, bad := range []string{
+		"@@ -1 +1 @@\n-a\n+b\n",
+		"--- a/x\n+++ b/x\n@@ -1,3 +1,3 @@\n a\n-b\n+c\n",
+		"--- a/x\n+++ b/x\n@@ -1,2 +1,2 @@\n a\n-b\nnot a hunk line\n",
+		"--- a/x\n+++ b/x\n@@ bad @@\n",
+		"Binary files a/x and b/x differ\n",
+	} {
+		if _, err := parsePatch(bad); err == nil {
+			t.Errorf("parsePatch(%q) has no error", bad)
+		}
+	}
+}
+
+// diff and patch of any two texts give back the new one
+func FuzzPatchRoundTrip(f *testing.F) {
+	f.Add("a\nb\nc\n", "a\nB\nc\n")
+	f.Add("", "new\n")
+	f.Add("gone\n", "")
+	f.Add("no newline", "no newline\n")
+	f.Add("x\ny", "x\nz")
+	f.Add(patchTestOld["long.txt"], patchTestNew["long.txt"])
+	f.Fuzz(func(t *testing.T, old string, new string) {
+		if diffIsBinary([]byte(old)) || diffIsBinary([]byte(new)) {
+			t.Skip()
+		}
+		fd := diffFiles("f", []byte(old), true, []byte(new), true)
+		files, err := parsePatch(fd.unified("a/f", "b/f"))
+		if err != nil {
+			t.Fatalf("parsePatch: %s\n%s", err, fd.unified("a/f", "b/f"))
+		}
+		var hunks []*patchHunk
+		if len(files) > 0 {
+			hunks = files[0].Hunks
+		}
+		lines, err := patchApplyHunks(diffSplitLines([]byte(old)), hunks, "f")
+		if err != nil {
+			t.Fatalf("patchApplyHunks: %s\n%s", err, fd.unified("a/f", "b/f"))
+		}
+		if got := strings.Join(lines, ""); got != new {
+			t.Fatalf("patched is %q, want %q\n%s", got, new, fd.unified("a/f", "b/f"))
+		}
+	})
+}
@@ -1,75 +1,58 @@
 package main
 
 import (
+	"crypto/sha256"
+	"encoding/base64"
 	"errors"
 	"fmt"
 	"io/ioutil"
-	"net/http"
 	"os"
 	"os/exec"
 	"path/filepath"
-	"regexp"
+	"sort"
 	"strings"
 )
 
 // for each package, get canonical parent package
 // note includeTestDeps is ignored if knownPkgs is available
-func (cmd *ggcmd) getMinimalPackagesList(pkgs []string, shallow bool, includeTestDeps bool, knownPkgs map[string]*ggvPackage) map[string][]string {
+// given has the Revision ("" for latest), Include and Exclude of the one
+// package given, nil if none
+func (cmd *ggcmd) getMinimalPackagesList(pkgs []string, shallow bool, includeTestDeps bool, knownPkgs map[string]*ggvPackage, given *ggvPackage) map[string][]string {
 
 	gglog.Printf("len(pkgs)=%d shallow=%v includeTestDeps=%v len(knownPkgs)=%d\n", len(pkgs), shallow, includeTestDeps, len(knownPkgs))
 
-	todoPackages := map[string][]string{}
-	var pkg string
-	var vcs string
-	var vcsSource string
-	var err error
-
-	for _, p := range pkgs {
-
-		var knownPkgInfo *ggvPackage = nil
-
-		// for known packages, skip this meta getting
-		if knownPkgs == nil {
-			pkg, vcs, vcsSource, err = getPkgMeta(p)
-		} else {
-			knownPkgInfo = knownPkgs[p]
-			if knownPkgInfo != nil {
-				pkg, vcs, vcsSource, err = p, knownPkgInfo.Vcs, knownPkgInfo.VcsSource, nil
+	analysis := cmd.newDepAnalysis(knownPkgs)
+	if shallow {
+		for _, p := range pkgs {
+			_, _, err := analysis.repoFor(p)
+			if err != nil {
+				analysis.unresolved(p, err)
 			}
 		}
+	} else {
+		analysis.run(pkgs, includeTestDeps, given)
 
-		if err != nil {
-			fmt.Printf("Unable to resolve a package: %s\n", p)
-			continue
+		specific := analysis.platformSpecific()
+		var names []string
+		for pkg := range specific {
+			names = append(names, pkg)
 		}
-
-		if todoPackages[pkg] == nil {
-			todoPackages[pkg] = []string{pkg, vcs, vcsSource}
-		}
-
-		if shallow {
-			continue
+		sort.Strings(names)
+		for _, pkg := range names {
+			fmt.Fprintf(ggMessagesOut, "Platform specific dependency %s\n", platformNote(pkg, specific))
 		}
+	}
 
-		var recursePkgs []string
+	if len(analysis.Offline) > 0 {
+		ggFatal("%s", offlineMissingError(analysis.Offline))
+	}
 
-		if knownPkgInfo != nil {
-			// use deptest from the known package info
-			recursePkgs = cmd.rdepHelper(p, knownPkgInfo.DepTests)
-		} else {
-			recursePkgs = cmd.rdepHelper(p, includeTestDeps)
-		}
-		for _, pp := range recursePkgs {
-			pkg, vcs, vcsSource, err := getPkgMeta(pp)
-			if err == nil {
-				if todoPackages[pkg] == nil {
-					todoPackages[pkg] = []string{pkg, vcs, vcsSource}
-				}
-			} else {
-				fmt.Printf("Unable to resolve a package: %s\n", pp)
-				continue
-			}
+	todoPackages := map[string][]string{}
+	for pkg, info := range analysis.Repos {
+		if analysis.Failed[pkg] {
+			continue
 		}
+		todoPackages[pkg] = []string{pkg, info.Vcs, info.VcsSource, info.Subdir}
 	}
 	return todoPackages
 }
@@ -86,11 +69,16 @@ func (cmd *ggcmd) downloadUpdate(vendorDir string, vendorRoot string, updatedPac
 	}{}
 
 	// download to temp directories
+	offlineMissing := map[string]bool{}
 	for pkgName, newPkgInfo := range updatedPackages {
 		gglog.Printf("%s %v\n", pkgName, newPkgInfo)
 
 		// revision is set in newPkgInfo anyways...
 		tempDir, destDir, _, err := cmd.downloadPkg(vendorDir, vendorRoot, pkgName, newPkgInfo, true)
+		if _, ok := err.(*offlineError); ok {
+			offlineMissing[pkgName] = true
+			continue
+		}
 		if err != nil {
 			ggFatal("%s", err)
 		}
@@ -99,6 +87,12 @@ func (cmd *ggcmd) downloadUpdate(vendorDir string, vendorRoot string, updatedPac
 			DestDir string
 		}{tempDir, destDir}
 	}
+	if len(offlineMissing) > 0 {
+		for _, dirMove := range downloadedDirs {
+			os.RemoveAll(dirMove.TempDir)
+		}
+		ggFatal("%s", offlineMissingError(offlineMissing))
+	}
 
 	gglog.Printf("%v\n", downloadedDirs)
 
@@ -154,11 +148,45 @@ func (cmd *ggcmd) downloadPkg(vendorDir string, vendorRoot string, p string, inf
 		return "", targetDir, "", errors.New("Unknown vcs, or vcs source")
 	}
 
-	// if revision is "", then latest
-	tempDir, revision, err := cmd.fetchPackage(info.Vcs, info.VcsSource, info.Revision, info.SaveRepo)
-	gglog.Printf("%s %s %s %v\n", p, tempDir, revision, err)
+	// the dependency analysis may have fetched it already
+	var tempDir, revision, zipHash string
+	var vendored bool
+	var err error
+	if tree := cmd.takeFetchedTree(p, info); tree != nil {
+		tempDir, revision, zipHash, vendored = tree.Dir, tree.Revision, tree.ZipHash, tree.Vendored
+	} else if dir, record := cmd.offlineVendoredTree(p, info, false); dir != "" {
+		tempDir, revision, zipHash, vendored = dir, record.Revision, record.ZipHash, true
+	} else {
+		tempDir, revision, zipHash, err = cmd.fetchPkgTree(p, info)
+		if err != nil {
+			return "", targetDir, "", err
+		}
+	}
 
 	info.Revision = revision
+	if info.Vcs == "proxy" {
+		// revision is the module version
+		info.Version = revision
+		info.ZipHash = zipHash
+	}
+
+	// offline copy of the vendor tree, filtered, rewritten, pruned and patched already
+	if vendored {
+		info.TreeHash, err = vendorTreeHash(tempDir)
+		if err != nil {
+			ggFatal("Unable to hash package %s at %s %s", p, tempDir, err)
+		}
+		return tempDir, targetDir, revision, nil
+	}
+
+	// before the rewrite, so what is left out is not rewritten either
+	if info.hasFilter() {
+		removed, err := filterPackageDir(tempDir, info)
+		if err != nil {
+			ggFatal("Unable to filter package %s at %s %s", p, tempDir, err)
+		}
+		gglog.Printf("filterPackageDir %s removed %v\n", p, removed)
+	}
 
 	if info.RewriteImports {
 		err = cmd.astmodVendorWithPrefix(nil, vendorRoot, tempDir, false)
@@ -168,9 +196,109 @@ func (cmd *ggcmd) downloadPkg(vendorDir string, vendorRoot string, p string, inf
 		}
 	}
 
+	if info.Prune != nil {
+		extraUsed := cmd.pruneUsedByVendorDir(vendorDir, vendorRoot, p)
+		_, err = cmd.prunePackageDir(tempDir, p, vendorRoot, info.Prune, extraUsed, false)
+		if err != nil {
+			ggFatal("Unable to prune package %s at %s %s", p, tempDir, err)
+		}
+	}
+
+	err = cmd.applyPackagePatches(vendorDir, p, info.Patches, tempDir)
+	if err != nil {
+		ggFatal("%s", err)
+	}
+
+	info.TreeHash, err = vendorTreeHash(tempDir)
+	if err != nil {
+		ggFatal("Unable to hash package %s at %s %s", p, tempDir, err)
+	}
+
 	return tempDir, targetDir, revision, nil
 }
 
+// h1: hash of the files in a vendored tree, as for module zips, .git and
+// .hg left out
+func vendorTreeHash(dir string) (string, error) {
+	files, err := diffListFiles(dir)
+	if err != nil {
+		return "", err
+	}
+	var names []string
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	summary := sha256.New()
+	for _, name := range names {
+		content, err := ioutil.ReadFile(filepath.Join(dir, filepath.FromSlash(name)))
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(summary, "%x  %s\n", sha256.Sum256(content), name)
+	}
+	return "h1:" + base64.StdEncoding.EncodeToString(summary.Sum(nil)), nil
+}
+
+// tempdir, revision, zip hash, error
+// if revision is "", then latest
+// try the sources in order, the canonical VcsSource stays as is
+func (cmd *ggcmd) fetchPkgTree(p string, info *ggvPackage) (string, string, string, error) {
+	sources := cmd.vcsSourcesFor(info)
+	if isOffline() {
+		sources = offlineSources(p, info, sources)
+	}
+
+	var tempDir, revision, zipHash string
+	var err error
+	if isOffline() && info.Vcs != "proxy" {
+		tempDir, revision, err = cmd.fetchPackageOffline(p, info)
+	}
+	for _, vcsSource := range sources {
+		if info.Vcs == "proxy" {
+			tempDir, revision, zipHash, err = cmd.fetchPackageProxy(p, vcsSource, info.Revision, info.Version, info.ZipHash)
+		} else {
+			tempDir, revision, err = cmd.fetchPackage(info.Vcs, vcsSource, info.Revision, info.SaveRepo)
+		}
+		gglog.Printf("%s %s %s %s %v\n", p, vcsSource, tempDir, revision, err)
+		if err == nil {
+			break
+		}
+		fmt.Fprintf(ggMessagesOut, "Unable to fetch %s from %s. %s\n", p, vcsSource, err)
+	}
+	if isOffline() && (err != nil || tempDir == "") {
+		return "", "", "", &offlineError{p, info.Revision}
+	}
+	if err != nil {
+		return "", "", "", errors.New("Unable to fetch " + p + " from any source")
+	}
+
+	if info.Subdir != "" {
+		tempDir, err = fetchedSubdir(tempDir, info.Subdir)
+		if err != nil {
+			return "", "", "", fmt.Errorf("Unable to use subdirectory %s of %s %s", info.Subdir, p, err)
+		}
+	}
+	return tempDir, revision, zipHash, nil
+}
+
+// the package lives in a subdirectory of the repo, keep only that
+func fetchedSubdir(tempDir string, subdir string) (string, error) {
+	defer os.RemoveAll(tempDir)
+
+	newTempDir, err := ioutil.TempDir("", "gg")
+	if err != nil {
+		return "", err
+	}
+	err = copyDir(filepath.Join(tempDir, filepath.FromSlash(subdir)), newTempDir)
+	if err != nil {
+		os.RemoveAll(newTempDir)
+		return "", err
+	}
+	return newTempDir, nil
+}
+
 // tempdir, revision fetched, error
 func (cmd *ggcmd) fetchPackage(vcs string, vcsSource string, revision string, saveRepo bool) (string, string, error) {
 	if vcs == "git" {
@@ -192,9 +320,14 @@ func (cmd *ggcmd) fetchPackageGit(vcsSource string, revision string, saveRepo bo
 	}
 
 	subcmd := exec.Command("git", "clone", vcsSource, tempdir)
-	err = subcmd.Run()
+	subcmd.Env = cmd.vcsEnv("git", vcsSource)
+	out, err := subcmd.CombinedOutput()
 	if err != nil {
-		ggFatal("Unable to git clone %s %s", vcsSource, err)
+		os.RemoveAll(tempdir)
+		if authErr := vcsAuthError(vcsSource, out); authErr != nil {
+			return "", "", authErr
+		}
+		return "", "", fmt.Errorf("Unable to git clone %s %s", vcsSource, err)
 	}
 
 	if revision != "" {
@@ -203,7 +336,8 @@ func (cmd *ggcmd) fetchPackageGit(vcsSource string, revision string, saveRepo bo
 		subcmd.Dir = tempdir
 		err = subcmd.Run()
 		if err != nil {
-			ggFatal("Unable to git checkout %s %s %s", vcsSource, revision, err)
+			os.RemoveAll(tempdir)
+			return "", "", fmt.Errorf("Unable to git checkout %s %s %s", vcsSource, revision, err)
 		}
 	}
 
@@ -212,7 +346,8 @@ func (cmd *ggcmd) fetchPackageGit(vcsSource string, revision string, saveRepo bo
 	subcmd.Dir = tempdir
 	revisionRaw, err = subcmd.Output()
 	if err != nil {
-		ggFatal("Unable to git log %s %s", vcsSource, err)
+		os.RemoveAll(tempdir)
+		return "", "", fmt.Errorf("Unable to git log %s %s", vcsSource, err)
 	}
 
 	if !saveRepo {
@@ -236,9 +371,14 @@ func (cmd *ggcmd) fetchPackageHg(vcsSource string, revision string, saveRepo boo
 	}
 
 	subcmd := exec.Command("hg", "clone", vcsSource, tempdir)
-	err = subcmd.Run()
+	subcmd.Env = cmd.vcsEnv("hg", vcsSource)
+	out, err := subcmd.CombinedOutput()
 	if err != nil {
-		ggFatal("Unable to hg clone %s %s", vcsSource, err)
+		os.RemoveAll(tempdir)
+		if authErr := vcsAuthError(vcsSource, out); authErr != nil {
+			return "", "", authErr
+		}
+		return "", "", fmt.Errorf("Unable to hg clone %s %s", vcsSource, err)
 	}
 
 	if revision != "" {
@@ -246,7 +386,8 @@ func (cmd *ggcmd) fetchPackageHg(vcsSource string, revision string, saveRepo boo
 		subcmd.Dir = tempdir
 		err = subcmd.Run()
 		if err != nil {
-			ggFatal("Unable to hg update %s %s", vcsSource, err)
+			os.RemoveAll(tempdir)
+			return "", "", fmt.Errorf("Unable to hg update %s %s", vcsSource, err)
 		}
 	}
 
@@ -255,7 +396,8 @@ func (cmd *ggcmd) fetchPackageHg(vcsSource string, revision string, saveRepo boo
 	subcmd.Dir = tempdir
 	revisionRaw, err = subcmd.Output()
 	if err != nil {
-		ggFatal("Unable to hg identify %s %s", vcsSource, err)
+		os.RemoveAll(tempdir)
+		return "", "", fmt.Errorf("Unable to hg identify %s %s", vcsSource, err)
 	}
 
 	if !saveRepo {
@@ -267,65 +409,3 @@ func (cmd *ggcmd) fetchPackageHg(vcsSource string, revision string, saveRepo boo
 	}
 	return tempdir, strings.TrimSpace(string(revisionRaw)), nil
 }
-
-// package, vcs, vcssource, error
-func getPkgMeta(p string) (string, string, string, error) {
-	// curl the package as a url
-	// parse and look for meta
-
-	reMeta := regexp.MustCompile("<meta [^>]*>")
-	reContent := regexp.MustCompile("content=\"([^\"]*)\"")
-	reName := regexp.MustCompile("name=\"([^\"]*)\"")
-
-	for len(p) > 0 {
-		purl := "https://" + p + "?go-get=1"
-		resp, err := http.Get(purl)
-
-		if err != nil {
-			// maybe http instead?
-			purl = "http://" + p + "?go-get=1"
-			resp, err = http.Get(purl)
-		}
-
-		if err != nil {
-			// chop p, and continue
-			pparts := strings.Split(p, "/")
-			p = strings.Join(pparts[:len(pparts)-1], "/")
-			continue
-		}
-
-		body, err := ioutil.ReadAll(resp.Body)
-		if err != nil {
-			continue // pretty bad error
-		}
-		//fmt.Printf("BODY: %s\n", string(body))
-		metas := reMeta.FindAllString(string(body), -1)
-
-		// look for the meta e.g.
-		// <meta name="go-import" content="gopkg.in/mgo.v2 git https://gopkg.in/mgo.v2">
-		for _, meta := range metas {
-			//fmt.Printf("META: %s\n", meta)
-			mname := reName.FindAllStringSubmatch(meta, -1)
-			if mname == nil || mname[0][1] != "go-import" {
-				continue
-			}
-
-			// found it
-			mcontent := reContent.FindAllStringSubmatch(meta, -1)
-			if mcontent == nil {
-				continue
-			}
-			mcontentParts := strings.Split(mcontent[0][1], " ")
-			if len(mcontentParts) != 3 {
-				continue
-			}
-
-			return mcontentParts[0], mcontentParts[1], mcontentParts[2], nil
-		}
-
-		pparts := strings.Split(p, "/")
-		p = strings.Join(pparts[:len(pparts)-1], "/")
-	}
-
-	return "", "", "", errors.New("Unable to get package meta info")
-}
@@ -0,0 +1,118 @@
+package main
+
+//
+// GOOS/GOARCH pairs and build tags dependencies are collected for, the union
+// of their imports is vendored
+//
+
+import (
+	"errors"
+	"go/build"
+	"os"
+	"sort"
+	"strings"
+)
+
+type depPlatform struct {
+	Goos   string
+	Goarch string
+	Tags   []string
+}
+
+func (platform depPlatform) String() string {
+	return platform.Goos + "/" + platform.Goarch
+}
+
+// "linux/amd64", ... from the command line or _ggv.json, the host if none
+func parsePlatforms(names []string, tags []string) ([]depPlatform, error) {
+	if len(names) == 0 {
+		return []depPlatform{{build.Default.GOOS, build.Default.GOARCH, tags}}, nil
+	}
+
+	var platforms []depPlatform
+	seen := map[string]bool{}
+	for _, name := range names {
+		parts := strings.Split(name, "/")
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return nil, errors.New("Bad platform " + name + ", expected GOOS/GOARCH, e.g. linux/amd64")
+		}
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+		platforms = append(platforms, depPlatform{parts[0], parts[1], tags})
+	}
+	return platforms, nil
+}
+
+// options win over the vendor root settings, ggv may be nil
+func (cmd *ggcmd) setDepPlatforms(optPlatforms argOptionStr, optTags argOptionStr, ggv *ggvJson) {
+	var names, tags []string
+	if ggv != nil {
+		names, tags = ggv.Platforms, ggv.BuildTags
+	}
+	if optPlatforms.IsSet {
+		names = splitCommaList(optPlatforms.String)
+	}
+	if optTags.IsSet {
+		tags = splitCommaList(optTags.String)
+	}
+
+	platforms, err := parsePlatforms(names, tags)
+	if err != nil {
+		ggFatal("%s", err)
+	}
+	cmd.depPlatforms = platforms
+}
+
+func (cmd *ggcmd) platforms() []depPlatform {
+	if cmd.depPlatforms == nil {
+		cmd.depPlatforms, _ = parsePlatforms(nil, nil)
+	}
+	return cmd.depPlatforms
+}
+
+// cgo files count too, their imports are needed wherever cgo is used
+func (platform depPlatform) buildContext() build.Context {
+	ctx := build.Default
+	ctx.GOOS = platform.Goos
+	ctx.GOARCH = platform.Goarch
+	ctx.BuildTags = platform.Tags
+	ctx.CgoEnabled = true
+	return ctx
+}
+
+// env for go list, nil for the host without tags
+func (platform depPlatform) goListEnv() []string {
+	if platform.Goos == build.Default.GOOS && platform.Goarch == build.Default.GOARCH && len(platform.Tags) == 0 {
+		return nil
+	}
+	env := append(os.Environ(), "GOOS="+platform.Goos, "GOARCH="+platform.Goarch)
+	if len(platform.Tags) > 0 {
+		env = append(env, "GOFLAGS="+strings.TrimSpace(os.Getenv("GOFLAGS")+" -tags="+strings.Join(platform.Tags, ",")))
+	}
+	return env
+}
+
+// package -> platforms it is needed on, for packages not needed on all of them
+func platformSpecific(needs map[string]map[string]bool, platforms []depPlatform) map[string][]string {
+	specific := map[string][]string{}
+	for pkg, on := range needs {
+		if len(on) == 0 || len(on) == len(platforms) {
+			continue
+		}
+		for name := range on {
+			specific[pkg] = append(specific[pkg], name)
+		}
+		sort.Strings(specific[pkg])
+	}
+	return specific
+}
+
+// "pkg" or "pkg (windows/amd64, windows/386)"
+func platformNote(pkg string, specific map[string][]string) string {
+	if len(specific[pkg]) == 0 {
+		return pkg
+	}
+	return pkg + " (" + strings.Join(specific[pkg], ", ") + ")"
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"go/build"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParsePlatforms(t *testing.T) {
+	host := depPlatform{build.Default.GOOS, build.Default.GOARCH, nil}
+	tests := []struct {
+		names string
+		tags  string
+		want  []depPlatform
+		err   bool
+	}{
+		{"", "", []depPlatform{host}, false},
+		{"", "netgo,osusergo", []depPlatform{{host.Goos, host.Goarch, []string{"netgo", "osusergo"}}}, false},
+		{"linux/amd64", "", []depPlatform{{"linux", "amd64", nil}}, false},
+		{" linux/amd64 , windows/386,,linux/amd64", "", []depPlatform{{"linux", "amd64", nil}, {"windows", "386", nil}}, false},
+		{"darwin/arm64,js/wasm", "purego", []depPlatform{{"darwin", "arm64", []string{"purego"}}, {"js", "wasm", []string{"purego"}}}, false},
+		{"linux", "", nil, true},
+		{"linux/", "", nil, true},
+		{"/amd64", "", nil, true},
+		{"linux/arm/v7", "", nil, true},
+		{"linux/amd64,windows", "", nil, true},
+	}
+	for _, test := range tests {
+		platforms, err := parsePlatforms(splitCommaList(test.names), splitCommaList(test.tags))
+		if test.err {
+			if err == nil {
+				t.Errorf("parsePlatforms %q = %v, want an error", test.names, platforms)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parsePlatforms %q %v", test.names, err)
+			continue
+		}
+		if !reflect.DeepEqual(platforms, test.want) {
+			t.Errorf("parsePlatforms %q %q = %v, want %v", test.names, test.tags, platforms, test.want)
+		}
+	}
+}
+
+func TestPlatformSpecific(t *testing.T) {
+	platforms, _ := parsePlatforms([]string{"linux/amd64", "windows/amd64", "windows/386"}, nil)
+	needs := map[string]map[string]bool{
+		"example.com/all":     {"linux/amd64": true, "windows/amd64": true, "windows/386": true},
+		"example.com/windows": {"windows/386": true, "windows/amd64": true},
+		"example.com/linux":   {"linux/amd64": true},
+		"example.com/none":    {},
+	}
+	specific := platformSpecific(needs, platforms)
+	want := map[string][]string{
+		"example.com/windows": {"windows/386", "windows/amd64"},
+		"example.com/linux":   {"linux/amd64"},
+	}
+	if !reflect.DeepEqual(specific, want) {
+		t.Errorf("platformSpecific = %v, want %v", specific, want)
+	}
+
+	notes := map[string]string{
+		"example.com/all":     "example.com/all",
+		"example.com/windows": "example.com/windows (windows/386, windows/amd64)",
+		"example.com/linux":   "example.com/linux (linux/amd64)",
+	}
+	for pkg, note := range notes {
+		if got := platformNote(pkg, specific); got != note {
+			t.Errorf("platformNote %s = %q, want %q", pkg, got, note)
+		}
+	}
+}
+
+func TestPlatformGoListEnv(t *testing.T) {
+	t.Setenv("GOFLAGS", "-mod=mod")
+	host := depPlatform{build.Default.GOOS, build.Default.GOARCH, nil}
+	if env := host.goListEnv(); env != nil {
+		t.Errorf("goListEnv of the host = %v, want nil", env)
+	}
+
+	other := depPlatform{"plan9", "386", []string{"a", "b"}}
+	env := other.goListEnv()
+	want := []string{"GOOS=plan9", "GOARCH=386", "GOFLAGS=-mod=mod -tags=a,b"}
+	if len(env) < len(want) || !reflect.DeepEqual(env[len(env)-len(want):], want) {
+		t.Errorf("goListEnv of %v = %v, want it to end with %s", other, env, strings.Join(want, " "))
+	}
+}
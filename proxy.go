@@ -0,0 +1,240 @@
+package main
+
+//
+// fetching module zips from a GOPROXY protocol server, https:// or file://
+// the VcsSource of a proxy package is the base url followed by the escaped
+// module path, e.g. https://proxy.golang.org/github.com/!burnt!sushi/toml,
+// the package name is the module path
+//
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const proxyDefault = "https://proxy.golang.org"
+
+// answer of @v/<version>.info and @latest
+type proxyInfo struct {
+	Version string
+	Time    string
+}
+
+// upper case letters become !lower, as in the module cache
+func proxyEscapePath(path string) string {
+	var escaped strings.Builder
+	for _, r := range path {
+		if 'A' <= r && r <= 'Z' {
+			escaped.WriteByte('!')
+			escaped.WriteRune(r + 'a' - 'A')
+		} else {
+			escaped.WriteRune(r)
+		}
+	}
+	return escaped.String()
+}
+
+// first usable entry of $GOPROXY, or proxy.golang.org
+func proxyBaseUrl() string {
+	for _, entry := range strings.FieldsFunc(os.Getenv("GOPROXY"), func(r rune) bool { return r == ',' || r == '|' }) {
+		if entry != "direct" && entry != "off" {
+			return strings.TrimSuffix(entry, "/")
+		}
+	}
+	return proxyDefault
+}
+
+func (cmd *ggcmd) proxyGet(vcsSource string, name string) ([]byte, error) {
+	target := strings.TrimSuffix(vcsSource, "/") + "/" + name
+	gglog.Printf("proxyGet %s\n", target)
+
+	if strings.HasPrefix(target, "file://") {
+		u, err := url.Parse(target)
+		if err != nil {
+			return nil, err
+		}
+		return ioutil.ReadFile(filepath.FromSlash(u.Path))
+	}
+
+	resp, err := cmd.httpGetWithAuth(target)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.New(target + " " + resp.Status)
+	}
+	return ioutil.ReadAll(resp.Body)
+}
+
+// resolve a version query, "" is the latest release
+func (cmd *ggcmd) proxyResolveVersion(vcsSource string, query string) (string, error) {
+	if query != "" {
+		if content, err := cmd.proxyGet(vcsSource, "@v/"+query+".info"); err == nil {
+			var info proxyInfo
+			if json.Unmarshal(content, &info) == nil && info.Version != "" {
+				return info.Version, nil
+			}
+		}
+		return "", errors.New("Unknown version " + query + " at " + vcsSource)
+	}
+
+	// highest from the list, releases before pre-releases
+	if content, err := cmd.proxyGet(vcsSource, "@v/list"); err == nil {
+		best := ""
+		for _, version := range strings.Fields(string(content)) {
+			if best == "" || proxyVersionLess(best, version) {
+				best = version
+			}
+		}
+		if best != "" {
+			return best, nil
+		}
+	}
+
+	// no tagged versions, pseudo version of the default branch
+	content, err := cmd.proxyGet(vcsSource, "@latest")
+	if err != nil {
+		return "", err
+	}
+	var info proxyInfo
+	err = json.Unmarshal(content, &info)
+	if err != nil || info.Version == "" {
+		return "", errors.New("No versions found at " + vcsSource)
+	}
+	return info.Version, nil
+}
+
+func proxyVersionLess(a string, b string) bool {
+	aPre, bPre := strings.Contains(a, "-"), strings.Contains(b, "-")
+	if aPre != bPre {
+		return aPre
+	}
+	return compareVersions(a, b) < 0
+}
+
+// h1: hash of the zip content, as in go.sum
+func proxyZipHash(content []byte) (string, error) {
+	reader, err := zip.NewReader(bytes.NewReader(content), int64(len(content)))
+	if err != nil {
+		return "", err
+	}
+
+	files := map[string]*zip.File{}
+	var names []string
+	// every entry counts, directories included, as go does
+	for _, f := range reader.File {
+		files[f.Name] = f
+		names = append(names, f.Name)
+	}
+	sort.Strings(names)
+
+	summary := sha256.New()
+	for _, name := range names {
+		r, err := files[name].Open()
+		if err != nil {
+			return "", err
+		}
+		h := sha256.New()
+		_, err = io.Copy(h, r)
+		r.Close()
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(summary, "%x  %s\n", h.Sum(nil), name)
+	}
+	return "h1:" + base64.StdEncoding.EncodeToString(summary.Sum(nil)), nil
+}
+
+// unzip module@version/... into dir
+func proxyUnzip(content []byte, prefix string, dir string) error {
+	reader, err := zip.NewReader(bytes.NewReader(content), int64(len(content)))
+	if err != nil {
+		return err
+	}
+
+	for _, f := range reader.File {
+		if !strings.HasPrefix(f.Name, prefix) {
+			return errors.New("Unexpected file " + f.Name + " in module zip")
+		}
+		rel := strings.TrimPrefix(f.Name, prefix)
+		if rel == "" || strings.HasSuffix(rel, "/") {
+			continue
+		}
+		target := filepath.Join(dir, filepath.FromSlash(rel))
+		if !strings.HasPrefix(target, filepath.Clean(dir)+string(os.PathSeparator)) {
+			return errors.New("Bad file name " + f.Name + " in module zip")
+		}
+
+		err = os.MkdirAll(filepath.Dir(target), os.ModePerm)
+		if err != nil {
+			return err
+		}
+		r, err := f.Open()
+		if err != nil {
+			return err
+		}
+		w, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+		if err != nil {
+			r.Close()
+			return err
+		}
+		_, err = io.Copy(w, r)
+		r.Close()
+		w.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// tempdir, version, zip hash, error
+// expectedHash is checked when fetching the version it was recorded for
+func (cmd *ggcmd) fetchPackageProxy(modulePath string, vcsSource string, revision string, expectedVersion string, expectedHash string) (string, string, string, error) {
+	gglog.Printf("fetchPackageProxy modulePath=%s vcsSource=%s revision=%s\n", modulePath, vcsSource, revision)
+
+	version, err := cmd.proxyResolveVersion(vcsSource, revision)
+	if err != nil {
+		return "", "", "", err
+	}
+
+	content, err := cmd.proxyGet(vcsSource, "@v/"+version+".zip")
+	if err != nil {
+		return "", "", "", err
+	}
+
+	hash, err := proxyZipHash(content)
+	if err != nil {
+		return "", "", "", fmt.Errorf("Unable to read zip of %s %s %s", modulePath, version, err)
+	}
+	if expectedHash != "" && version == expectedVersion && hash != expectedHash {
+		return "", "", "", fmt.Errorf("Zip of %s %s has hash %s, expected %s", modulePath, version, hash, expectedHash)
+	}
+
+	tempdir, err := ioutil.TempDir("", "gg")
+	if err != nil {
+		ggFatal("Unable to create temp directory %s", err)
+	}
+
+	err = proxyUnzip(content, modulePath+"@"+version+"/", tempdir)
+	if err != nil {
+		os.RemoveAll(tempdir)
+		return "", "", "", fmt.Errorf("Unable to unzip %s %s %s", modulePath, version, err)
+	}
+
+	return tempdir, version, hash, nil
+}
@@ -0,0 +1,197 @@
+package main
+
+//
+// pruning vendored repos down to what is used
+//
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// source files the go tool may build, everything else is an asset
+var pruneSourceExts = map[string]bool{
+	".go": true, ".s": true, ".S": true, ".c": true, ".h": true, ".cc": true,
+	".cpp": true, ".cxx": true, ".hh": true, ".hpp": true, ".hxx": true,
+	".m": true, ".f": true, ".F": true, ".for": true, ".f90": true,
+	".swig": true, ".swigcxx": true, ".syso": true,
+}
+
+var pruneLicensePrefixes = []string{
+	"license", "licence", "copying", "notice", "authors", "contributors", "patents", "unlicense",
+}
+
+// license and similar legal files are never pruned, sources named like
+// them, license.go or notice_test.go, are sources
+func isLicenseFile(name string) bool {
+	if pruneSourceExts[filepath.Ext(name)] {
+		return false
+	}
+	lower := strings.ToLower(name)
+	for _, prefix := range pruneLicensePrefixes {
+		if strings.HasPrefix(lower, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// sub-packages of pkgName imported from outside of pkgName
+func (cmd *ggcmd) pruneUsedPackages(graph importGraph, pkgName string) []string {
+	inPkg := func(p string) bool {
+		return p == pkgName || strings.HasPrefix(p, pkgName+"/")
+	}
+
+	usedMap := map[string]bool{}
+	for pkg, imports := range graph {
+		if inPkg(pkg) {
+			continue
+		}
+		for _, imp := range imports {
+			if inPkg(imp) {
+				usedMap[imp] = true
+			}
+		}
+	}
+
+	used := []string{}
+	for p := range usedMap {
+		used = append(used, p)
+	}
+	sort.Strings(used)
+	return used
+}
+
+// prune a downloaded (and rewritten) package directory according to policy.
+// Returns removed files relative to dir.
+func (cmd *ggcmd) prunePackageDir(dir string, pkgName string, vendorRoot string, prune *ggvPrune, extraUsed []string, dryrun bool) ([]string, error) {
+	var removed []string
+
+	// packages we keep when pruning unused ones
+	keepPkgs := map[string]bool{}
+	if prune.UnusedPackages {
+		rawGraph, err := scanImportGraph(dir, pkgName, true)
+		if err != nil {
+			return nil, err
+		}
+		graph := importGraph{}
+		cmd.mergeImportGraph(graph, rawGraph, vendorRoot, true)
+
+		queue := append([]string{}, prune.Used...)
+		queue = append(queue, extraUsed...)
+		if len(queue) == 0 {
+			queue = append(queue, pkgName)
+		}
+		for len(queue) > 0 {
+			p := queue[0]
+			queue = queue[1:]
+			if keepPkgs[p] {
+				continue
+			}
+			keepPkgs[p] = true
+			for _, imp := range graph[p] {
+				if imp == pkgName || strings.HasPrefix(imp, pkgName+"/") {
+					queue = append(queue, imp)
+				}
+			}
+		}
+	}
+
+	var emptyCandidates []string
+	err := filepath.Walk(dir, func(path string, f os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+
+		if f.IsDir() {
+			if path == dir {
+				return nil
+			}
+			// .git, .hg when saving repos
+			if strings.HasPrefix(f.Name(), ".") {
+				return filepath.SkipDir
+			}
+			if prune.Testdata && f.Name() == "testdata" {
+				removed = append(removed, filepath.ToSlash(rel)+"/")
+				if !dryrun {
+					err = os.RemoveAll(path)
+					if err != nil {
+						return err
+					}
+				}
+				return filepath.SkipDir
+			}
+			emptyCandidates = append(emptyCandidates, path)
+			return nil
+		}
+
+		name := f.Name()
+		if isLicenseFile(name) {
+			return nil
+		}
+
+		remove := false
+		ext := filepath.Ext(name)
+		if prune.Tests && strings.HasSuffix(name, "_test.go") {
+			remove = true
+		} else if prune.NonGo && !pruneSourceExts[ext] {
+			remove = true
+		} else if prune.UnusedPackages && pruneSourceExts[ext] {
+			pkg := pkgName
+			if relDir := filepath.Dir(rel); relDir != "." {
+				pkg = pkgName + "/" + filepath.ToSlash(relDir)
+			}
+			remove = !keepPkgs[pkg]
+		}
+
+		if remove {
+			removed = append(removed, filepath.ToSlash(rel))
+			if !dryrun {
+				return os.Remove(path)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if !dryrun {
+		// deepest first, so parents may become empty as well
+		sort.Sort(sort.Reverse(sort.StringSlice(emptyCandidates)))
+		for _, path := range emptyCandidates {
+			entries, err := ioutil.ReadDir(path)
+			if err == nil && len(entries) == 0 {
+				os.Remove(path)
+			}
+		}
+	}
+
+	return removed, nil
+}
+
+// packages of pkgName imported by the rest of the vendor tree on disk, so a
+// reapplied prune keeps what other vendored packages need
+func (cmd *ggcmd) pruneUsedByVendorDir(vendorDir string, vendorRoot string, pkgName string) []string {
+	if cmd.pruneVendorGraph == nil {
+		rawGraph, err := scanImportGraph(vendorDir, vendorRoot, false)
+		if err != nil {
+			gglog.Printf("Unable to scan %s %s\n", vendorDir, err)
+		}
+		cmd.pruneVendorGraph = importGraph{}
+		cmd.mergeImportGraph(cmd.pruneVendorGraph, rawGraph, vendorRoot, true)
+	}
+
+	graph := importGraph{}
+	for pkg, imports := range cmd.pruneVendorGraph {
+		graph[canonicalImport(vendorRoot, pkg)] = imports
+	}
+	return cmd.pruneUsedPackages(graph, pkgName)
+}
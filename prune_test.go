@@ -0,0 +1,142 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestIsLicenseFile(t *testing.T) {
+	tests := map[string]bool{
+		"LICENSE":          true,
+		"LICENSE.txt":      true,
+		"license.md":       true,
+		"Licence":          true,
+		"COPYING":          true,
+		"NOTICE":           true,
+		"AUTHORS":          true,
+		"CONTRIBUTORS":     true,
+		"PATENTS":          true,
+		"UNLICENSE":        true,
+		"README.md":        false,
+		"main.go":          false,
+		"license.go":       false,
+		"license_test.go":  false,
+		"notice.go":        false,
+		"authors.c":        false,
+		"copying_amd64.s":  false,
+		"licenses.syso":    false,
+		"mylicense":        false,
+		"LICENSE-APACHE":   true,
+		"license_check.sh": true,
+	}
+	for name, want := range tests {
+		if got := isLicenseFile(name); got != want {
+			t.Errorf("isLicenseFile %s = %v, want %v", name, got, want)
+		}
+	}
+}
+
+func TestPruneUsedPackages(t *testing.T) {
+	graph := importGraph{
+		"example.com/app":       {"example.com/a", "example.com/a/sub"},
+		"example.com/other":     {"example.com/a/util", "example.com/ab"},
+		"example.com/a":         {"example.com/a/internal"},
+		"example.com/a/sub":     {"example.com/a/internal"},
+		"example.com/a/unused":  {"example.com/a"},
+		"example.com/unrelated": {"example.com/b"},
+	}
+	used := (&ggcmd{}).pruneUsedPackages(graph, "example.com/a")
+	want := []string{"example.com/a", "example.com/a/sub", "example.com/a/util"}
+	if !reflect.DeepEqual(used, want) {
+		t.Errorf("pruneUsedPackages = %v, want %v", used, want)
+	}
+}
+
+func testPruneTree(t *testing.T, files map[string]string) string {
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		err := os.MkdirAll(filepath.Dir(path), 0755)
+		if err == nil {
+			err = ioutil.WriteFile(path, []byte(content), 0644)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestPrunePackageDir(t *testing.T) {
+	files := map[string]string{
+		"LICENSE":               "MIT",
+		"README.md":             "# a",
+		"a.go":                  "package a\n\nimport _ \"example.com/a/used\"\n",
+		"a_test.go":             "package a\n",
+		"license.go":            "package a\n",
+		"license_test.go":       "package a\n",
+		"asm_amd64.s":           "",
+		"testdata/LICENSE":      "sample",
+		"testdata/in.json":      "{}",
+		"used/used.go":          "package used\n",
+		"used/notice.go":        "package used\n",
+		"unused/unused.go":      "package unused\n",
+		"unused/license.go":     "package unused\n",
+		"unused/NOTICE":         "legal",
+		"unused/data.txt":       "data",
+		".git/HEAD":             "ref",
+		"cmd/tool/main.go":      "package main\n\nimport _ \"example.com/a\"\n",
+		"cmd/tool/main_test.go": "package main\n",
+	}
+	tests := []struct {
+		prune   ggvPrune
+		removed []string
+	}{
+		{ggvPrune{Tests: true}, []string{"a_test.go", "cmd/tool/main_test.go", "license_test.go"}},
+		{ggvPrune{Testdata: true}, []string{"testdata/"}},
+		{ggvPrune{NonGo: true}, []string{"README.md", "testdata/in.json", "unused/data.txt"}},
+		{ggvPrune{UnusedPackages: true}, []string{"cmd/tool/main.go", "cmd/tool/main_test.go", "unused/license.go", "unused/unused.go"}},
+		{ggvPrune{UnusedPackages: true, Used: []string{"example.com/a/cmd/tool"}}, []string{"unused/license.go", "unused/unused.go"}},
+		{ggvPrune{Tests: true, Testdata: true, NonGo: true, UnusedPackages: true}, []string{
+			"README.md", "a_test.go", "cmd/tool/main.go", "cmd/tool/main_test.go", "license_test.go",
+			"testdata/", "unused/data.txt", "unused/license.go", "unused/unused.go"}},
+	}
+	cmd := &ggcmd{}
+	for _, test := range tests {
+		prune := test.prune
+		dir := testPruneTree(t, files)
+		dryRemoved, err := cmd.prunePackageDir(dir, "example.com/a", "", &prune, nil, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		removed, err := cmd.prunePackageDir(dir, "example.com/a", "", &prune, nil, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sort.Strings(dryRemoved)
+		sort.Strings(removed)
+		if !reflect.DeepEqual(removed, test.removed) {
+			t.Errorf("prunePackageDir %+v removed %v, want %v", prune, removed, test.removed)
+		}
+		if !reflect.DeepEqual(dryRemoved, removed) {
+			t.Errorf("prunePackageDir %+v dry run would remove %v, removed %v", prune, dryRemoved, removed)
+		}
+
+		for name := range files {
+			_, err := os.Stat(filepath.Join(dir, filepath.FromSlash(name)))
+			gone := false
+			for _, rel := range removed {
+				if rel == name || (rel[len(rel)-1] == '/' && metaPrefixMatches(rel[:len(rel)-1], name)) {
+					gone = true
+				}
+			}
+			if gone != os.IsNotExist(err) {
+				t.Errorf("prunePackageDir %+v: %s removed %v, on disk %v", prune, name, gone, err)
+			}
+		}
+	}
+}
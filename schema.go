@@ -0,0 +1,172 @@
+package main
+
+//
+// _ggv.json schema versions, older files are migrated when read and backed
+// up before they are first saved in the new version, newer ones are refused
+//
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// what saveGvv writes, bump it with a migration when ggvJson changes in a
+// way older gg can not just ignore. Everything so far is optional and
+// ignored by older gg, so files from before there was a Version are still
+// the first version.
+const ggvSchemaVersion = "0.1"
+
+// files without a Version are from before there was one
+const ggvFirstVersion = "0.1"
+
+type ggvMigration struct {
+	From    string
+	To      string
+	Note    string
+	Migrate func(raw map[string]interface{}) error // on the decoded json, before it is read into ggvJson
+}
+
+// in order, From of each is To of the one before, none yet
+var ggvMigrations = []ggvMigration{}
+
+// notes of the migrations applied, raw is changed in place
+func migrateGgvRaw(raw map[string]interface{}, filename string) (string, []string, error) {
+	version, _ := raw["Version"].(string)
+	if version == "" {
+		version = ggvFirstVersion
+	}
+	from := version
+
+	if compareVersions(version, ggvSchemaVersion) > 0 {
+		return from, nil, fmt.Errorf("%s is schema version %s, this gg only knows up to %s. Please update gg.", filename, version, ggvSchemaVersion)
+	}
+
+	var notes []string
+	for _, migration := range ggvMigrations {
+		if migration.From != version {
+			continue
+		}
+		err := migration.Migrate(raw)
+		if err != nil {
+			return from, nil, fmt.Errorf("Unable to migrate %s from schema version %s to %s. %s", filename, migration.From, migration.To, err)
+		}
+		notes = append(notes, migration.From+" -> "+migration.To+": "+migration.Note)
+		version = migration.To
+	}
+	if version != ggvSchemaVersion {
+		return from, nil, fmt.Errorf("%s has unknown schema version %s.", filename, from)
+	}
+	raw["Version"] = version
+	return from, notes, nil
+}
+
+// fields in raw that t has no place for, e.g. Packages[github.com/a/b].Foo,
+// matched as encoding/json does, ignoring case
+func unknownJsonFields(value interface{}, t reflect.Type, path string) []string {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	var unknown []string
+	switch t.Kind() {
+	case reflect.Struct:
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		fields := map[string]reflect.Type{}
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if field.PkgPath != "" {
+				continue
+			}
+			name := strings.Split(field.Tag.Get("json"), ",")[0]
+			if name == "-" {
+				continue
+			}
+			if name == "" {
+				name = field.Name
+			}
+			fields[strings.ToLower(name)] = field.Type
+		}
+		for _, key := range sortedJsonKeys(object) {
+			fieldPath := key
+			if path != "" {
+				fieldPath = path + "." + key
+			}
+			fieldType, ok := fields[strings.ToLower(key)]
+			if !ok {
+				unknown = append(unknown, fieldPath)
+				continue
+			}
+			unknown = append(unknown, unknownJsonFields(object[key], fieldType, fieldPath)...)
+		}
+	case reflect.Map:
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		for _, key := range sortedJsonKeys(object) {
+			unknown = append(unknown, unknownJsonFields(object[key], t.Elem(), path+"["+key+"]")...)
+		}
+	case reflect.Slice:
+		list, ok := value.([]interface{})
+		if !ok {
+			return nil
+		}
+		for i, item := range list {
+			unknown = append(unknown, unknownJsonFields(item, t.Elem(), path+"["+strconv.Itoa(i)+"]")...)
+		}
+	}
+	return unknown
+}
+
+func sortedJsonKeys(object map[string]interface{}) []string {
+	var keys []string
+	for key := range object {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// decode content of filename, migrated to the current version, unknown
+// fields warned about and remembered so saveGvv does not drop them silently
+func decodeGgv(content []byte, filename string) (*ggvJson, error) {
+	var raw map[string]interface{}
+	err := json.Unmarshal(content, &raw)
+	if err != nil {
+		return nil, err
+	}
+
+	from, notes, err := migrateGgvRaw(raw, filename)
+	if err != nil {
+		return nil, err
+	}
+	migrated, err := json.Marshal(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	var ggv ggvJson
+	err = json.Unmarshal(migrated, &ggv)
+	if err != nil {
+		return nil, err
+	}
+
+	ggv.readVersion = from
+	ggv.migrations = notes
+	ggv.original = content
+	ggv.unknownFields = unknownJsonFields(raw, reflect.TypeOf(ggv), "")
+	for _, note := range notes {
+		gglog.Printf("decodeGgv %s %s\n", filename, note)
+	}
+	if len(ggv.unknownFields) > 0 {
+		ggWarn("%s has fields this gg does not know, they are ignored: %s", filename, strings.Join(ggv.unknownFields, ", "))
+	}
+	return &ggv, nil
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestUnknownJsonFields(t *testing.T) {
+	content := `{
+		"Version": "0.1",
+		"vendorprefix": "example.com/vendor",
+		"Mirror": "x",
+		"Packages": {
+			"example.com/a": {"Vcs": "git", "Prune": {"Tests": true, "Docs": true}, "Patches": ["a.patch"], "Extra": 1},
+			"example.com/b": {"Vcs": "hg"}
+		},
+		"UrlRewrites": [{"Url": "u", "InsteadOf": "i"}, {"Url": "u", "Mode": "ssh"}],
+		"LicensePolicy": {"Allow": ["MIT"], "Review": ["GPL-2.0"]}
+	}`
+	var raw map[string]interface{}
+	err := json.Unmarshal([]byte(content), &raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	unknown := unknownJsonFields(raw, reflect.TypeOf(ggvJson{}), "")
+	want := []string{
+		"LicensePolicy.Review",
+		"Mirror",
+		"Packages[example.com/a].Extra",
+		"Packages[example.com/a].Prune.Docs",
+		"UrlRewrites[1].Mode",
+	}
+	if !reflect.DeepEqual(unknown, want) {
+		t.Errorf("unknownJsonFields = %v, want %v", unknown, want)
+	}
+
+	// fields that are not exported are not json fields
+	raw = map[string]interface{}{"readVersion": "0.1", "split": true}
+	unknown = unknownJsonFields(raw, reflect.TypeOf(ggvJson{}), "")
+	if !reflect.DeepEqual(unknown, []string{"readVersion", "split"}) {
+		t.Errorf("unknownJsonFields of unexported fields = %v", unknown)
+	}
+}
+
+func TestDecodeGgvVersions(t *testing.T) {
+	gglogDisable()
+	ggv, err := decodeGgv([]byte(`{"VendorPrefix": "example.com/vendor", "Packages": {}}`), "_ggv.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ggv.readVersion != ggvFirstVersion || len(ggv.migrations) != 0 {
+		t.Errorf("decodeGgv without a Version read as %s with %v", ggv.readVersion, ggv.migrations)
+	}
+
+	_, err = decodeGgv([]byte(`{"Version": "99.0", "VendorPrefix": "example.com/vendor", "Packages": {}}`), "_ggv.json")
+	if err == nil || !strings.Contains(err.Error(), "schema version 99.0") {
+		t.Errorf("decodeGgv of a newer version, error %v", err)
+	}
+
+	_, err = decodeGgv([]byte(`{"Version": "0.0.5", "Packages": {}}`), "_ggv.json")
+	if err == nil || !strings.Contains(err.Error(), "unknown schema version 0.0.5") {
+		t.Errorf("decodeGgv of a version there is no migration from, error %v", err)
+	}
+}
+
+// a migration of the version before the current one, as the next real one
+// will look
+func testGgvMigration(t *testing.T, migrate func(raw map[string]interface{}) error) string {
+	from := "0.0"
+	migrations := ggvMigrations
+	ggvMigrations = []ggvMigration{{from, ggvSchemaVersion, "Sources renamed to VcsSource.", migrate}}
+	t.Cleanup(func() {
+		ggvMigrations = migrations
+	})
+	return from
+}
+
+func TestMigrateGgv(t *testing.T) {
+	gglogDisable()
+	from := testGgvMigration(t, func(raw map[string]interface{}) error {
+		packages, _ := raw["Packages"].(map[string]interface{})
+		for _, pkg := range packages {
+			if info, ok := pkg.(map[string]interface{}); ok {
+				info["VcsSource"] = info["Source"]
+				delete(info, "Source")
+			}
+		}
+		return nil
+	})
+
+	dir := t.TempDir()
+	vendorFilename := filepath.Join(dir, "_ggv.json")
+	content := []byte(`{"Version": "` + from + `", "VendorPrefix": "example.com/vendor", "Packages": {"example.com/a": {"Vcs": "git", "Source": "https://git.example.com/a"}}}`)
+	err := ioutil.WriteFile(vendorFilename, content, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ggv, err := decodeGgv(content, vendorFilename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ggv.readVersion != from || len(ggv.migrations) != 1 || len(ggv.unknownFields) != 0 {
+		t.Errorf("decodeGgv read %s, migrations %v, unknown %v", ggv.readVersion, ggv.migrations, ggv.unknownFields)
+	}
+	if source := ggv.Packages["example.com/a"].VcsSource; source != "https://git.example.com/a" {
+		t.Errorf("migrated VcsSource = %q", source)
+	}
+
+	// the file as it was is kept before the first save, and only then
+	err = ggv.saveGvv(vendorFilename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	backupFilename := vendorFilename + ".v" + from + ".bak"
+	backup, err := ioutil.ReadFile(backupFilename)
+	if err != nil || string(backup) != string(content) {
+		t.Errorf("backup %s = %q, %v, want the file as read", backupFilename, backup, err)
+	}
+	os.Remove(backupFilename)
+
+	err = ggv.saveGvv(vendorFilename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(backupFilename); err == nil {
+		t.Errorf("backup written again on the second save")
+	}
+	saved, _ := ioutil.ReadFile(vendorFilename)
+	if !strings.Contains(string(saved), `"Version": "`+ggvSchemaVersion+`"`) || strings.Contains(string(saved), `"Source"`) {
+		t.Errorf("saved %s", saved)
+	}
+}
+
+func TestMigrateGgvError(t *testing.T) {
+	from := testGgvMigration(t, func(raw map[string]interface{}) error {
+		return errors.New("can not")
+	})
+	_, err := decodeGgv([]byte(`{"Version": "`+from+`", "Packages": {}}`), "_ggv.json")
+	if err == nil || !strings.Contains(err.Error(), "Unable to migrate _ggv.json from schema version "+from) {
+		t.Errorf("decodeGgv with a failing migration, error %v", err)
+	}
+}
+
+func TestReadLockNewerVersion(t *testing.T) {
+	dir := t.TempDir()
+	vendorFilename := filepath.Join(dir, "_ggv.json")
+	err := ioutil.WriteFile(ggvLockFilename(vendorFilename), []byte(`{"Version": "99.0", "Packages": {}}`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ggv := &ggvJson{Packages: map[string]*ggvPackage{}}
+	err = ggv.readLock(vendorFilename)
+	if err == nil || !strings.Contains(err.Error(), "schema version 99.0") {
+		t.Errorf("readLock of a newer version, error %v", err)
+	}
+}
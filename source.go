@@ -0,0 +1,53 @@
+package main
+
+//
+// rewriting vcs sources at fetch time, like git's url.<base>.insteadOf
+//
+
+import (
+	"strings"
+)
+
+// add the vendor root rules to the user rules, for the rest of the run
+func (cmd *ggcmd) loadUrlRewrites(ggv *ggvJson) {
+	cmd.urlRewrites = append([]*ggvUrlRewrite{}, cmd.userConfig().UrlRewrites...)
+	cmd.urlRewrites = append(cmd.urlRewrites, ggv.UrlRewrites...)
+}
+
+// source to fetch from, the longest matching InsteadOf wins
+// on equal length the user rule wins, it knows the network we are on
+func (cmd *ggcmd) rewriteVcsSource(vcsSource string) string {
+	rules := cmd.urlRewrites
+	if rules == nil {
+		rules = cmd.userConfig().UrlRewrites
+	}
+
+	var best *ggvUrlRewrite
+	for _, rule := range rules {
+		if rule.InsteadOf == "" || !strings.HasPrefix(vcsSource, rule.InsteadOf) {
+			continue
+		}
+		if best == nil || len(rule.InsteadOf) > len(best.InsteadOf) {
+			best = rule
+		}
+	}
+
+	if best == nil {
+		return vcsSource
+	}
+	rewritten := best.Url + vcsSource[len(best.InsteadOf):]
+	gglog.Printf("rewriteVcsSource %s -> %s\n", vcsSource, rewritten)
+	return rewritten
+}
+
+// sources to try in order, rewritten
+func (cmd *ggcmd) vcsSourcesFor(info *ggvPackage) []string {
+	var sources []string
+	for _, source := range append([]string{info.VcsSource}, info.Fallbacks...) {
+		source = cmd.sshVcsSource(info.Vcs, cmd.rewriteVcsSource(source))
+		if source != "" && !stringInSlice(source, sources) {
+			sources = append(sources, source)
+		}
+	}
+	return sources
+}
@@ -10,9 +10,20 @@ Manage vendor directory:
 
  vinit    Initialize vendor directory.
  vadd     Add package to vendor.
+ vaudit   Check vendored packages against advisory database.
+ vcheck   Check vendor directory against _ggv.json.
+ vdiff    Show source changes between vendored and newer revision.
+ vgraph   Export dependency graph of vendored packages.
+ vlicenses License inventory of vendored packages.
  vlist    List packages being vendored.
+ vlog     Show upstream commit log since vendored revision.
+ vmigrate-config Upgrade _ggv.json to the current schema version.
+ vpatch   Manage local patches of vendored packages.
+ vprune   Prune vendored packages down to what is used.
  vrebuild Rebuild from config file.
+ vstatus  Health summary of the vendor root.
  vupdate  Update packages.
+ vwhy     Explain why a package is vendored.
 
 Import rewriting:
 
@@ -24,10 +35,30 @@ Other commands:
  rdep     List dependencies of a go-getable package.
  ldep     List dependencies of local directory/package.
 
+Global options:
+
+ --offline Use only local mirrors, caches and the vendor tree, never the
+           network. Same as GG_OFFLINE=1.
+
+Record options, of vlist, vstatus, rdep, ldep, pkgmeta, vadd, vupdate and
+vrebuild:
+
+ --json    Print records as json. Other output goes to stderr.
+ --format TEMPLATE Print each record with a go template instead, e.g.
+           '{{.Name}} {{.Revision}}'.
+
 Special files:
 
  _ggv.json Vendor configuration file.
+ _ggv.lock.json Revisions, update times and hashes, when split from
+           _ggv.json, see vmigrate-config.
+ _ggv.json.v*.bak _ggv.json before a schema migration.
+ _ggmap.json Import path mappings for hosts without go-get meta pages.
  .gg       Specifies vendor root to use.
+ GGHOME/config.json User settings, e.g. url rewrites. GGHOME is ~/.gghome
+           by default.
+ GGHOME/cache/meta.json Cached package meta lookups.
+ GGHOME/map.json Your own import path mappings.
 
 Use "gg help <command>" for usage of a specific command.
 `, nil},
@@ -47,6 +78,8 @@ Options:
 
  -v --vendor VENDOR_ROOT Create vendor description file at specified
                          package directory under GOPATH.
+ --split=false           Keep what gg resolves in _ggv.lock.json from the
+                         start, see vmigrate-config.
 `, cmd.cmdVinit},
 
 		// ---------------------------------------------------
@@ -62,8 +95,13 @@ Add package to vendor.
 Options:
 
  -v --vendor VENDOR_ROOT Vendor package root
- --vcs VCS               git, hg
+ --vcs VCS               git, hg, proxy
  --vcs-source URL        Source of the package. https://github.com/a/b
+                         For proxy, the GOPROXY base url followed by the
+                         module path, defaults to $GOPROXY.
+ --fallbacks URLS        Comma separated sources tried in order when the
+                         vcs-source can not be fetched. For the package
+                         given only, not its dependencies.
  --revision REVISION     Revision, or latest if not specified.
  --lock=false            Lock on revision when adding done.
  --rewrite=true          Will bring in the package(s), but skip import rewrite.
@@ -73,7 +111,226 @@ Options:
  --dep-tests=true        Check for dependencies of tests as well.
  --notes NOTES           Add notes for package.
  --test=false            Dry run test.
+ --refresh=false         Look up package meta again instead of using the
+                         cache in GGHOME.
+ --platforms LIST        Comma separated GOOS/GOARCH dependencies are
+                         collected for, saved as Platforms in _ggv.json.
+ --tags LIST             Comma separated build tags, saved as BuildTags.
+ --include GLOBS         Comma separated globs of what to vendor of the
+                         repo, e.g. aws,service/s3. Saved as Include.
+ --exclude GLOBS         Comma separated globs of what not to vendor, e.g.
+                         cmd,_examples. Saved as Exclude.
 `, cmd.cmdVadd},
+		// ---------------------------------------------------
+		"vdiff": {`gg vdiff [options] <gg-package>
+
+Show source changes between vendored and newer revision.
+
+    Fetch the package at the target revision, rewrite imports the same way
+    vupdate would and show a unified diff against the vendored tree.
+
+Options:
+
+ -v --vendor VENDOR_ROOT Vendor package root
+ --to REVISION           Revision to compare against, latest if not
+                         specified.
+ --stat=false            Only show a summary of changed files.
+ --ignore-rewrite=false  Ignore differences caused only by import rewrites.
+`, cmd.cmdVdiff},
+		// ---------------------------------------------------
+		"vlog": {`gg vlog [options] [<gg-package> ...]
+
+Show upstream commit log since the vendored revision.
+
+    For each package, or all packages if none specified, list the commits
+    between the vendored revision and the latest revision. History is kept in
+    a local mirror under $GGHOME (default ~/.gghome). Reports when the history
+    between the two revisions is not linear.
+
+Options:
+
+ -v --vendor VENDOR_ROOT Vendor package root
+ --from REVISION         Start revision, vendored revision if not specified.
+ --to REVISION           End revision, latest if not specified.
+ --markdown=false        Markdown output, e.g. for update descriptions.
+ --refresh=true          Pull latest changes into the local mirror.
+`, cmd.cmdVlog},
+		// ---------------------------------------------------
+		"vwhy": {`gg vwhy [options] <gg-package>
+
+Explain why a package is in the vendor directory.
+
+    Build the import graph of the vendored packages, and optionally of the
+    projects using the vendor root, and print the shortest import chains
+    leading to the package. Reports when nothing depends on it anymore.
+
+Options:
+
+ -v --vendor VENDOR_ROOT Vendor package root
+ -c --consumers DIRS     Comma separated directories of projects using the
+                         vendor root.
+ --dep-tests=false       Also follow imports of tests.
+`, cmd.cmdVwhy},
+		// ---------------------------------------------------
+		"vgraph": {`gg vgraph [options]
+
+Export the dependency graph of the vendor directory.
+
+    Print the import graph of the vendored packages, and optionally of the
+    projects using the vendor root. Nodes are annotated with their status
+    (vendored, canonical when imported by the canonical path although a
+    vendored copy exists, missing) and vendored revision.
+
+Options:
+
+ -v --vendor VENDOR_ROOT Vendor package root
+ --graph-format FORMAT   dot, json or mermaid. Default dot.
+ --repo=false            Graph of vendored repos instead of packages.
+ -c --consumers DIRS     Comma separated directories of projects using the
+                         vendor root.
+ --dep-tests=false       Also follow imports of tests.
+`, cmd.cmdVgraph},
+		// ---------------------------------------------------
+		"vprune": {`gg vprune [options] [<gg-package> ...]
+
+Prune vendored packages down to what is used.
+
+    Remove tests, testdata directories and files that are not go sources from
+    the vendored packages, or all packages if none specified. With --unused,
+    also remove sub-packages that are not reachable from the projects using
+    the vendor root or from other vendored packages. License, notice and
+    similar files are always kept. The prune is recorded in _ggv.json and
+    reapplied by vadd, vupdate and vrebuild.
+
+Options:
+
+ -v --vendor VENDOR_ROOT Vendor package root
+ -c --consumers DIRS     Comma separated directories of projects using the
+                         vendor root. Needed for --unused.
+ --unused=false          Remove sub-packages nothing imports.
+ --tests=true            Remove _test.go files.
+ --testdata=true         Remove testdata directories.
+ --non-go=true           Remove files that are not go sources.
+ --off=false             Stop pruning. Next vrebuild or vupdate restores the
+                         full package.
+ --test=false            Dry run test, list what would be removed.
+`, cmd.cmdVprune},
+		// ---------------------------------------------------
+		"vlicenses": {`gg vlicenses [options]
+
+License inventory of vendored packages.
+
+    Detect license files in each vendored package and classify them offline
+    by text similarity against known licenses (MIT, BSD, Apache-2.0, GPL,
+    LGPL, MPL and others). The SPDX id is stored in _ggv.json. Packages with
+    unknown, missing or disallowed licenses are flagged and the exit code is
+    non zero.
+
+Options:
+
+ -v --vendor VENDOR_ROOT Vendor package root
+ --allow IDS             Comma separated SPDX ids allowed, anything else is
+                         flagged. Saved in _ggv.json.
+ --deny IDS              Comma separated SPDX ids denied. Saved in _ggv.json.
+ --notice FILE           Write combined third party notice file.
+ --test=false            Report only, do not update _ggv.json.
+`, cmd.cmdVlicenses},
+		// ---------------------------------------------------
+		"vaudit": {`gg vaudit [options] [<gg-package> ...]
+
+Check vendored packages against a local advisory database.
+
+    Read OSV format advisories from a directory, .zip or .tar.gz and match
+    the canonical path and vendored revision of each package, or all packages
+    if none specified. Commit ranges are resolved through the local mirror
+    under $GGHOME, version ranges through the closest tag. Exits non zero
+    when an advisory is at or above the --fail-on severity. Unknown severity
+    counts as medium.
+
+Options:
+
+ -v --vendor VENDOR_ROOT Vendor package root
+ --db PATH               OSV advisory directory or archive. Default is
+                         $GG_ADVISORY_DB.
+ --fail-on=high          low, medium, high or critical.
+ --refresh=false         Pull latest changes into the local mirrors.
+`, cmd.cmdVaudit},
+		// ---------------------------------------------------
+		"vcheck": {`gg vcheck [options]
+
+Check the vendor directory against _ggv.json.
+
+    Report vendored packages missing on disk or empty manual entries,
+    directories nobody recorded, nested package entries, imports of canonical
+    paths that should have been rewritten and rewritten imports pointing at
+    packages that are not vendored. Exits non zero when problems are found.
+
+Options:
+
+ -v --vendor VENDOR_ROOT Vendor package root
+ --dep-tests=false       Also check imports of tests.
+`, cmd.cmdVcheck},
+		// ---------------------------------------------------
+		"vstatus": {`gg vstatus [options] [<gg-package> ...]
+
+Health summary of the vendor root.
+
+    For each vendored package, or the ones given, show whether it is locked,
+    days since LastUpdate, commits behind upstream, local modifications,
+    whether imports are rewritten, how many packages import it, its license
+    and advisories. Local modifications are found against the TreeHash gg
+    records whenever it writes a package, packages vendored before show
+    unknown until the next vupdate or vrebuild. Commits behind come from the
+    mirrors under GGHOME, see vlog. Without --refresh a package that has no
+    mirror yet shows no mirror, ? means they could not be counted, e.g. the
+    history is not linear.
+
+    Ends with a summary line. Exits 1 when a package is missing, modified,
+    has a flagged license or an advisory at or above --fail-on, 2 when there
+    are only warnings (behind upstream, older than --max-age, advisories
+    below --fail-on), 0 otherwise.
+
+Options:
+
+ -v --vendor VENDOR_ROOT Vendor package root
+ -c --consumers DIRS     Comma separated directories of projects using the
+                         vendor root, counted as importers.
+ --dep-tests=false       Also count imports of tests.
+ --refresh=false         Pull latest changes into the local mirrors, clone
+                         the missing ones.
+ --db DB                 OSV advisory directory, .zip or .tar.gz. Defaults to
+                         GG_ADVISORY_DB, advisories are skipped without one.
+ --fail-on=high          low, medium, high or critical.
+ --max-age DAYS          Warn about packages not updated for DAYS days.
+`, cmd.cmdVstatus},
+		// ---------------------------------------------------
+		"vpatch": {`gg vpatch create [options] <gg-package>
+gg vpatch list [options] [<gg-package> ...]
+gg vpatch drop [options] <gg-package> <patch>
+gg vpatch export [options] <gg-package>
+
+Manage local patches of vendored packages.
+
+    create records the difference between the vendored files and a fresh
+    download at the vendored revision as a patch under _ggpatches, next to
+    _ggv.json, and adds it to the package in _ggv.json. vadd, vupdate and
+    vrebuild reapply the patches in order after import rewrites and prune,
+    and stop when a patch no longer applies.
+
+    list shows the patches of the packages, or of all patched packages.
+
+    drop removes a patch. The vendored files keep the change until the
+    next vrebuild or vupdate.
+
+    export writes each patch against the canonical import paths, with the
+    import rewrites undone, to send upstream.
+
+Options:
+
+ -v --vendor VENDOR_ROOT Vendor package root
+ --name NAME             create: name of the patch file (default local).
+ --dir DIR               export: directory to write the patches to.
+`, cmd.cmdVpatch},
 		// ---------------------------------------------------
 		"voption": {`gg voption [options] [<gg-package> ...]
 
@@ -114,6 +371,31 @@ List managed packages.
 Options:
  -v --vendor VENDOR_ROOT Vendor package root.
 `, cmd.cmdVlist},
+		// ---------------------------------------------------
+		"vmigrate-config": {`gg vmigrate-config [options]
+
+Upgrade _ggv.json to the current schema version.
+
+    _ggv.json records its schema version. Older files are migrated whenever
+    gg reads them, and saved in the new version by the next command that
+    writes them, after a backup as _ggv.json.v<old version>.bak. This does
+    it right away. Files of a newer version are refused, update gg instead.
+
+    Fields gg does not know are warned about and gg will not save over them,
+    so they are not lost silently. Update gg, or drop them with
+    --drop-unknown.
+
+    --split moves what gg resolves (Revision, LastUpdate, Version, ZipHash,
+    TreeHash) into _ggv.lock.json, leaving _ggv.json with what you want,
+    License included, as vlicenses records it for you to review. Once there is a _ggv.lock.json, every command reads both and
+    writes both, and vrebuild takes revisions only from the lock file.
+
+Options:
+ -v --vendor VENDOR_ROOT Vendor package root.
+ --drop-unknown=false    Remove fields this gg does not know.
+ --split=false           Keep the resolved state in _ggv.lock.json.
+ --test=false            Just test to see what will change.
+`, cmd.cmdVmigrateConfig},
 		// ---------------------------------------------------
 		"vstrip": {`gg vstrip [options]
 
@@ -131,7 +413,9 @@ Options:
 Rebuild vendor directory.
 
     Given the configuration file _ggv.json in the vendor directory, strip and
-    rebuild as described.
+    rebuild as described. Revisions and hashes that were not recorded yet,
+    e.g. the TreeHash of packages vendored before there was one, are saved
+    to _ggv.json, or _ggv.lock.json when split.
 
 Options:
  -v --vendor VENDOR_ROOT Vendor package root.
@@ -151,6 +435,9 @@ Options:
  --revision REVISION  Update specified package to revision. (shallow)
  --test               See what would actually get updated without modifying
                       your vendor directory.
+ --refresh=false      Look up package meta again instead of using the cache.
+ --platforms LIST     Comma separated GOOS/GOARCH, saved in _ggv.json.
+ --tags LIST          Comma separated build tags, saved in _ggv.json.
 `, cmd.cmdVupdate},
 		// ---------------------------------------------------
 		"usev": {`gg usev [options] [<gg-package> ...]
@@ -204,31 +491,63 @@ Options:
 Check dependencies of a package in a public repo.
 
     Given a canonical package name, get dependencies as it currently exists on
-    the internet. This is done by creating a clean temporary directory and
-    running go get.
+    the internet. Each repo is fetched the same way vadd does and the imports
+    are read from the sources. Dependencies only some platforms need are
+    listed with those platforms.
 
 Options:
 
  --dep-tests=true  Check for dependencies of tests as well.
+ --graph FORMAT    Print the dependency graph as dot, json or mermaid.
+ --repo=false      With --graph, graph of repos instead of packages.
+ --platforms LIST  Comma separated GOOS/GOARCH, e.g. linux/amd64,windows/amd64.
+                   Defaults to the Platforms of the vendor root here, or the
+                   host.
+ --tags LIST       Comma separated build tags.
 `, cmd.cmdRdep},
 		// ---------------------------------------------------
-		"ldep": {`gg ldep [options] [<local-package>]
+		"ldep": {`gg ldep [options] [<local-package> ...]
 
 Check dependencies of a package residing on local disk.
 
-    For a local directory/package on your disk, determine the dependencies.
-    Skip core packages and subpackages already found under the package being
-    queried.
+    For local directories/packages on your disk, determine the dependencies.
+    Patterns such as ./... are allowed, the dependencies of all packages
+    matched are listed together. Skip core packages and subpackages already
+    found under the packages being queried.
+
+    With --status, each dependency is shown as one of
+      vendored   imported under the vendor prefix
+      canonical  vendored, but imported by its canonical path, run usev
+      missing    not vendored, run vadd
+      local      part of the module or repo being checked
 
 Options:
 
- --dep-tests=true  Check for dependencies of tests as well.
+ -v --vendor VENDOR_ROOT Vendor package root, used to annotate the graph.
+ --dep-tests=true        Check for dependencies of tests as well.
+ --graph FORMAT          Print the dependency graph as dot, json or mermaid.
+ --repo=false            With --graph, graph of repos instead of packages.
+ --platforms LIST        Comma separated GOOS/GOARCH, e.g. linux/amd64,
+                         windows/amd64. Defaults to the Platforms of the
+                         vendor root, or the host.
+ --tags LIST             Comma separated build tags.
+ --status=false          Show the vendor status of each dependency, needs a
+                         vendor root.
 
 `, cmd.cmdLdep},
 		// ---------------------------------------------------
-		"pkgmeta": {`gg pkgmeta
+		"pkgmeta": {`gg pkgmeta [options] <package> [<package> ...]
 
 Get meta for package.
+
+    Results are cached in GGHOME/cache/meta.json, found repo roots for 7
+    days, failed lookups for an hour.
+
+Options:
+
+ --refresh=false         Look up again instead of using the cache.
+ --cache=false           Show the cached entries for the given packages, all
+                         if none specified.
 `, cmd.cmdPkgmeta},
 	}
 }
@@ -1,12 +1,13 @@
 package main
 
 import (
+	"bytes"
 	"encoding/json"
 	"errors"
-	"fmt"
 	"io/ioutil"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"sort"
 	"strings"
 	"time"
@@ -22,11 +23,15 @@ type goListJson struct {
 	Doc         string
 	Target      string
 	Root        string
+	Standard    bool
 	Gofiles     []string
 	Imports     []string
 	Deps        []string
 	TestGoFiles []string
 	TestImports []string
+	Module      *struct {
+		Path string
+	}
 }
 
 func getNowStr() string {
@@ -37,21 +42,15 @@ func getNowStr() string {
 
 // github.com/jprobinson/go-imap/imap
 // actually lives in the github.com/jprobinson/go-imap repo
-// only works on github.com and bitbucket.org for now
+// only works on known hosts, see knownhosts.go
 //
 // For other things, you will need to specify the dependencies individually
 //
-func trimPackageToRepo(p string) string {
-	if !strings.HasPrefix(p, "github.com") && !strings.HasPrefix(p, "bitbucket.org") {
-		return p
-	}
-
-	pparts := strings.Split(p, "/")
-	if len(pparts) <= 3 {
-		return p
+func (cmd *ggcmd) trimPackageToRepo(p string) string {
+	if meta := cmd.knownHostMeta(p); meta != nil {
+		return meta.Prefix
 	}
-
-	return strings.Join(pparts[:3], "/")
+	return p
 }
 
 func getCurrentGopath() (string, error) {
@@ -64,119 +63,162 @@ func getCurrentGopath() (string, error) {
 	return "", errors.New("GOPATH not found")
 }
 
-func getEnvWithNewGopath(newGopath string) []string {
-	currentenv := os.Environ()
-	// same env, but change GOPATH
-	subenv := make([]string, len(currentenv))
-	for i, envval := range currentenv {
-		if strings.HasPrefix(envval, "GOPATH=") {
-			subenv[i] = fmt.Sprintf("GOPATH=%s", newGopath)
-		} else {
-			subenv[i] = envval
-		}
-	}
-
-	return subenv
+// package/repo dependencies, read from the fetched sources, with the
+// platforms of the ones not every platform needs
+func (cmd *ggcmd) rdepHelper(rpkg string, includeTestDeps bool) ([]string, map[string][]string) {
+	analysis := cmd.newDepAnalysis(nil)
+	analysis.run([]string{rpkg}, includeTestDeps, nil)
+	return analysis.depsOf(rpkg), analysis.platformSpecific()
 }
 
-// package/repo dependencies
-func (cmd *ggcmd) rdepHelper(rpkg string, includeTestDeps bool) []string {
-	tempdir, err := ioutil.TempDir("", "gg")
-	if err != nil {
-		ggFatal("Unable to create temp directory %s", err)
-	}
-	defer os.RemoveAll(tempdir)
-	os.Mkdir(tempdir+"/src", os.ModePerm)
-
-	subcmd := exec.Command("go", "get", rpkg)
-	subcmd.Env = getEnvWithNewGopath(tempdir)
-	_, err = subcmd.Output()
-	if err != nil {
-		ggFatal("Unable to get package specified err=%s", err)
+// union over the platforms, with the platforms of the ones not every
+// platform needs
+// imports under vendorPrefix are kept, they would look like core packages
+func (cmd *ggcmd) ldepHelper(goListArgs []string, includeTestDeps bool, vendorPrefix string) ([]string, map[string][]string) {
+	needs := map[string]map[string]bool{}
+	var deps []string
+	for _, platform := range cmd.platforms() {
+		for _, pkg := range cmd.goListDeps(platform.goListEnv(), goListArgs, includeTestDeps) {
+			vendored := vendorPrefix != "" && strings.HasPrefix(pkg, vendorPrefix+"/")
+			if cmd.isCorePackage(pkg) && !vendored {
+				continue
+			}
+			if needs[pkg] == nil {
+				needs[pkg] = map[string]bool{}
+				deps = append(deps, pkg)
+			}
+			needs[pkg][platform.String()] = true
+		}
 	}
+	sort.Strings(deps)
+	return deps, platformSpecific(needs, cmd.platforms())
+}
 
-	subcmd = exec.Command("go", "list", "-e", "-json", rpkg)
-	subcmd.Env = getEnvWithNewGopath(tempdir)
+// go list -e -json, one entry per package matched
+func goListPackages(env []string, goListArgs []string) []goListJson {
+	subcmd := exec.Command("go", append([]string{"list", "-e", "-json"}, goListArgs...)...)
+	subcmd.Env = env
 	goListJsonRaw, err := subcmd.Output()
 	if err != nil {
 		ggFatal("Unable call go list on package err=%s", err)
 	}
 
-	var pkgGoList goListJson
-	err = json.Unmarshal(goListJsonRaw, &pkgGoList)
-	if err != nil {
-		ggFatal("Unable to parse json of go list err=%s", err)
+	var listed []goListJson
+	decoder := json.NewDecoder(bytes.NewReader(goListJsonRaw))
+	for decoder.More() {
+		var pkgGoList goListJson
+		err = decoder.Decode(&pkgGoList)
+		if err != nil {
+			ggFatal("Unable to parse json of go list err=%s", err)
+		}
+		listed = append(listed, pkgGoList)
 	}
+	return listed
+}
 
-	hasSeen := map[string]string{}
-	deps := make([]string, 0, len(pkgGoList.Deps)+len(pkgGoList.TestImports))
-	for _, pkg := range pkgGoList.Deps {
-		if !cmd.isCorePackage(pkg) && !strings.HasPrefix(pkg, pkgGoList.ImportPath) {
-			if hasSeen[pkg] != "" {
-				continue
-			}
-			deps = append(deps, pkg)
-			hasSeen[pkg] = pkg
+// is pkg one of the listed packages, or under one
+func underGoListed(pkg string, listed []goListJson) bool {
+	for _, pkgGoList := range listed {
+		if metaPrefixMatches(pkgGoList.ImportPath, pkg) {
+			return true
 		}
 	}
+	return false
+}
 
-	if !includeTestDeps {
-		sort.Strings(deps)
-		return deps
-	}
+// dependencies of all packages matched, core ones included
+func (cmd *ggcmd) goListDeps(env []string, goListArgs []string, includeTestDeps bool) []string {
+	listed := goListPackages(env, goListArgs)
 
-	for _, pkg := range pkgGoList.TestImports {
-		if !cmd.isCorePackage(pkg) && !strings.HasPrefix(pkg, pkgGoList.ImportPath) {
-			if hasSeen[pkg] != "" {
+	hasSeen := map[string]bool{}
+	var deps []string
+	for _, pkgGoList := range listed {
+		pkgDeps := pkgGoList.Deps
+		if includeTestDeps {
+			pkgDeps = append(append([]string{}, pkgDeps...), pkgGoList.TestImports...)
+		}
+		for _, pkg := range pkgDeps {
+			if pkg == "C" || hasSeen[pkg] || underGoListed(pkg, listed) {
 				continue
 			}
 			deps = append(deps, pkg)
-			hasSeen[pkg] = pkg
+			hasSeen[pkg] = true
 		}
 	}
+
 	sort.Strings(deps)
 	return deps
 }
 
-func (cmd *ggcmd) ldepHelper(goListArg string, includeTestDeps bool) []string {
-	subcmd := exec.Command("go", "list", "-e", "-json", goListArg)
-	goListJsonRaw, err := subcmd.Output()
-	if err != nil {
-		ggFatal("Unable call go list on package err=%s", err)
-	}
+// package/repo dependencies with the edges between them
+func (cmd *ggcmd) rdepGraphHelper(rpkg string, includeTestDeps bool) importGraph {
+	analysis := cmd.newDepAnalysis(nil)
+	analysis.run([]string{rpkg}, includeTestDeps, nil)
+	return analysis.Graph
+}
 
-	var pkgGoList goListJson
-	err = json.Unmarshal(goListJsonRaw, &pkgGoList)
-	if err != nil {
-		ggFatal("Unable to parse json of go list err=%s", err)
+// union of the graphs of each platform
+func (cmd *ggcmd) ldepGraphHelper(goListArgs []string, includeTestDeps bool) importGraph {
+	graph := importGraph{}
+	for _, platform := range cmd.platforms() {
+		for pkg, imports := range cmd.goListGraph(platform.goListEnv(), goListArgs, includeTestDeps) {
+			seen := map[string]bool{}
+			for _, imp := range graph[pkg] {
+				seen[imp] = true
+			}
+			if graph[pkg] == nil {
+				graph[pkg] = []string{}
+			}
+			for _, imp := range imports {
+				if !seen[imp] {
+					graph[pkg] = append(graph[pkg], imp)
+				}
+			}
+			sort.Strings(graph[pkg])
+		}
 	}
+	return graph
+}
 
-	hasSeen := map[string]string{}
-	deps := make([]string, 0, len(pkgGoList.Deps)+len(pkgGoList.TestImports))
-	for _, pkg := range pkgGoList.Deps {
-		if !cmd.isCorePackage(pkg) && !strings.HasPrefix(pkg, pkgGoList.ImportPath) {
-			deps = append(deps, pkg)
-			hasSeen[pkg] = pkg
+// edges between non core packages, starting at the packages matched
+func (cmd *ggcmd) goListGraph(env []string, goListArgs []string, includeTestDeps bool) importGraph {
+	roots := goListPackages(env, goListArgs)
+
+	// core packages are only known for sure once listed
+	isRoot := map[string]bool{}
+	var listArgs []string
+	for _, pkgGoList := range roots {
+		isRoot[pkgGoList.ImportPath] = true
+		listArgs = append(listArgs, pkgGoList.ImportPath)
+		listArgs = append(listArgs, pkgGoList.Deps...)
+		if includeTestDeps {
+			listArgs = append(listArgs, pkgGoList.TestImports...)
 		}
 	}
 
-	if !includeTestDeps {
-		sort.Strings(deps)
-		return deps
+	listed := goListPackages(env, listArgs)
+	isStandard := map[string]bool{}
+	for _, depGoList := range listed {
+		isStandard[depGoList.ImportPath] = depGoList.Standard
 	}
 
-	for _, pkg := range pkgGoList.TestImports {
-		if !cmd.isCorePackage(pkg) && !strings.HasPrefix(pkg, pkgGoList.ImportPath) {
-			if hasSeen[pkg] != "" {
-				continue
+	graph := importGraph{}
+	for _, depGoList := range listed {
+		if depGoList.Standard || graph[depGoList.ImportPath] != nil {
+			continue
+		}
+		imports := depGoList.Imports
+		if includeTestDeps && isRoot[depGoList.ImportPath] {
+			imports = append(imports, depGoList.TestImports...)
+		}
+		graph[depGoList.ImportPath] = []string{}
+		for _, imp := range imports {
+			if !isStandard[imp] && imp != "C" {
+				graph[depGoList.ImportPath] = append(graph[depGoList.ImportPath], imp)
 			}
-			deps = append(deps, pkg)
-			hasSeen[pkg] = pkg
 		}
 	}
-
-	sort.Strings(deps)
-	return deps
+	return graph
 }
 
 // simple naive way to see if it is an internal package by checking
@@ -190,3 +232,56 @@ func (cmd ggcmd) isCorePackage(name string) bool {
 	pkgParts := strings.Split(name, "/")
 	return !strings.Contains(pkgParts[0], ".")
 }
+
+// copy a directory tree, keeping file modes
+func copyDir(srcDir string, destDir string) error {
+	return filepath.Walk(srcDir, func(path string, f os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(srcDir, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(destDir, rel)
+
+		if f.IsDir() {
+			return os.MkdirAll(target, f.Mode()|0700)
+		}
+		if !f.Mode().IsRegular() {
+			return nil
+		}
+
+		content, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		return ioutil.WriteFile(target, content, f.Mode())
+	})
+}
+
+func readFileString(filename string) (string, error) {
+	content, err := ioutil.ReadFile(filename)
+	return string(content), err
+}
+
+func stringInSlice(s string, list []string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// "a, b,c" -> [a b c], empty for ""
+func splitCommaList(s string) []string {
+	var list []string
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}